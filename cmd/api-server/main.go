@@ -2,54 +2,144 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel"
 
 	"api-server/internal/config"
 	"api-server/internal/database"
+	"api-server/internal/handlers"
+	"api-server/internal/lifecycle"
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
 	tracing "api-server/internal/observability"
+	"api-server/internal/openapi"
+	"api-server/internal/repositories"
 	"api-server/internal/routes"
 	"api-server/internal/services"
+	"api-server/internal/utils"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", "error", err)
 	}
 
+	// Write JSON log lines, with secrets and bucket paths redacted
+	logging.Init(os.Stdout, logging.Policy{
+		Level:             cfg.LogLevel,
+		RedactKeys:        cfg.LogRedactKeys,
+		RedactBucketPaths: cfg.LogRedactBucketPaths,
+	})
+
 	// Initialize database
 	db, err := database.InitDB(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", "error", err)
 	}
-	defer db.Close()
 
-	// Initialize OpenTelemetry
-	log.Printf("Initializing OpenTelemetry with service name '%s' and endpoint '%s'",
-		cfg.ServiceName, cfg.OtlpEndpoint)
-	shutdown := tracing.InitTracer(cfg.ServiceName, cfg.OtlpEndpoint)
-	defer func() {
-		log.Println("Shutting down OpenTelemetry")
-		shutdown()
-	}()
+	// Bound every statement and storage call, also for the migrate command
+	repositories.SetQueryTimeouts(repositories.QueryTimeouts{
+		Read:    cfg.DBReadTimeout,
+		Write:   cfg.DBWriteTimeout,
+		Refresh: cfg.DBRefreshTimeout,
+	})
+	utils.SetStorageTimeout(cfg.StorageTimeout)
 
-	// Send Initial span on startup
-	go func() {
-		time.Sleep(2 * time.Second) // Wait for server to start
-		tracer := otel.Tracer("startup-inital-span")
-		ctx := context.Background()
-		_, span := tracer.Start(ctx, "startup-initial-span")
-		log.Println("Created initial span at startup")
-		time.Sleep(100 * time.Millisecond)
-		span.End()
-		log.Println("Ended initial span after startup")
-	}()
+	// Run `api-server migrate up|down [steps]|status` and exit
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(db, os.Args[2:])
+		db.Close()
+		if err != nil {
+			fatal("Migrate command failed", "error", err)
+		}
+		return
+	}
+
+	// Components are stopped in reverse order on shutdown, the database pool last
+	lifecycle.Register("database pool", func(context.Context) error { return db.Close() })
+
+	// Route list and analytics reads to the read replicas, if any
+	replicaPools, err := database.InitReplicas(cfg)
+	if err != nil {
+		db.Close()
+		fatal("Failed to initialize read replicas", "error", err)
+	}
+	for address, pool := range replicaPools {
+		lifecycle.Register("read replica pool "+address, func(context.Context) error { return pool.Close() })
+	}
+	lifecycle.RegisterFunc("read replica health checks", repositories.StartReadReplicas(replicaPools, repositories.ReplicaPolicy{
+		HealthInterval: cfg.DBReplicaHealthInterval,
+		StickyWindow:   cfg.DBReadYourWritesWindow,
+	}))
+
+	// Handlers, background jobs and the auth middleware read and write through one store
+	store := repositories.NewPostgresStore(db)
+
+	// Apply pending migrations, replicas starting together wait on an advisory lock
+	if cfg.DBAutoMigrate {
+		applied, err := database.MigrateUp(context.Background(), db)
+		if err != nil {
+			fatal("Failed to migrate database", "error", err)
+		}
+		for _, migration := range applied {
+			slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	// Initialize OpenTelemetry. Nothing waits for the collector, and the server still
+	// starts without exporting when an exporter cannot be set up.
+	slog.Info("Initializing OpenTelemetry", "service", cfg.ServiceName, "exporter", cfg.TracingExporter,
+		"protocol", cfg.OtlpProtocol, "endpoint", cfg.OtlpEndpoint, "sample_ratio", cfg.TracingSampleRatio)
+	res, err := tracing.NewResource(context.Background(), tracing.ServiceInfo{
+		Name:        cfg.ServiceName,
+		Version:     cfg.ServiceVersion,
+		Environment: cfg.DeploymentEnvironment,
+	})
+	if err != nil {
+		slog.Warn("Resource detection incomplete", "error", err)
+	}
+	collector := tracing.OTLPPolicy{
+		Protocol:   cfg.OtlpProtocol,
+		Endpoint:   cfg.OtlpEndpoint,
+		Headers:    cfg.OtlpHeaders,
+		Insecure:   cfg.OtlpInsecure,
+		CACert:     cfg.OtlpCACert,
+		ClientCert: cfg.OtlpClientCert,
+		ClientKey:  cfg.OtlpClientKey,
+	}
+	shutdownTracer, err := tracing.InitTracer(res, tracing.TracingPolicy{
+		Exporter:       cfg.TracingExporter,
+		Collector:      collector,
+		FilePath:       cfg.TracingFile,
+		SampleRatio:    cfg.TracingSampleRatio,
+		SamplerFromEnv: cfg.TracingSamplerFromEnv,
+		Propagators:    cfg.TracingPropagators,
+	})
+	if err != nil {
+		slog.Warn("Tracing degraded, spans are not exported", "error", err)
+	}
+	lifecycle.Register("tracer provider", shutdownTracer)
+	shutdownMeter, err := tracing.InitMeter(res, tracing.MetricsPolicy{
+		Prometheus:     cfg.MetricsPrometheusEnabled,
+		OTLP:           cfg.MetricsOTLPEnabled,
+		Collector:      collector,
+		ExportInterval: cfg.MetricsExportInterval,
+	})
+	if err != nil {
+		slog.Warn("Metrics degraded", "error", err)
+	}
+	if shutdownMeter != nil {
+		lifecycle.Register("meter provider", shutdownMeter)
+	}
 
 	// Initialize Kafka producer with authentication
 	services.InitKafkaProducer(
@@ -59,17 +149,134 @@ func main() {
 		cfg.KafkaPassword,
 		cfg.KafkaAuth,
 	)
-	defer services.CloseKafkaProducer()
+	lifecycle.RegisterFunc("Kafka producer", services.CloseKafkaProducer)
+
+	// Initialize upload validation and malware scanning
+	services.InitUploadValidation(services.UploadPolicy{
+		AllowedTypes:     cfg.UploadAllowedTypes,
+		MaxPDFPages:      cfg.UploadMaxPDFPages,
+		QuarantinePrefix: cfg.QuarantinePrefix,
+		BatchConcurrency: cfg.BatchUploadConcurrency,
+		BatchMaxFiles:    cfg.BatchUploadMaxFiles,
+		BatchMaxBytes:    cfg.BatchUploadMaxBytes,
 
-	// Register routes
-	r := routes.RegisterRoutes()
+		ResumableMaxBytes:      cfg.ResumableUploadMaxBytes,
+		ResumableChunkMaxBytes: cfg.ResumableChunkMaxBytes,
+		ResumableTTL:           cfg.ResumableUploadTTL,
+	}, cfg.ClamAVAddress)
 
-	// Wrap the router with OpenTelemetry middleware
-	handler := otelhttp.NewHandler(r, "api-server")
+	// Periodically remove abandoned resumable uploads
+	lifecycle.RegisterFunc("upload session janitor", services.StartUploadSessionJanitor(store, cfg.ResumableCleanupInterval))
+
+	// Refresh survey analytics in the background as results arrive
+	services.SetAnalyticsMinResponses(cfg.AnalyticsMinResponses)
+	lifecycle.RegisterFunc("analytics refresher", services.StartAnalyticsRefresher())
+
+	// Generate department reports in the background
+	lifecycle.RegisterFunc("report worker", services.StartReportWorker(store, services.ReportPolicy{
+		PollInterval: cfg.ReportPollInterval,
+		Lease:        cfg.ReportJobLease,
+	}))
+
+	// Allow the processing service to call internal routes
+	middleware.SetInternalAPIToken(cfg.InternalAPIToken)
+
+	// Replay responses to retried POST requests that carry an Idempotency-Key
+	middleware.SetIdempotencyKeyTTL(cfg.IdempotencyKeyTTL)
+	middleware.SetIdempotencyLease(cfg.IdempotencyLease)
+	middleware.SetIdempotencyStore(store)
+	lifecycle.RegisterFunc("idempotency key janitor", services.StartIdempotencyKeyJanitor(store, cfg.IdempotencyCleanupInterval))
+
+	// Deliver trace and course events to webhook subscriptions
+	lifecycle.RegisterFunc("webhook dispatcher", services.StartWebhookDispatcher(store, services.WebhookPolicy{
+		Timeout:       cfg.WebhookTimeout,
+		PollInterval:  cfg.WebhookPollInterval,
+		MaxAttempts:   cfg.WebhookMaxAttempts,
+		BackoffBase:   cfg.WebhookBackoffBase,
+		BackoffMax:    cfg.WebhookBackoffMax,
+		DisableAfter:  cfg.WebhookDisableAfterFailures,
+		AllowInsecure: cfg.WebhookAllowInsecure,
+	}))
+
+	// Stream trace events from every replica to GET /v1/events through PostgreSQL LISTEN/NOTIFY.
+	// The listener keeps its own connection and fetches the password again when it is rejected.
+	listenConnStr := func(ctx context.Context) (string, error) {
+		return database.ConnectionString(ctx, cfg)
+	}
+	lifecycle.RegisterFunc("trace event listener", services.StartTraceEventListener(listenConnStr, services.TraceEventPolicy{
+		ReplayBufferSize:  cfg.EventStreamReplayBuffer,
+		HeartbeatInterval: cfg.EventStreamHeartbeatInterval,
+		ClientBufferSize:  cfg.EventStreamClientBuffer,
+	}))
+
+	// Validate requests, and in tests responses, against the OpenAPI document
+	middleware.SetOpenAPIValidation(cfg.OpenAPIValidateRequests, cfg.OpenAPIValidateResponses)
+
+	// Cache the dependency checks of /readyz and /healthz between probes
+	services.SetHealthPolicy(services.HealthPolicy{
+		CacheTTL:     cfg.HealthCheckCacheTTL,
+		CheckTimeout: cfg.HealthCheckTimeout,
+	})
+
+	// Register routes, with requests authenticated against the users of the store
+	middleware.SetUserLookup(store)
+	r := routes.RegisterRoutes(handlers.NewHandler(store))
+	// routes_test.go keeps the document complete, requests to an undescribed route are not validated
+	if missing := openapi.Spec().MissingRoutes(r); len(missing) > 0 {
+		slog.Warn("Routes missing from the OpenAPI document", "routes", strings.Join(missing, ", "))
+	}
+
+	// Wrap the router with OpenTelemetry middleware and log every request with its request and trace IDs
+	handler := otelhttp.NewHandler(middleware.RequestIDMiddleware(middleware.AccessLogMiddleware(r)), "api-server")
+
+	server := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+	}
+	// event streams only end when closed, end them so shutdown does not wait out its timeout
+	server.RegisterOnShutdown(services.CloseTraceEventStreams)
+	lifecycle.Register("HTTP server", server.Shutdown)
 
 	// Start server
-	log.Printf("Server starting on :%s", cfg.ServerPort)
-	if err := http.ListenAndServe(":"+cfg.ServerPort, handler); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "port", cfg.ServerPort)
+		serverErr <- server.ListenAndServe()
+	}()
+	lifecycle.SetReady(true)
+
+	failed := false
+	select {
+	case <-signals.Done():
+		// keep serving while load balancers notice the failing health check
+		lifecycle.SetReady(false)
+		slog.Info("Shutting down, draining", "drain_delay", cfg.ShutdownDrainDelay.String())
+		time.Sleep(cfg.ShutdownDrainDelay)
+	case err := <-serverErr:
+		slog.Error("Server failed", "error", err)
+		failed = true
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := lifecycle.Shutdown(ctx); err != nil {
+		slog.Error("Shutdown incomplete", "error", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// fatal logs why the server cannot start and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"api-server/internal/database"
+)
+
+// runMigrate implements `api-server migrate up|down [steps]|status`
+func runMigrate(db *sql.DB, args []string) error {
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := database.MigrateUp(ctx, db)
+		for _, migration := range applied {
+			slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		if len(applied) == 0 {
+			slog.Info("Database schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %s", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := database.MigrateDown(ctx, db, steps)
+		for _, migration := range rolledBack {
+			slog.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("rollback failed: %w", err)
+		}
+	case "status":
+		states, err := database.GetMigrationStatus(ctx, db)
+		if err != nil {
+			return fmt.Errorf("reading migration status: %w", err)
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, state := range states {
+			status, appliedAt := "pending", ""
+			if state.AppliedAt != nil {
+				status = "applied"
+				appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05Z07:00")
+			}
+			if state.Modified {
+				status = "modified"
+			}
+			if state.Unknown {
+				status = "unknown"
+			}
+			fmt.Fprintf(out, "%04d\t%s\t%s\t%s\n", state.Version, state.Name, status, appliedAt)
+		}
+		return out.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down [steps] or status", command)
+	}
+	return nil
+}
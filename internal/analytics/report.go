@@ -0,0 +1,180 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"api-server/internal/models"
+)
+
+// Subject types for BuildReport
+const (
+	SubjectInstructor = "instructor"
+	SubjectCourse     = "course"
+)
+
+type questionKey struct {
+	term     string
+	position int
+	text     string
+}
+
+type aggregate struct {
+	stats models.QuestionTermStats
+}
+
+func (a *aggregate) add(row models.QuestionTermStats) {
+	if a.stats.Text == "" {
+		a.stats = row
+		return
+	}
+	a.stats.SectionCount += row.SectionCount
+	a.stats.ResponseCount += row.ResponseCount
+	a.stats.EnrolledCount += row.EnrolledCount
+	for i := range a.stats.Ratings {
+		a.stats.Ratings[i] += row.Ratings[i]
+	}
+	if row.TermStarted.Before(a.stats.TermStarted) {
+		a.stats.TermStarted = row.TermStarted
+	}
+}
+
+// BuildReport combines the view rows of a subject into per-term question analytics.
+// peers are the rows of every course in the subject's departments for the same terms;
+// they are grouped by instructor or course, depending on the subject type, to compute
+// department percentiles. Groups below minResponses are suppressed and never used as peers.
+func BuildReport(subjectType, subjectID string, rows, peers []models.QuestionTermStats, minResponses int) models.AnalyticsReport {
+	report := models.AnalyticsReport{
+		SubjectType:      subjectType,
+		SubjectID:        subjectID,
+		MinResponseCount: minResponses,
+		Terms:            []models.TermAnalytics{},
+	}
+
+	subject := map[questionKey]*aggregate{}
+	for _, row := range rows {
+		key := questionKey{row.SemesterTerm, row.Position, row.Text}
+		if subject[key] == nil {
+			subject[key] = &aggregate{}
+		}
+		subject[key].add(row)
+	}
+
+	peerMeans := peerMeansByQuestion(subjectType, peers, minResponses)
+
+	// order terms by their start date, terms starting on the same day by name
+	termStarted := map[string]int64{}
+	for key, agg := range subject {
+		started := agg.stats.TermStarted.Unix()
+		if current, ok := termStarted[key.term]; !ok || started < current {
+			termStarted[key.term] = started
+		}
+	}
+	terms := make([]string, 0, len(termStarted))
+	for term := range termStarted {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if termStarted[terms[i]] != termStarted[terms[j]] {
+			return termStarted[terms[i]] < termStarted[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+
+	previousMean := map[string]float64{}
+	for _, term := range terms {
+		termAnalytics := models.TermAnalytics{SemesterTerm: term, Questions: []models.QuestionAnalytics{}}
+		termMeans := map[string]float64{}
+		for key, agg := range subject {
+			if key.term != term {
+				continue
+			}
+			question := buildQuestion(agg.stats, minResponses)
+			// compare unrounded means so the subject ranks consistently against itself among its peers
+			if mean, ok := Mean(agg.stats.Ratings); ok && !question.Suppressed {
+				if prev, ok := previousMean[key.text]; ok {
+					question.DeltaFromPrevious = round(mean - prev)
+				}
+				if rank, ok := PercentileRank(mean, peerMeans[key]); ok {
+					question.DepartmentPercentile = round(rank)
+				}
+				termMeans[key.text] = mean
+			}
+			termAnalytics.Questions = append(termAnalytics.Questions, question)
+		}
+		sort.Slice(termAnalytics.Questions, func(i, j int) bool {
+			return termAnalytics.Questions[i].Position < termAnalytics.Questions[j].Position
+		})
+		for text, mean := range termMeans {
+			previousMean[text] = mean
+		}
+		report.Terms = append(report.Terms, termAnalytics)
+	}
+
+	return report
+}
+
+func buildQuestion(stats models.QuestionTermStats, minResponses int) models.QuestionAnalytics {
+	question := models.QuestionAnalytics{
+		Position:      stats.Position,
+		Category:      stats.Category,
+		Text:          stats.Text,
+		SectionCount:  stats.SectionCount,
+		ResponseCount: stats.ResponseCount,
+	}
+	if stats.ResponseCount < minResponses {
+		question.Suppressed = true
+		return question
+	}
+
+	if stats.EnrolledCount > 0 {
+		question.ResponseRate = round(float64(stats.ResponseCount) / float64(stats.EnrolledCount))
+	}
+	if mean, ok := Mean(stats.Ratings); ok {
+		question.Mean = round(mean)
+	}
+	if median, ok := Median(stats.Ratings); ok {
+		question.Median = &median
+	}
+	question.Distribution = make([]models.RatingCount, len(stats.Ratings))
+	for i, count := range stats.Ratings {
+		question.Distribution[i] = models.RatingCount{Rating: i + 1, Count: count}
+	}
+	return question
+}
+
+// peerMeansByQuestion groups peer rows by instructor or course and returns their means per question
+func peerMeansByQuestion(subjectType string, peers []models.QuestionTermStats, minResponses int) map[questionKey][]float64 {
+	type peerKey struct {
+		question questionKey
+		peer     string
+	}
+	grouped := map[peerKey]*aggregate{}
+	for _, row := range peers {
+		peer := row.CourseID
+		if subjectType == SubjectInstructor {
+			peer = row.InstructorID
+		}
+		key := peerKey{questionKey{row.SemesterTerm, row.Position, row.Text}, peer}
+		if grouped[key] == nil {
+			grouped[key] = &aggregate{}
+		}
+		grouped[key].add(row)
+	}
+
+	means := map[questionKey][]float64{}
+	for key, agg := range grouped {
+		if agg.stats.ResponseCount < minResponses {
+			continue
+		}
+		if mean, ok := Mean(agg.stats.Ratings); ok {
+			means[key.question] = append(means[key.question], mean)
+		}
+	}
+	return means
+}
+
+func round(value float64) *float64 {
+	rounded := math.Round(value*1000) / 1000
+	return &rounded
+}
@@ -0,0 +1,167 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"api-server/internal/models"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratings [5]int
+		want    float64
+		ok      bool
+	}{
+		{"no responses", [5]int{}, 0, false},
+		{"single response", [5]int{0, 0, 1, 0, 0}, 3, true},
+		{"odd count", [5]int{1, 1, 1, 0, 0}, 2, true},
+		{"even count between ratings", [5]int{0, 1, 0, 1, 0}, 3, true},
+		{"even count within a rating", [5]int{0, 0, 0, 4, 0}, 4, true},
+		{"tied halves", [5]int{2, 0, 0, 0, 2}, 3, true},
+		{"skewed", [5]int{0, 0, 1, 1, 5}, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Median(tt.ratings)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Median(%v) = %v, %v; want %v, %v", tt.ratings, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPercentileRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float64
+		peers []float64
+		want  float64
+		ok    bool
+	}{
+		{"no peers", 4, nil, 0, false},
+		{"single lower peer", 4, []float64{3}, 100, true},
+		{"single higher peer", 4, []float64{4.5}, 0, true},
+		{"single tied peer", 4, []float64{4}, 50, true},
+		{"ties count as half", 4, []float64{3, 4, 4, 5}, 50, true},
+		{"all below", 5, []float64{1, 2, 3, 4}, 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PercentileRank(tt.value, tt.peers)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("PercentileRank(%v, %v) = %v, %v; want %v, %v", tt.value, tt.peers, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	fall := time.Date(2025, 8, 25, 0, 0, 0, 0, time.UTC)
+	spring := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+	row := func(term string, started time.Time, instructorID string, ratings [5]int) models.QuestionTermStats {
+		responses := 0
+		for _, count := range ratings {
+			responses += count
+		}
+		return models.QuestionTermStats{
+			CourseID:      "course-" + instructorID,
+			InstructorID:  instructorID,
+			SemesterTerm:  term,
+			TermStarted:   started,
+			Position:      1,
+			Text:          "The course was well organized",
+			SectionCount:  1,
+			ResponseCount: responses,
+			EnrolledCount: 2 * responses,
+			Ratings:       ratings,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		rows, peers  []models.QuestionTermStats
+		minResponses int
+		check        func(t *testing.T, report models.AnalyticsReport)
+	}{
+		{
+			name:         "no rows",
+			minResponses: 5,
+			check: func(t *testing.T, report models.AnalyticsReport) {
+				if report.Terms == nil || len(report.Terms) != 0 {
+					t.Errorf("terms = %#v, want an empty list", report.Terms)
+				}
+			},
+		},
+		{
+			name:         "single row",
+			rows:         []models.QuestionTermStats{row("2026SP", spring, "ada", [5]int{0, 0, 0, 0, 5})},
+			minResponses: 5,
+			check: func(t *testing.T, report models.AnalyticsReport) {
+				question := report.Terms[0].Questions[0]
+				if question.Suppressed || *question.Mean != 5 || *question.Median != 5 || *question.ResponseRate != 0.5 {
+					t.Errorf("question = %+v, want mean and median 5 at a 50%% response rate", question)
+				}
+				if question.DeltaFromPrevious != nil || question.DepartmentPercentile != nil {
+					t.Errorf("question = %+v, want no delta and no percentile without history and peers", question)
+				}
+			},
+		},
+		{
+			name:         "below the minimum responses",
+			rows:         []models.QuestionTermStats{row("2026SP", spring, "ada", [5]int{0, 0, 0, 2, 2})},
+			minResponses: 5,
+			check: func(t *testing.T, report models.AnalyticsReport) {
+				question := report.Terms[0].Questions[0]
+				if !question.Suppressed || question.Mean != nil || question.Distribution != nil {
+					t.Errorf("question = %+v, want it suppressed without statistics", question)
+				}
+			},
+		},
+		{
+			name: "terms ordered by start date with a delta",
+			rows: []models.QuestionTermStats{
+				row("2026SP", spring, "ada", [5]int{0, 0, 0, 0, 5}),
+				row("2025FA", fall, "ada", [5]int{0, 0, 0, 5, 0}),
+			},
+			minResponses: 5,
+			check: func(t *testing.T, report models.AnalyticsReport) {
+				if len(report.Terms) != 2 || report.Terms[0].SemesterTerm != "2025FA" || report.Terms[1].SemesterTerm != "2026SP" {
+					t.Fatalf("terms = %+v, want 2025FA then 2026SP", report.Terms)
+				}
+				if delta := report.Terms[1].Questions[0].DeltaFromPrevious; delta == nil || *delta != 1 {
+					t.Errorf("delta = %v, want 1", delta)
+				}
+			},
+		},
+		{
+			name: "ties with peers and peers below the minimum",
+			rows: []models.QuestionTermStats{row("2026SP", spring, "ada", [5]int{0, 0, 0, 5, 0})},
+			peers: []models.QuestionTermStats{
+				row("2026SP", spring, "ada", [5]int{0, 0, 0, 5, 0}),
+				row("2026SP", spring, "grace", [5]int{0, 0, 0, 5, 0}),
+				row("2026SP", spring, "alan", [5]int{0, 0, 5, 0, 0}),
+				row("2026SP", spring, "barbara", [5]int{0, 0, 0, 0, 5}),
+				// suppressed, it would otherwise rank below the subject
+				row("2026SP", spring, "edsger", [5]int{1, 0, 0, 0, 0}),
+			},
+			minResponses: 5,
+			check: func(t *testing.T, report models.AnalyticsReport) {
+				// of four peers one is below and two are tied: (1 + 0.5*2) / 4
+				if percentile := report.Terms[0].Questions[0].DepartmentPercentile; percentile == nil || *percentile != 50 {
+					t.Errorf("percentile = %v, want 50", percentile)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := BuildReport(SubjectInstructor, "ada", tt.rows, tt.peers, tt.minResponses)
+			if report.SubjectType != SubjectInstructor || report.SubjectID != "ada" || report.MinResponseCount != tt.minResponses {
+				t.Errorf("report = %+v, want the subject and threshold echoed", report)
+			}
+			tt.check(t, report)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package analytics
+
+// Mean of a five point rating distribution where ratings[i] is the count for rating i+1
+func Mean(ratings [5]int) (float64, bool) {
+	total, weighted := 0, 0
+	for i, count := range ratings {
+		total += count
+		weighted += (i + 1) * count
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(weighted) / float64(total), true
+}
+
+// Median of a five point rating distribution
+func Median(ratings [5]int) (float64, bool) {
+	total := 0
+	for _, count := range ratings {
+		total += count
+	}
+	if total == 0 {
+		return 0, false
+	}
+	if total%2 == 1 {
+		return float64(nthRating(ratings, total/2+1)), true
+	}
+	lower := nthRating(ratings, total/2)
+	upper := nthRating(ratings, total/2+1)
+	return float64(lower+upper) / 2, true
+}
+
+// nthRating returns the rating of the n-th response (1-based) in sorted order
+func nthRating(ratings [5]int, n int) int {
+	seen := 0
+	for i, count := range ratings {
+		seen += count
+		if seen >= n {
+			return i + 1
+		}
+	}
+	return len(ratings)
+}
+
+// PercentileRank returns the percentage of peers scoring below value, counting ties as half
+func PercentileRank(value float64, peers []float64) (float64, bool) {
+	if len(peers) == 0 {
+		return 0, false
+	}
+	below, equal := 0, 0
+	for _, peer := range peers {
+		switch {
+		case peer < value:
+			below++
+		case peer == value:
+			equal++
+		}
+	}
+	return (float64(below) + 0.5*float64(equal)) / float64(len(peers)) * 100, true
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -11,7 +16,47 @@ type Config struct {
 	DBName     string
 	DBUser     string
 	DBPassword string
-	ServerPort string
+	// Where the password comes from: env (DB_PASSWORD), file (re-read for every new
+	// connection so rotated secrets are picked up) or iam (Cloud SQL IAM token)
+	DBPasswordSource string
+	DBPasswordFile   string
+	// TLS: sslmode and the PEM files lib/pq verifies the server and authenticates with
+	DBSSLMode     string
+	DBSSLRootCert string
+	DBSSLCert     string
+	DBSSLKey      string
+	// Connection pool limits
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	// Startup connection retries
+	DBConnectAttempts    int
+	DBConnectBackoffBase time.Duration
+	DBConnectBackoffMax  time.Duration
+	// Read replicas as host, host:port, [ipv6]:port or a full DSN overriding the settings above
+	DBReplicaHosts          []string
+	DBReplicaHealthInterval time.Duration
+	// How long a user's reads stay on the primary after they wrote
+	DBReadYourWritesWindow time.Duration
+	// Apply pending migrations on startup
+	DBAutoMigrate bool
+	// Longest a single SELECT, other statement or analytics refresh may run
+	DBReadTimeout    time.Duration
+	DBWriteTimeout   time.Duration
+	DBRefreshTimeout time.Duration
+	// Longest a single GCS call may run
+	StorageTimeout time.Duration
+	ServerPort     string
+	// HTTP server timeouts; reads and writes are long enough for large uploads
+	ServerReadHeaderTimeout time.Duration
+	ServerReadTimeout       time.Duration
+	ServerWriteTimeout      time.Duration
+	ServerIdleTimeout       time.Duration
+	// How long the server keeps serving after it reports not ready, so load balancers
+	// stop routing to it, and how long in-flight requests then have to finish
+	ShutdownDrainDelay time.Duration
+	ShutdownTimeout    time.Duration
 
 	// Kafka configuration
 	KafkaBrokers  []string
@@ -21,8 +66,87 @@ type Config struct {
 	KafkaAuth     bool
 
 	// OpenTelemetry configuration
-	ServiceName  string
-	OtlpEndpoint string
+	ServiceName           string
+	ServiceVersion        string
+	DeploymentEnvironment string
+	OtlpProtocol          string
+	OtlpEndpoint          string
+	OtlpHeaders           map[string]string
+	OtlpInsecure          *bool // nil when OTLP_INSECURE is unset
+	OtlpCACert            string
+	OtlpClientCert        string
+	OtlpClientKey         string
+
+	// Tracing configuration
+	TracingExporter       string
+	TracingFile           string
+	TracingSampleRatio    float64
+	TracingSamplerFromEnv bool
+	TracingPropagators    []string
+
+	// Logging configuration
+	LogLevel             slog.Level
+	LogRedactKeys        []string
+	LogRedactBucketPaths bool
+
+	// Metrics configuration
+	MetricsPrometheusEnabled bool
+	MetricsOTLPEnabled       bool
+	MetricsExportInterval    time.Duration
+
+	// Upload validation configuration
+	UploadAllowedTypes []string
+	UploadMaxPDFPages  int
+	ClamAVAddress      string
+	QuarantinePrefix   string
+
+	// Batch upload configuration
+	BatchUploadConcurrency int
+	BatchUploadMaxFiles    int
+	BatchUploadMaxBytes    int64
+
+	// Resumable upload configuration
+	ResumableUploadMaxBytes  int64
+	ResumableChunkMaxBytes   int64
+	ResumableUploadTTL       time.Duration
+	ResumableCleanupInterval time.Duration
+
+	// Shared secret for service-to-service routes
+	InternalAPIToken string
+
+	// Report worker configuration
+	ReportPollInterval time.Duration
+	ReportJobLease     time.Duration
+
+	// Analytics configuration
+	AnalyticsMinResponses int
+
+	// OpenAPI validation configuration
+	OpenAPIValidateRequests  bool
+	OpenAPIValidateResponses bool
+
+	// Idempotency-Key configuration
+	IdempotencyKeyTTL          time.Duration
+	IdempotencyLease           time.Duration
+	IdempotencyCleanupInterval time.Duration
+
+	// Webhook delivery configuration
+	WebhookTimeout              time.Duration
+	WebhookPollInterval         time.Duration
+	WebhookMaxAttempts          int
+	WebhookBackoffBase          time.Duration
+	WebhookBackoffMax           time.Duration
+	WebhookDisableAfterFailures int
+	WebhookAllowInsecure        bool
+
+	// Event stream configuration
+	EventStreamReplayBuffer      int
+	EventStreamHeartbeatInterval time.Duration
+	EventStreamClientBuffer      int
+
+	// Readiness check configuration
+	HealthCheckCacheTTL time.Duration
+	HealthCheckTimeout  time.Duration
 }
 
 func Load() (*Config, error) {
@@ -36,13 +160,123 @@ func Load() (*Config, error) {
 	// Enable auth if both username and password are provided
 	kafkaAuth := kafkaUsername != "" && kafkaPassword != ""
 
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error: %w", err)
+	}
+
+	// the standard OTEL_* variables are the defaults of the server's own
+	otlpProtocol := getEnv("OTLP_PROTOCOL", getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc"))
+	if otlpProtocol != "grpc" && otlpProtocol != "http/protobuf" {
+		return nil, fmt.Errorf("OTLP_PROTOCOL must be grpc or http/protobuf, got %q", otlpProtocol)
+	}
+	otlpHeaders, err := parseHeaders(getEnv("OTLP_HEADERS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("OTLP_HEADERS: %w", err)
+	}
+	// unset leaves plain text or TLS to OTEL_EXPORTER_OTLP_INSECURE and the endpoint scheme
+	var otlpInsecure *bool
+	if value := getEnv("OTLP_INSECURE", ""); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("OTLP_INSECURE must be true or false, got %q", value)
+		}
+		otlpInsecure = &parsed
+	}
+	otlpClientCert := getEnv("OTLP_CLIENT_CERT", "")
+	otlpClientKey := getEnv("OTLP_CLIENT_KEY", "")
+	if (otlpClientCert == "") != (otlpClientKey == "") {
+		return nil, fmt.Errorf("OTLP_CLIENT_CERT and OTLP_CLIENT_KEY must be set together")
+	}
+
+	defaultExporter := getEnv("OTEL_TRACES_EXPORTER", "otlp")
+	if getEnvBool("OTEL_SDK_DISABLED", false) {
+		defaultExporter = "none"
+	}
+	tracingExporter := getEnv("TRACING_EXPORTER", defaultExporter)
+	tracingFile := getEnv("TRACING_FILE", "")
+	switch tracingExporter {
+	case "otlp", "stdout", "none":
+	case "console":
+		tracingExporter = "stdout"
+	case "file":
+		if tracingFile == "" {
+			return nil, fmt.Errorf("TRACING_FILE is required when TRACING_EXPORTER is file")
+		}
+	default:
+		return nil, fmt.Errorf("TRACING_EXPORTER must be otlp, stdout, file or none, got %q", tracingExporter)
+	}
+	tracingSampleRatio := getEnvFloat("TRACING_SAMPLE_RATIO", 1)
+	if tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", tracingSampleRatio)
+	}
+	tracingPropagators := getEnvList("OTEL_PROPAGATORS")
+	if len(tracingPropagators) == 0 {
+		tracingPropagators = []string{"tracecontext", "baggage"}
+	}
+	for _, propagator := range tracingPropagators {
+		if propagator != "tracecontext" && propagator != "baggage" && propagator != "none" {
+			return nil, fmt.Errorf("OTEL_PROPAGATORS may only contain tracecontext, baggage or none, got %q", propagator)
+		}
+	}
+
+	dbPasswordSource := getEnv("DB_PASSWORD_SOURCE", "env")
+	dbPasswordFile := getEnv("DB_PASSWORD_FILE", "")
+	switch dbPasswordSource {
+	case "env", "iam":
+	case "file":
+		if dbPasswordFile == "" {
+			return nil, fmt.Errorf("DB_PASSWORD_FILE is required when DB_PASSWORD_SOURCE is file")
+		}
+	default:
+		return nil, fmt.Errorf("DB_PASSWORD_SOURCE must be env, file or iam, got %q", dbPasswordSource)
+	}
+
+	idempotencyLease := getEnvDuration("IDEMPOTENCY_LEASE", 30*time.Second)
+	if idempotencyLease <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_LEASE must be positive, got %v", idempotencyLease)
+	}
+
 	return &Config{
-		DBHost:     getEnv("DB_HOST", ""),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBName:     getEnv("DB_NAME", ""),
-		DBUser:     getEnv("DB_USER", ""),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
+		DBHost:        getEnv("DB_HOST", ""),
+		DBPort:        getEnv("DB_PORT", "5432"),
+		DBName:        getEnv("DB_NAME", ""),
+		DBUser:        getEnv("DB_USER", ""),
+		DBPassword:    getEnv("DB_PASSWORD", ""),
+		DBAutoMigrate: getEnvBool("DB_AUTO_MIGRATE", false),
+
+		DBPasswordSource: dbPasswordSource,
+		DBPasswordFile:   dbPasswordFile,
+		DBSSLMode:        getEnv("DB_SSLMODE", "disable"),
+		DBSSLRootCert:    getEnv("DB_SSLROOTCERT", ""),
+		DBSSLCert:        getEnv("DB_SSLCERT", ""),
+		DBSSLKey:         getEnv("DB_SSLKEY", ""),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+		DBConnectAttempts:    getEnvInt("DB_CONNECT_ATTEMPTS", 10),
+		DBConnectBackoffBase: getEnvDuration("DB_CONNECT_BACKOFF_BASE", time.Second),
+		DBConnectBackoffMax:  getEnvDuration("DB_CONNECT_BACKOFF_MAX", 30*time.Second),
+
+		DBReplicaHosts:          getEnvList("DB_REPLICA_HOSTS"),
+		DBReplicaHealthInterval: getEnvDuration("DB_REPLICA_HEALTH_INTERVAL", 5*time.Second),
+		DBReadYourWritesWindow:  getEnvDuration("DB_READ_YOUR_WRITES_WINDOW", 5*time.Second),
+
+		DBReadTimeout:    getEnvDuration("DB_READ_TIMEOUT", 5*time.Second),
+		DBWriteTimeout:   getEnvDuration("DB_WRITE_TIMEOUT", 10*time.Second),
+		DBRefreshTimeout: getEnvDuration("DB_REFRESH_TIMEOUT", 5*time.Minute),
+		StorageTimeout:   getEnvDuration("STORAGE_TIMEOUT", time.Minute),
+		ServerPort:       getEnv("SERVER_PORT", "8080"),
+
+		ServerReadHeaderTimeout: getEnvDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second),
+		ServerReadTimeout:       getEnvDuration("SERVER_READ_TIMEOUT", 10*time.Minute),
+		ServerWriteTimeout:      getEnvDuration("SERVER_WRITE_TIMEOUT", 10*time.Minute),
+		ServerIdleTimeout:       getEnvDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownDrainDelay:      getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		ShutdownTimeout:         getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
 
 		// Kafka fields
 		KafkaBrokers:  kafkaBrokers,
@@ -52,8 +286,79 @@ func Load() (*Config, error) {
 		KafkaAuth:     kafkaAuth,
 
 		// OpenTelemetry fields
-		ServiceName:  getEnv("SERVICE_NAME", "api-server"),
-		OtlpEndpoint: getEnv("OTLP_ENDPOINT", "localhost:4317"),
+		ServiceName:           getEnv("SERVICE_NAME", "api-server"),
+		ServiceVersion:        getEnv("SERVICE_VERSION", ""),
+		DeploymentEnvironment: getEnv("DEPLOYMENT_ENVIRONMENT", ""),
+		OtlpProtocol:          otlpProtocol,
+		OtlpEndpoint:          getEnv("OTLP_ENDPOINT", ""),
+		OtlpHeaders:           otlpHeaders,
+		OtlpInsecure:          otlpInsecure,
+		OtlpCACert:            getEnv("OTLP_CA_CERT", ""),
+		OtlpClientCert:        otlpClientCert,
+		OtlpClientKey:         otlpClientKey,
+
+		// Tracing fields
+		TracingExporter:       tracingExporter,
+		TracingFile:           tracingFile,
+		TracingSampleRatio:    tracingSampleRatio,
+		TracingSamplerFromEnv: os.Getenv("TRACING_SAMPLE_RATIO") == "" && os.Getenv("OTEL_TRACES_SAMPLER") != "",
+		TracingPropagators:    tracingPropagators,
+
+		// Logging fields
+		LogLevel:             logLevel,
+		LogRedactKeys:        strings.Split(getEnv("LOG_REDACT_KEYS", "password,authorization,token,secret,cookie"), ","),
+		LogRedactBucketPaths: getEnvBool("LOG_REDACT_BUCKET_PATHS", true),
+
+		// Metrics fields
+		MetricsPrometheusEnabled: getEnvBool("METRICS_PROMETHEUS_ENABLED", true),
+		MetricsOTLPEnabled:       getEnvBool("METRICS_OTLP_ENABLED", true),
+		MetricsExportInterval:    getEnvDuration("METRICS_EXPORT_INTERVAL", 60*time.Second),
+
+		// Upload validation fields
+		UploadAllowedTypes: strings.Split(getEnv("UPLOAD_ALLOWED_TYPES", "application/pdf"), ","),
+		UploadMaxPDFPages:  getEnvInt("UPLOAD_MAX_PDF_PAGES", 50),
+		ClamAVAddress:      getEnv("CLAMAV_ADDRESS", ""),
+		QuarantinePrefix:   getEnv("QUARANTINE_PREFIX", "quarantine"),
+
+		// Batch upload fields
+		BatchUploadConcurrency: getEnvInt("BATCH_UPLOAD_CONCURRENCY", 4),
+		BatchUploadMaxFiles:    getEnvInt("BATCH_UPLOAD_MAX_FILES", 100),
+		BatchUploadMaxBytes:    int64(getEnvInt("BATCH_UPLOAD_MAX_MB", 200)) << 20,
+
+		// Resumable upload fields
+		ResumableUploadMaxBytes:  int64(getEnvInt("RESUMABLE_UPLOAD_MAX_MB", 500)) << 20,
+		ResumableChunkMaxBytes:   int64(getEnvInt("RESUMABLE_CHUNK_MAX_MB", 16)) << 20,
+		ResumableUploadTTL:       getEnvDuration("RESUMABLE_UPLOAD_TTL", 24*time.Hour),
+		ResumableCleanupInterval: getEnvDuration("RESUMABLE_CLEANUP_INTERVAL", 15*time.Minute),
+
+		InternalAPIToken: getEnv("INTERNAL_API_TOKEN", ""),
+
+		ReportPollInterval: getEnvDuration("REPORT_POLL_INTERVAL", 5*time.Second),
+		ReportJobLease:     getEnvDuration("REPORT_JOB_LEASE", 5*time.Minute),
+
+		AnalyticsMinResponses: getEnvInt("ANALYTICS_MIN_RESPONSES", 5),
+
+		OpenAPIValidateRequests:  getEnvBool("OPENAPI_VALIDATE_REQUESTS", true),
+		OpenAPIValidateResponses: getEnvBool("OPENAPI_VALIDATE_RESPONSES", false),
+
+		IdempotencyKeyTTL:          getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		IdempotencyLease:           idempotencyLease,
+		IdempotencyCleanupInterval: getEnvDuration("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour),
+
+		WebhookTimeout:              getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookPollInterval:         getEnvDuration("WEBHOOK_POLL_INTERVAL", 10*time.Second),
+		WebhookMaxAttempts:          getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookBackoffBase:          getEnvDuration("WEBHOOK_BACKOFF_BASE", 30*time.Second),
+		WebhookBackoffMax:           getEnvDuration("WEBHOOK_BACKOFF_MAX", time.Hour),
+		WebhookDisableAfterFailures: getEnvInt("WEBHOOK_DISABLE_AFTER_FAILURES", 20),
+		WebhookAllowInsecure:        getEnvBool("WEBHOOK_ALLOW_INSECURE", getEnv("DEPLOYMENT_ENVIRONMENT", "") == "development"),
+
+		EventStreamReplayBuffer:      getEnvInt("EVENT_STREAM_REPLAY_BUFFER", 1000),
+		EventStreamHeartbeatInterval: getEnvDuration("EVENT_STREAM_HEARTBEAT_INTERVAL", 15*time.Second),
+		EventStreamClientBuffer:      getEnvInt("EVENT_STREAM_CLIENT_BUFFER", 64),
+
+		HealthCheckCacheTTL: getEnvDuration("HEALTH_CHECK_CACHE_TTL", 5*time.Second),
+		HealthCheckTimeout:  getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
 	}, nil
 }
 
@@ -63,3 +368,72 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList splits a comma separated variable, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// parseHeaders parses "key=value,key=value" with URL-encoded values, the format of
+// OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, encoded, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"api-server/internal/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// cloudSQLLoginScope is the OAuth scope of tokens Cloud SQL accepts as IAM database passwords
+const cloudSQLLoginScope = "https://www.googleapis.com/auth/sqlservice.login"
+
+// passwordSource returns the database password to open a new connection with
+type passwordSource func(ctx context.Context) (string, error)
+
+func newPasswordSource(cfg *config.Config) passwordSource {
+	switch cfg.DBPasswordSource {
+	case "file":
+		return filePassword(cfg.DBPasswordFile)
+	case "iam":
+		return iamPassword()
+	default:
+		return func(context.Context) (string, error) {
+			return cfg.DBPassword, nil
+		}
+	}
+}
+
+// filePassword reads the password from a file, e.g. a mounted Kubernetes secret,
+// every time so a rotated secret is used without a restart
+func filePassword(path string) passwordSource {
+	return func(context.Context) (string, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		password := strings.TrimRight(string(content), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("password file %s is empty", path)
+		}
+		return password, nil
+	}
+}
+
+// iamPassword uses an OAuth token of the application default credentials as the
+// password. Tokens are cached and refreshed shortly before they expire.
+func iamPassword() passwordSource {
+	var (
+		lock   sync.Mutex
+		tokens oauth2.TokenSource
+	)
+	return func(context.Context) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if tokens == nil {
+			// the source outlives the connection attempt, so it refreshes without its context
+			source, err := google.DefaultTokenSource(context.Background(), cloudSQLLoginScope)
+			if err != nil {
+				return "", err
+			}
+			tokens = source
+		}
+		token, err := tokens.Token()
+		if err != nil {
+			return "", err
+		}
+		return token.AccessToken, nil
+	}
+}
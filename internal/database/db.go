@@ -1,35 +1,215 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
 	"api-server/internal/config"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var db *sql.DB
 
-func InitDB(cfg *config.Config) (*sql.DB, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
-	)
+// ConnectionString returns the lib/pq connection string of the configured database,
+// with the password currently held by its password source
+func ConnectionString(ctx context.Context, cfg *config.Config) (string, error) {
+	password, err := newPasswordSource(cfg)(ctx)
+	if err != nil {
+		return "", err
+	}
+	return connectionString(cfg, cfg.DBHost, cfg.DBPort, password), nil
+}
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
+func connectionString(cfg *config.Config, host, port, password string) string {
+	params := []string{
+		"host=" + quoteParam(host),
+		"port=" + quoteParam(port),
+		"user=" + quoteParam(cfg.DBUser),
+		"password=" + quoteParam(password),
+		"dbname=" + quoteParam(cfg.DBName),
+		"sslmode=" + quoteParam(cfg.DBSSLMode),
+	}
+	if cfg.DBSSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteParam(cfg.DBSSLRootCert))
+	}
+	if cfg.DBSSLCert != "" {
+		params = append(params, "sslcert="+quoteParam(cfg.DBSSLCert))
+	}
+	if cfg.DBSSLKey != "" {
+		params = append(params, "sslkey="+quoteParam(cfg.DBSSLKey))
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteParam quotes a connection string value so passwords may contain spaces and quotes
+func quoteParam(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// connector opens every connection with the password its source holds at that moment,
+// so connections replaced after DB_CONN_MAX_LIFETIME pick up a rotated password. The
+// parameters of dsn, if any, override those of the primary.
+type connector struct {
+	cfg      *config.Config
+	host     string
+	port     string
+	dsn      string
+	password passwordSource
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.password(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching database password: %w", err)
+	}
+	params := connectionString(c.cfg, c.host, c.port, password)
+	if c.dsn != "" {
+		params += " " + c.dsn
+	}
+	conn, err := pq.NewConnector(params)
 	if err != nil {
 		return nil, err
 	}
+	return conn.Connect(ctx)
+}
 
-	if err = db.Ping(); err != nil {
-		return nil, err
+func (c *connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// InitDB opens the connection pool and waits for the database to accept connections,
+// retrying with backoff so the server can start before the database does
+func InitDB(cfg *config.Config) (*sql.DB, error) {
+	pool := openPool(cfg, cfg.DBHost, cfg.DBPort, "")
+
+	attempts := max(cfg.DBConnectAttempts, 1)
+	wait := cfg.DBConnectBackoffBase
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = pool.PingContext(ctx)
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt == attempts {
+			pool.Close()
+			return nil, fmt.Errorf("database not reachable after %d attempts: %w", attempts, err)
+		}
+		slog.Warn("Database not reachable, retrying", "attempt", attempt, "attempts", attempts, "retry_in", wait.String(), "error", err)
+		time.Sleep(wait)
+		wait = min(wait*2, cfg.DBConnectBackoffMax)
 	}
 
+	if err := registerPoolMetrics(pool, "primary"); err != nil {
+		slog.Error("Error registering database pool metrics", "error", err)
+	}
+	db = pool
 	return db, nil
 }
 
+// InitReplicas opens a connection pool per read replica, keyed by its host and port.
+// Replicas are not waited for, reads fall back to the primary while they are unreachable.
+func InitReplicas(cfg *config.Config) (map[string]*sql.DB, error) {
+	pools := map[string]*sql.DB{}
+	for _, entry := range cfg.DBReplicaHosts {
+		target, err := parseReplica(entry, cfg.DBPort)
+		if err != nil {
+			for _, pool := range pools {
+				pool.Close()
+			}
+			return nil, err
+		}
+		pool := openPool(cfg, target.host, target.port, target.dsn)
+		if err := registerPoolMetrics(pool, "replica "+target.name()); err != nil {
+			slog.Error("Error registering database pool metrics", "error", err)
+		}
+		pools[target.name()] = pool
+	}
+	return pools, nil
+}
+
+// replicaTarget is where a DB_REPLICA_HOSTS entry points, with the entry itself as
+// connection parameters when it is a full DSN
+type replicaTarget struct {
+	host string
+	port string
+	dsn  string
+}
+
+// name identifies the replica in logs and metrics without the credentials of its DSN
+func (t replicaTarget) name() string {
+	return net.JoinHostPort(t.host, t.port)
+}
+
+// parseReplica reads a replica given as host, host:port, [ipv6]:port, a postgres:// URL
+// or a key=value connection string. Parts a DSN leaves out are taken from the primary.
+func parseReplica(entry, defaultPort string) (replicaTarget, error) {
+	switch {
+	case strings.HasPrefix(entry, "postgres://"), strings.HasPrefix(entry, "postgresql://"):
+		u, err := url.Parse(entry)
+		if err != nil {
+			return replicaTarget{}, fmt.Errorf("invalid replica URL: %w", err)
+		}
+		dsn, err := pq.ParseURL(entry)
+		if err != nil {
+			return replicaTarget{}, fmt.Errorf("invalid replica URL %s: %w", u.Redacted(), err)
+		}
+		target := replicaTarget{host: u.Hostname(), port: u.Port(), dsn: dsn}
+		if target.port == "" {
+			target.port = defaultPort
+		}
+		if target.host == "" {
+			return replicaTarget{}, fmt.Errorf("replica URL %s has no host", u.Redacted())
+		}
+		return target, nil
+	case strings.Contains(entry, "="):
+		target := replicaTarget{port: defaultPort, dsn: entry}
+		for _, param := range strings.Fields(entry) {
+			key, value, _ := strings.Cut(param, "=")
+			value = strings.Trim(value, "'")
+			switch key {
+			case "host":
+				target.host = value
+			case "port":
+				target.port = value
+			}
+		}
+		if target.host == "" {
+			return replicaTarget{}, fmt.Errorf("replica connection string has no host")
+		}
+		return target, nil
+	}
+	host, port, err := net.SplitHostPort(entry)
+	if err != nil {
+		// No port, a bare IPv6 address included
+		host, port = strings.TrimSuffix(strings.TrimPrefix(entry, "["), "]"), defaultPort
+	}
+	if host == "" {
+		return replicaTarget{}, fmt.Errorf("invalid replica address %q", entry)
+	}
+	return replicaTarget{host: host, port: port}, nil
+}
+
+func openPool(cfg *config.Config, host, port, dsn string) *sql.DB {
+	pool := sql.OpenDB(&connector{cfg: cfg, host: host, port: port, dsn: dsn, password: newPasswordSource(cfg)})
+	pool.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	pool.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	pool.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	pool.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	return pool
+}
+
 func GetDB() *sql.DB {
 	return db
 }
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// registerPoolMetrics reports the statistics of a connection pool on every collection
+// of the global meter provider
+func registerPoolMetrics(pool *sql.DB, name string) error {
+	meter := otel.Meter("api-server/database")
+	poolName := attribute.String("pool.name", name)
+
+	usage, err := meter.Int64ObservableUpDownCounter("db.client.connections.usage",
+		metric.WithDescription("Connections in the pool by state"),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+	maxOpen, err := meter.Int64ObservableUpDownCounter("db.client.connections.max",
+		metric.WithDescription("Maximum number of open connections allowed"),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+	waits, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		metric.WithDescription("Times a statement waited for a free connection"),
+		metric.WithUnit("{wait}"))
+	if err != nil {
+		return err
+	}
+	waitTime, err := meter.Float64ObservableCounter("db.client.connections.wait_time",
+		metric.WithDescription("Total time spent waiting for a free connection"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	closed, err := meter.Int64ObservableCounter("db.client.connections.closed",
+		metric.WithDescription("Connections closed by the pool by reason"),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := pool.Stats()
+		o.ObserveInt64(usage, int64(stats.Idle), metric.WithAttributes(poolName, attribute.String("state", "idle")))
+		o.ObserveInt64(usage, int64(stats.InUse), metric.WithAttributes(poolName, attribute.String("state", "used")))
+		o.ObserveInt64(maxOpen, int64(stats.MaxOpenConnections), metric.WithAttributes(poolName))
+		o.ObserveInt64(waits, stats.WaitCount, metric.WithAttributes(poolName))
+		o.ObserveFloat64(waitTime, stats.WaitDuration.Seconds(), metric.WithAttributes(poolName))
+		o.ObserveInt64(closed, stats.MaxIdleClosed, metric.WithAttributes(poolName, attribute.String("reason", "max_idle")))
+		o.ObserveInt64(closed, stats.MaxIdleTimeClosed, metric.WithAttributes(poolName, attribute.String("reason", "max_idle_time")))
+		o.ObserveInt64(closed, stats.MaxLifetimeClosed, metric.WithAttributes(poolName, attribute.String("reason", "max_lifetime")))
+		return nil
+	}, usage, maxOpen, waits, waitTime, closed)
+	return err
+}
@@ -0,0 +1,8 @@
+DROP TABLE api.health_checks;
+DROP TABLE api.traces;
+DROP TABLE api.courses;
+DROP TABLE api.instructors;
+DROP TABLE api.semester_terms;
+DROP TABLE api.departments;
+DROP TABLE api.users;
+DROP SCHEMA api;
@@ -0,0 +1,64 @@
+CREATE SCHEMA IF NOT EXISTS api;
+
+CREATE TABLE api.users (
+    user_id         uuid PRIMARY KEY,
+    first_name      text NOT NULL,
+    last_name       text NOT NULL,
+    username        text NOT NULL UNIQUE,
+    password        text NOT NULL,
+    account_created timestamptz NOT NULL,
+    account_updated timestamptz NOT NULL
+);
+
+CREATE TABLE api.departments (
+    department_id integer PRIMARY KEY,
+    name          text NOT NULL
+);
+
+CREATE TABLE api.semester_terms (
+    semester_term text PRIMARY KEY,
+    name          text NOT NULL,
+    start_date    date NOT NULL
+);
+
+CREATE TABLE api.instructors (
+    instructor_id uuid PRIMARY KEY,
+    user_id       uuid NOT NULL REFERENCES api.users (user_id),
+    name          text NOT NULL,
+    date_created  timestamptz NOT NULL
+);
+
+CREATE TABLE api.courses (
+    course_id         uuid PRIMARY KEY,
+    date_added        timestamptz NOT NULL,
+    date_last_updated timestamptz NOT NULL,
+    user_id           uuid NOT NULL REFERENCES api.users (user_id),
+    code              varchar(15) NOT NULL,
+    name              varchar(100) NOT NULL,
+    description       varchar(500) NOT NULL DEFAULT '',
+    instructor_id     uuid NOT NULL REFERENCES api.instructors (instructor_id),
+    department_id     integer NOT NULL REFERENCES api.departments (department_id),
+    credit_hours      integer NOT NULL CHECK (credit_hours BETWEEN 0 AND 4)
+);
+
+CREATE INDEX courses_department_id_idx ON api.courses (department_id);
+
+CREATE TABLE api.traces (
+    trace_id      uuid PRIMARY KEY,
+    user_id       uuid NOT NULL REFERENCES api.users (user_id),
+    file_name     text NOT NULL,
+    date_created  timestamptz NOT NULL,
+    bucket_path   text NOT NULL,
+    course_id     uuid NOT NULL REFERENCES api.courses (course_id),
+    instructor_id uuid NOT NULL REFERENCES api.instructors (instructor_id),
+    semester_term text NOT NULL REFERENCES api.semester_terms (semester_term),
+    section       text NOT NULL
+);
+
+CREATE INDEX traces_course_id_idx ON api.traces (course_id);
+CREATE INDEX traces_instructor_id_semester_term_idx ON api.traces (instructor_id, semester_term);
+
+CREATE TABLE api.health_checks (
+    check_id   bigserial PRIMARY KEY,
+    checked_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
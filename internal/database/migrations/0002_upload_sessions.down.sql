@@ -0,0 +1,2 @@
+DROP TABLE api.upload_chunks;
+DROP TABLE api.upload_sessions;
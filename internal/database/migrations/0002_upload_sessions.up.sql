@@ -0,0 +1,28 @@
+CREATE TABLE api.upload_sessions (
+    upload_id     uuid PRIMARY KEY,
+    user_id       uuid NOT NULL REFERENCES api.users (user_id),
+    course_id     uuid NOT NULL REFERENCES api.courses (course_id) ON DELETE CASCADE,
+    instructor_id uuid NOT NULL,
+    semester_term text NOT NULL,
+    section       text NOT NULL,
+    file_name     text NOT NULL,
+    upload_length bigint NOT NULL CHECK (upload_length > 0),
+    upload_offset bigint NOT NULL DEFAULT 0 CHECK (upload_offset >= 0),
+    status        text NOT NULL,
+    trace_id      uuid REFERENCES api.traces (trace_id) ON DELETE SET NULL,
+    error         text,
+    date_created  timestamptz NOT NULL,
+    expires_at    timestamptz NOT NULL,
+    date_finished timestamptz
+);
+
+CREATE INDEX upload_sessions_status_expires_at_idx ON api.upload_sessions (status, expires_at);
+
+CREATE TABLE api.upload_chunks (
+    upload_id    uuid NOT NULL REFERENCES api.upload_sessions (upload_id) ON DELETE CASCADE,
+    chunk_offset bigint NOT NULL,
+    chunk_size   bigint NOT NULL,
+    bucket_path  text NOT NULL,
+    checksum     text NOT NULL DEFAULT '',
+    PRIMARY KEY (upload_id, chunk_offset)
+);
@@ -0,0 +1 @@
+DROP TABLE api.trace_versions;
@@ -0,0 +1,9 @@
+CREATE TABLE api.trace_versions (
+    trace_id       uuid NOT NULL REFERENCES api.traces (trace_id) ON DELETE CASCADE,
+    version_number integer NOT NULL CHECK (version_number > 0),
+    file_name      text NOT NULL,
+    bucket_path    text NOT NULL,
+    user_id        uuid NOT NULL REFERENCES api.users (user_id),
+    date_created   timestamptz NOT NULL,
+    PRIMARY KEY (trace_id, version_number)
+);
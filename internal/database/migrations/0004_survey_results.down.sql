@@ -0,0 +1,4 @@
+DROP TABLE api.survey_comments;
+DROP TABLE api.survey_rating_counts;
+DROP TABLE api.survey_questions;
+DROP TABLE api.survey_results;
@@ -0,0 +1,33 @@
+CREATE TABLE api.survey_results (
+    trace_id          uuid PRIMARY KEY REFERENCES api.traces (trace_id) ON DELETE CASCADE,
+    response_count    integer NOT NULL CHECK (response_count >= 0),
+    enrolled_count    integer NOT NULL CHECK (enrolled_count >= 0),
+    processor_version text NOT NULL DEFAULT '',
+    date_processed    timestamptz NOT NULL
+);
+
+CREATE TABLE api.survey_questions (
+    question_id    uuid PRIMARY KEY,
+    trace_id       uuid NOT NULL REFERENCES api.survey_results (trace_id) ON DELETE CASCADE,
+    position       integer NOT NULL,
+    category       text NOT NULL DEFAULT '',
+    text           text NOT NULL,
+    response_count integer NOT NULL CHECK (response_count >= 0),
+    UNIQUE (trace_id, position)
+);
+
+CREATE TABLE api.survey_rating_counts (
+    question_id uuid NOT NULL REFERENCES api.survey_questions (question_id) ON DELETE CASCADE,
+    rating      integer NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    count       integer NOT NULL CHECK (count >= 0),
+    PRIMARY KEY (question_id, rating)
+);
+
+CREATE TABLE api.survey_comments (
+    comment_id uuid PRIMARY KEY,
+    trace_id   uuid NOT NULL REFERENCES api.survey_results (trace_id) ON DELETE CASCADE,
+    question   text NOT NULL DEFAULT '',
+    text       text NOT NULL
+);
+
+CREATE INDEX survey_comments_trace_id_idx ON api.survey_comments (trace_id);
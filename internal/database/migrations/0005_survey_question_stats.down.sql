@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW api.survey_question_stats;
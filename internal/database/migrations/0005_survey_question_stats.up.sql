@@ -0,0 +1,42 @@
+-- The combined rating distribution of each question per course, instructor and term,
+-- refreshed concurrently by the analytics refresher, which needs the unique index
+CREATE MATERIALIZED VIEW api.survey_question_stats AS
+SELECT
+    t.course_id,
+    c.department_id,
+    t.instructor_id,
+    t.semester_term,
+    st.start_date AS term_started,
+    q.position,
+    q.category,
+    q.text,
+    COUNT(DISTINCT t.trace_id)::integer AS section_count,
+    SUM(q.response_count)::integer AS response_count,
+    SUM(sr.enrolled_count)::integer AS enrolled_count,
+    COALESCE(SUM(r.rating_1), 0)::integer AS rating_1,
+    COALESCE(SUM(r.rating_2), 0)::integer AS rating_2,
+    COALESCE(SUM(r.rating_3), 0)::integer AS rating_3,
+    COALESCE(SUM(r.rating_4), 0)::integer AS rating_4,
+    COALESCE(SUM(r.rating_5), 0)::integer AS rating_5
+FROM api.survey_questions q
+JOIN api.survey_results sr ON sr.trace_id = q.trace_id
+JOIN api.traces t ON t.trace_id = q.trace_id
+JOIN api.courses c ON c.course_id = t.course_id
+JOIN api.semester_terms st ON st.semester_term = t.semester_term
+-- one row per question, so the sums above are not multiplied by the number of ratings
+CROSS JOIN LATERAL (
+    SELECT
+        SUM(rc.count) FILTER (WHERE rc.rating = 1) AS rating_1,
+        SUM(rc.count) FILTER (WHERE rc.rating = 2) AS rating_2,
+        SUM(rc.count) FILTER (WHERE rc.rating = 3) AS rating_3,
+        SUM(rc.count) FILTER (WHERE rc.rating = 4) AS rating_4,
+        SUM(rc.count) FILTER (WHERE rc.rating = 5) AS rating_5
+    FROM api.survey_rating_counts rc
+    WHERE rc.question_id = q.question_id
+) r
+GROUP BY t.course_id, c.department_id, t.instructor_id, t.semester_term, st.start_date, q.position, q.category, q.text;
+
+CREATE UNIQUE INDEX survey_question_stats_key_idx
+    ON api.survey_question_stats (course_id, instructor_id, semester_term, position, category, text);
+CREATE INDEX survey_question_stats_instructor_id_idx ON api.survey_question_stats (instructor_id);
+CREATE INDEX survey_question_stats_department_id_semester_term_idx ON api.survey_question_stats (department_id, semester_term);
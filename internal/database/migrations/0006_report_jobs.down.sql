@@ -0,0 +1,2 @@
+DROP TABLE api.report_files;
+DROP TABLE api.report_jobs;
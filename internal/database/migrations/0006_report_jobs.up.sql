@@ -0,0 +1,24 @@
+CREATE TABLE api.report_jobs (
+    job_id        uuid PRIMARY KEY,
+    department_id integer NOT NULL REFERENCES api.departments (department_id),
+    semester_term text NOT NULL REFERENCES api.semester_terms (semester_term),
+    user_id       uuid NOT NULL REFERENCES api.users (user_id),
+    status        text NOT NULL,
+    total         integer NOT NULL DEFAULT 0,
+    completed     integer NOT NULL DEFAULT 0,
+    failed        integer NOT NULL DEFAULT 0,
+    error         text,
+    date_created  timestamptz NOT NULL,
+    date_finished timestamptz
+);
+
+CREATE INDEX report_jobs_status_idx ON api.report_jobs (status);
+
+CREATE TABLE api.report_files (
+    job_id        uuid NOT NULL REFERENCES api.report_jobs (job_id) ON DELETE CASCADE,
+    instructor_id uuid NOT NULL,
+    bucket_path   text NOT NULL DEFAULT '',
+    error         text,
+    date_created  timestamptz NOT NULL,
+    PRIMARY KEY (job_id, instructor_id)
+);
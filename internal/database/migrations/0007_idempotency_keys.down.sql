@@ -0,0 +1 @@
+DROP TABLE api.idempotency_keys;
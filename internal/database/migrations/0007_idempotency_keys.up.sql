@@ -0,0 +1,17 @@
+-- user_id is empty for public routes, so it does not reference api.users
+CREATE TABLE api.idempotency_keys (
+    user_id          text NOT NULL,
+    idempotency_key  text NOT NULL,
+    fingerprint      text NOT NULL,
+    method           text NOT NULL,
+    path             text NOT NULL,
+    status           text NOT NULL,
+    response_status  integer,
+    response_headers jsonb,
+    response_body    bytea,
+    date_created     timestamptz NOT NULL,
+    expires_at       timestamptz NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key, fingerprint)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON api.idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+DROP TABLE api.webhook_deliveries;
+DROP TABLE api.webhook_subscriptions;
@@ -0,0 +1,35 @@
+CREATE TABLE api.webhook_subscriptions (
+    webhook_id      uuid PRIMARY KEY,
+    user_id         uuid NOT NULL REFERENCES api.users (user_id) ON DELETE CASCADE,
+    url             varchar(2048) NOT NULL,
+    secret          text NOT NULL,
+    event_types     text[] NOT NULL,
+    department_id   integer REFERENCES api.departments (department_id),
+    description     varchar(200) NOT NULL DEFAULT '',
+    active          boolean NOT NULL DEFAULT true,
+    failure_count   integer NOT NULL DEFAULT 0,
+    disabled_reason text,
+    date_created    timestamptz NOT NULL,
+    date_updated    timestamptz NOT NULL
+);
+
+CREATE INDEX webhook_subscriptions_user_id_idx ON api.webhook_subscriptions (user_id);
+CREATE INDEX webhook_subscriptions_event_types_idx ON api.webhook_subscriptions USING gin (event_types) WHERE active;
+
+CREATE TABLE api.webhook_deliveries (
+    delivery_id      uuid PRIMARY KEY,
+    webhook_id       uuid NOT NULL REFERENCES api.webhook_subscriptions (webhook_id) ON DELETE CASCADE,
+    event_id         uuid NOT NULL,
+    event_type       text NOT NULL,
+    payload          bytea NOT NULL,
+    status           text NOT NULL,
+    attempts         integer NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz,
+    last_status_code integer,
+    last_error       text,
+    date_created     timestamptz NOT NULL,
+    date_delivered   timestamptz
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON api.webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_id_date_created_idx ON api.webhook_deliveries (webhook_id, date_created DESC);
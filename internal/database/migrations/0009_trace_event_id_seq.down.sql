@@ -0,0 +1 @@
+DROP SEQUENCE api.trace_event_id_seq;
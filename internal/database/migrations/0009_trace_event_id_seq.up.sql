@@ -0,0 +1,2 @@
+-- numbers the trace events sent with NOTIFY so every replica resumes streams from the same IDs
+CREATE SEQUENCE api.trace_event_id_seq;
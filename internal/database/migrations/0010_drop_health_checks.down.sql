@@ -0,0 +1,4 @@
+CREATE TABLE api.health_checks (
+    check_id   bigserial PRIMARY KEY,
+    checked_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- probes no longer write a row each, see GET /readyz
+DROP TABLE api.health_checks;
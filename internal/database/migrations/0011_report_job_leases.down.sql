@@ -0,0 +1 @@
+ALTER TABLE api.report_jobs DROP COLUMN lease_until;
@@ -0,0 +1,3 @@
+-- report workers claim jobs from the table and hold them until lease_until, so a job is run
+-- by one replica at a time and taken over when that replica dies
+ALTER TABLE api.report_jobs ADD COLUMN lease_until timestamptz;
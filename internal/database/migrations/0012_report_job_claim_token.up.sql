@@ -0,0 +1,3 @@
+-- every claim of a report job gets a new token, and a worker only writes to the job while
+-- the token is still its own, so a worker whose lease lapsed cannot undo its successor's work
+ALTER TABLE api.report_jobs ADD COLUMN claim_token uuid;
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"api-server/internal/analytics"
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/services"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+// InstructorAnalyticsHandler handles GET /v1/instructor/{instructor_id}/analytics
+func (h *Handler) InstructorAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	instructorID := validators.ExtractInstructorID(r.URL.Path)
+	if err := validators.ValidateInstructorID(instructorID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.instructors.GetInstructorByID(r.Context(), instructorID); err != nil {
+		respondWithRepositoryError(w, r, err, "instructor")
+		return
+	}
+
+	rows, err := h.analytics.GetQuestionStatsByInstructor(r.Context(), instructorID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error retrieving instructor analytics", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
+		return
+	}
+	h.respondWithAnalytics(w, r, analytics.SubjectInstructor, instructorID, rows)
+}
+
+// CourseAnalyticsHandler handles GET /v1/course/{course_id}/analytics
+func (h *Handler) CourseAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	courseID := extractCourseID(r.URL.Path)
+	if _, err := uuid.Parse(courseID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return
+	}
+
+	rows, err := h.analytics.GetQuestionStatsByCourse(r.Context(), courseID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error retrieving course analytics", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
+		return
+	}
+	h.respondWithAnalytics(w, r, analytics.SubjectCourse, courseID, rows)
+}
+
+// respondWithAnalytics loads the department peers of the rows and writes the report
+func (h *Handler) respondWithAnalytics(w http.ResponseWriter, r *http.Request, subjectType, subjectID string, rows []models.QuestionTermStats) {
+	departments := []int{}
+	terms := []string{}
+	seenDepartments := map[int]bool{}
+	seenTerms := map[string]bool{}
+	for _, row := range rows {
+		if !seenDepartments[row.DepartmentID] {
+			seenDepartments[row.DepartmentID] = true
+			departments = append(departments, row.DepartmentID)
+		}
+		if !seenTerms[row.SemesterTerm] {
+			seenTerms[row.SemesterTerm] = true
+			terms = append(terms, row.SemesterTerm)
+		}
+	}
+
+	peers := []models.QuestionTermStats{}
+	if len(rows) > 0 {
+		var err error
+		peers, err = h.analytics.GetDepartmentQuestionStats(r.Context(), departments, terms)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error retrieving department analytics", "error", err)
+			respondWithError(w, r, http.StatusServiceUnavailable, "")
+			return
+		}
+	}
+
+	report := analytics.BuildReport(subjectType, subjectID, rows, peers, services.GetAnalyticsMinResponses())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,18 +1,18 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
-	"errors"
-	"log"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
-	"api-server/internal/database"
+	"api-server/internal/kafka"
+	"api-server/internal/logging"
 	"api-server/internal/middleware"
 	"api-server/internal/models"
-	"api-server/internal/repositories"
+	"api-server/internal/services"
 	"api-server/internal/validators"
 
 	"github.com/google/uuid"
@@ -26,66 +26,67 @@ func extractCourseID(path string) string {
 	return parts[3]
 }
 
-func CourseHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CourseHandler(w http.ResponseWriter, r *http.Request) {
 	courseID := extractCourseID(r.URL.Path)
 	if courseID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	if _, err := uuid.Parse(courseID); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid UUID format")
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodPut:
-		UpdateCourseHandler(w, r, courseID)
+		h.UpdateCourseHandler(w, r, courseID)
 	case http.MethodPatch:
-		PatchCourseHandler(w, r, courseID)
+		h.PatchCourseHandler(w, r, courseID)
 	case http.MethodDelete:
-		DeleteCourseHandler(w, r, courseID)
+		h.DeleteCourseHandler(w, r, courseID)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 	}
 }
 
-func CreateCourseHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateCourseHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 
 	var courseReq models.CourseRequest
-	if r.Body != nil {
-		_ = json.NewDecoder(r.Body).Decode(&courseReq)
+	if err := json.NewDecoder(r.Body).Decode(&courseReq); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
 	}
 	// add course request validation from validators
 	if err := validators.ValidateCourseRequest(courseReq); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithValidationError(w, r, err)
 		return
 	}
 
 	// Get the user from the context
 	user := middleware.GetUserFromContext(r)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 	userID := user.UserID
-	if _, err := repositories.GetInstructorByID(database.GetDB(), courseReq.InstructorID); err != nil {
-		log.Printf("Error fetching instructor: %v", err)
-		http.Error(w, "failed to get instructor", http.StatusBadRequest)
+	if _, err := h.instructors.GetInstructorByID(r.Context(), courseReq.InstructorID); err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching instructor", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to get instructor")
 		return
 	}
 
-	if _, err := repositories.GetDepartmentByID(database.GetDB(), courseReq.DepartmentID); err != nil {
-		log.Printf("Error fetching department: %v", err)
-		http.Error(w, "failed to get department", http.StatusBadRequest)
+	if _, err := h.reference.GetDepartmentByID(r.Context(), courseReq.DepartmentID); err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching department", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to get department")
 		return
 	}
 	// TODO: add check for course code -> unique
@@ -104,14 +105,13 @@ func CreateCourseHandler(w http.ResponseWriter, r *http.Request) {
 		CreditHours:     courseReq.CreditHours,
 	}
 
-	log.Printf("Course: %v", course)
 	// Create the course
-	newCourse, err := repositories.CreateCourse(database.GetDB(), course)
+	newCourse, err := h.courses.CreateCourse(r.Context(), course)
 	if err != nil {
-		log.Printf("Error creating course: %v", err)
-		http.Error(w, "failed to create course", http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
+	h.publishCourseEvent(r.Context(), kafka.EventCourseCreated, newCourse, userID)
 	// return 201 Created with course JSON
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -119,20 +119,15 @@ func CreateCourseHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Get course by ID
-func GetCourseHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetCourseHandler(w http.ResponseWriter, r *http.Request) {
 	courseID := extractCourseID(r.URL.Path)
 	if courseID == "" {
-		http.Error(w, "invalid course ID", http.StatusBadRequest)
-		return
-	}
-	course, err := repositories.GetCourseByID(database.GetDB(), courseID)
-	if errors.Is(err, sql.ErrNoRows) {
-		http.Error(w, "course not found", http.StatusNotFound)
+		respondWithError(w, r, http.StatusBadRequest, "invalid course ID")
 		return
 	}
+	course, err := h.courses.GetCourseByID(r.Context(), courseID)
 	if err != nil {
-		log.Printf("Error fetching course: %v", err)
-		http.Error(w, "failed to get course", http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
 	// return 200 OK with course JSON
@@ -141,24 +136,23 @@ func GetCourseHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetAllCoursesHandler handles GET /v1/courses
-func GetAllCoursesHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllCoursesHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	db := database.GetDB()
-	courses, err := repositories.GetAllCourses(db)
+	courses, err := h.courses.GetAllCourses(r.Context())
 	if err != nil {
-		log.Printf("Error retrieving courses: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		logging.FromContext(r.Context()).Error("Error retrieving courses", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
 		return
 	}
 
@@ -167,127 +161,103 @@ func GetAllCoursesHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Update (PUT)	/v1/course/{course_id}
-func UpdateCourseHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+func (h *Handler) UpdateCourseHandler(w http.ResponseWriter, r *http.Request, courseID string) {
 	if courseID == "" {
-		respondWithError(w, http.StatusBadRequest, "invalid course ID")
+		respondWithError(w, r, http.StatusBadRequest, "invalid course ID")
 		return
 	}
 
 	// Do not allow query parameters.
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 
-	// Decode request body.
+	// Decode request body, PUT replaces every field so it is validated like POST.
 	var req models.CourseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
-
-	// Getting existing values
-	db := database.GetDB()
-	existingCourse, err := repositories.GetCourseByID(db, courseID)
-	if err != nil {
-		log.Printf("Error fetching course: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "failed to fetch course")
+	if err := validators.ValidateCourseRequest(req); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
-	// update course request validation from validators
-	if err := validators.ValidateCourseUpdateRequest(req); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-	// check if the instructor exists, if instructor_id is provided
-
-	if req.InstructorID != "" {
-		if _, err := repositories.GetInstructorByID(db, req.InstructorID); err != nil {
-			log.Printf("Error fetching instructor: %v", err)
-			respondWithError(w, http.StatusBadRequest, "instructor not found")
-			return
-		}
-	}
-	// check if the department exists
-	if req.DepartmentID != 0 {
-		if _, err := repositories.GetDepartmentByID(db, req.DepartmentID); err != nil {
-			log.Printf("Error fetching department: %v", err)
-			respondWithError(w, http.StatusBadRequest, "department not found")
-			return
-		}
-	}
-	// Create the course model
-	course := models.Course{
-		CourseID:        existingCourse.CourseID,
-		DateAdded:       existingCourse.DateAdded,
-		UserID:          existingCourse.UserID,
-		DateLastUpdated: time.Now().UTC(),
-		Code:            getValueOrDefault(req.Code, existingCourse.Code).(string), // Type assertion
-		Name:            getValueOrDefault(req.Name, existingCourse.Name).(string),
-		Description:     getValueOrDefault(req.Description, existingCourse.Description).(string),
-		InstructorID:    getValueOrDefault(req.InstructorID, existingCourse.InstructorID).(string),
-		DepartmentID:    getValueOrDefault(req.DepartmentID, existingCourse.DepartmentID).(int),
-		CreditHours:     getValueOrDefault(req.CreditHours, existingCourse.CreditHours).(int), // Type assertion for int
-	}
 
-	if err := repositories.UpdateCourse(db, &course); err != nil {
-		log.Printf("Error updating course: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	existingCourse, err := h.courses.GetCourseByID(r.Context(), courseID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(course)
+	h.saveCourseUpdate(w, r, existingCourse, req)
 }
 
 // Patch (PATCH)	/v1/course/{course_id}
-func PatchCourseHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+func (h *Handler) PatchCourseHandler(w http.ResponseWriter, r *http.Request, courseID string) {
 	if courseID == "" {
-		respondWithError(w, http.StatusBadRequest, "invalid course ID")
+		respondWithError(w, r, http.StatusBadRequest, "invalid course ID")
 		return
 	}
 
 	// Do not allow query parameters.
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 
-	// Decode request body.
-	var req models.CourseRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+	// Decode request body, only the fields present are validated and changed.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validators.ValidateCoursePatchFields(fields); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	// Getting existing values
-	db := database.GetDB()
-	existingCourse, err := repositories.GetCourseByID(db, courseID)
+	existingCourse, err := h.courses.GetCourseByID(r.Context(), courseID)
 	if err != nil {
-		log.Printf("Error fetching course: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "failed to fetch course")
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
-	// update course request validation from validators
-	if err := validators.ValidateCourseUpdateRequest(req); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+
+	// Apply the patch onto the current values
+	req := models.CourseRequest{
+		Code:         existingCourse.Code,
+		Name:         existingCourse.Name,
+		Description:  existingCourse.Description,
+		InstructorID: existingCourse.InstructorID,
+		DepartmentID: existingCourse.DepartmentID,
+		CreditHours:  existingCourse.CreditHours,
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	// check if the instructor exists, if instructor_id is provided
+	h.saveCourseUpdate(w, r, existingCourse, req)
+}
 
-	if req.InstructorID != "" {
-		if _, err := repositories.GetInstructorByID(db, req.InstructorID); err != nil {
-			log.Printf("Error fetching instructor: %v", err)
-			respondWithError(w, http.StatusBadRequest, "instructor not found")
+// saveCourseUpdate checks the references of a validated course request and stores it over the existing course
+func (h *Handler) saveCourseUpdate(w http.ResponseWriter, r *http.Request, existingCourse *models.Course, req models.CourseRequest) {
+	// check if the instructor exists, if it changed
+	if req.InstructorID != existingCourse.InstructorID {
+		if _, err := h.instructors.GetInstructorByID(r.Context(), req.InstructorID); err != nil {
+			logging.FromContext(r.Context()).Error("Error fetching instructor", "error", err)
+			respondWithError(w, r, http.StatusBadRequest, "instructor not found")
 			return
 		}
 	}
-	// check if the department exists
-	if req.DepartmentID != 0 {
-		if _, err := repositories.GetDepartmentByID(db, req.DepartmentID); err != nil {
-			log.Printf("Error fetching department: %v", err)
-			respondWithError(w, http.StatusBadRequest, "department not found")
+	// check if the department exists, if it changed
+	if req.DepartmentID != existingCourse.DepartmentID {
+		if _, err := h.reference.GetDepartmentByID(r.Context(), req.DepartmentID); err != nil {
+			logging.FromContext(r.Context()).Error("Error fetching department", "error", err)
+			respondWithError(w, r, http.StatusBadRequest, "department not found")
 			return
 		}
 	}
@@ -295,22 +265,22 @@ func PatchCourseHandler(w http.ResponseWriter, r *http.Request, courseID string)
 	// Create the course model
 	course := models.Course{
 		CourseID:        existingCourse.CourseID,
-		DateLastUpdated: time.Now().UTC(),
 		DateAdded:       existingCourse.DateAdded,
 		UserID:          existingCourse.UserID,
-		Code:            getValueOrDefault(req.Code, existingCourse.Code).(string), // Type assertion
-		Name:            getValueOrDefault(req.Name, existingCourse.Name).(string),
-		Description:     getValueOrDefault(req.Description, existingCourse.Description).(string),
-		InstructorID:    getValueOrDefault(req.InstructorID, existingCourse.InstructorID).(string),
-		DepartmentID:    getValueOrDefault(req.DepartmentID, existingCourse.DepartmentID).(int),
-		CreditHours:     getValueOrDefault(req.CreditHours, existingCourse.CreditHours).(int), // Type assertion for int
+		DateLastUpdated: time.Now().UTC(),
+		Code:            req.Code,
+		Name:            req.Name,
+		Description:     req.Description,
+		InstructorID:    req.InstructorID,
+		DepartmentID:    req.DepartmentID,
+		CreditHours:     req.CreditHours,
 	}
 
-	if err := repositories.UpdateCourse(db, &course); err != nil {
-		log.Printf("Error updating course: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if err := h.courses.UpdateCourse(r.Context(), &course); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
+	h.publishCourseEvent(r.Context(), kafka.EventCourseUpdated, course, currentUserID(r))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -318,34 +288,46 @@ func PatchCourseHandler(w http.ResponseWriter, r *http.Request, courseID string)
 }
 
 // Delete (DELETE)	/v1/course/{course_id}
-func DeleteCourseHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+func (h *Handler) DeleteCourseHandler(w http.ResponseWriter, r *http.Request, courseID string) {
 	if courseID == "" {
-		respondWithError(w, http.StatusBadRequest, "invalid course ID")
+		respondWithError(w, r, http.StatusBadRequest, "invalid course ID")
 		return
 	}
 
-	db := database.GetDB()
-	err := repositories.DeleteCourse(db, courseID)
+	// fetched first so the deleted course can be described in the event
+	course, err := h.courses.GetCourseByID(r.Context(), courseID)
 	if err != nil {
-		log.Printf("Error deleting course: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
+	if err := h.courses.DeleteCourse(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return
+	}
+	h.publishCourseEvent(r.Context(), kafka.EventCourseDeleted, *course, currentUserID(r))
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Helper function to return the value from the request or the existing value if the request field is empty
-func getValueOrDefault(newValue, defaultValue interface{}) interface{} {
-	switch v := newValue.(type) {
-	case string:
-		if v != "" {
-			return v
-		}
-	case int:
-		if v > 0 {
-			return v
-		}
+// publishCourseEvent sends a course event to Kafka and to the webhooks subscribed to it
+func (h *Handler) publishCourseEvent(ctx context.Context, eventType string, course models.Course, userID string) {
+	services.PublishCourseEvent(ctx, h.store, kafka.CourseMessage{
+		EventType:    eventType,
+		CourseID:     course.CourseID,
+		Code:         course.Code,
+		Name:         course.Name,
+		InstructorID: course.InstructorID,
+		DepartmentID: course.DepartmentID,
+		CreditHours:  course.CreditHours,
+		UpdatedBy:    userID,
+		UpdatedAt:    time.Now().UTC(),
+	})
+}
+
+// currentUserID returns the ID of the authenticated user, or an empty string
+func currentUserID(r *http.Request) string {
+	if user := middleware.GetUserFromContext(r); user != nil {
+		return user.UserID
 	}
-	return defaultValue
+	return ""
 }
@@ -2,33 +2,30 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
-	"api-server/internal/database"
-	"api-server/internal/repositories"
+	"api-server/internal/logging"
 	"api-server/internal/validators"
 )
 
 // GetAllDepartmentsHandler handles GET /v1/departments
-func GetAllDepartmentsHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllDepartmentsHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	db := database.GetDB()
-	departments, err := repositories.GetAllDepartments(db)
+	departments, err := h.reference.GetAllDepartments(r.Context())
 	if err != nil {
-		log.Printf("Error retrieving departments: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		logging.FromContext(r.Context()).Error("Error retrieving departments", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
 		return
 	}
 
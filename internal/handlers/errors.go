@@ -0,0 +1,13 @@
+package handlers
+
+import "net/http"
+
+// NotFoundHandler answers requests that match no route
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, r, http.StatusNotFound, "no route matches "+r.URL.Path)
+}
+
+// MethodNotAllowedHandler answers requests whose path matches a route but not its methods
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, r, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+	"api-server/internal/services"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+// eventStreamRetry is the reconnection delay suggested to EventSource clients
+const eventStreamRetry = 3 * time.Second
+
+// TraceEventsHandler handles GET /v1/events?course_id=..., a Server-Sent Events stream of
+// traces being created, deleted and processed. It covers the traces of the courses the
+// caller created or teaches, or those of one of them. Reconnecting clients send
+// Last-Event-ID to get the events they missed.
+func (h *Handler) TraceEventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := validators.ValidateReportParameters(query, "course_id"); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	access := &courseAccess{h: h, userID: user.UserID, allowed: map[string]bool{}}
+	courseID := query.Get("course_id")
+	if courseID != "" {
+		if _, err := uuid.Parse(courseID); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+			return
+		}
+		course, err := h.courses.GetCourseByID(r.Context(), courseID)
+		if err != nil {
+			respondWithRepositoryError(w, r, err, "course")
+			return
+		}
+		// courses of other users are reported as missing, like webhooks of other users
+		allowed, err := access.allowsCourse(r.Context(), course)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error checking course access", "course_id", courseID, "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "failed to get course")
+			return
+		}
+		if !allowed {
+			respondWithError(w, r, http.StatusNotFound, "course not found")
+			return
+		}
+	}
+	var lastEventID int64
+	header := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	resume := header != ""
+	if resume {
+		var err error
+		lastEventID, err = strconv.ParseInt(header, 10, 64)
+		if err != nil || lastEventID < 0 {
+			respondWithError(w, r, http.StatusBadRequest, "Last-Event-ID must be an event ID from this stream")
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	// streams outlive the server's write timeout, heartbeats detect dead connections instead
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logging.FromContext(r.Context()).Error("Error lifting write deadline of event stream", "error", err)
+	}
+
+	subscription, replay, missed := services.SubscribeTraceEvents(courseID, lastEventID, resume)
+	defer services.UnsubscribeTraceEvents(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keep reverse proxies such as nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	if missed {
+		writeTraceEvent(w, models.TraceStreamEvent{Type: models.TraceStreamReset, OccurredAt: time.Now().UTC()})
+	}
+	for _, event := range replay {
+		if access.allows(r.Context(), event) {
+			writeTraceEvent(w, event)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(services.GetTraceEventHeartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if !access.allows(r.Context(), event) {
+				continue
+			}
+			if err := writeTraceEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// courseAccess decides which trace events a stream may carry: those of courses the user
+// created or whose instructor they created. Answers are remembered for the life of the stream.
+type courseAccess struct {
+	h       *Handler
+	userID  string
+	allowed map[string]bool
+}
+
+// allows reports whether the event may be sent. Events without a course, such as resets,
+// are sent to everyone; events of courses that cannot be looked up are dropped.
+func (a *courseAccess) allows(ctx context.Context, event models.TraceStreamEvent) bool {
+	if event.CourseID == "" {
+		return true
+	}
+	if allowed, ok := a.allowed[event.CourseID]; ok {
+		return allowed
+	}
+	course, err := a.h.courses.GetCourseByID(ctx, event.CourseID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			a.allowed[event.CourseID] = false
+		} else {
+			logging.FromContext(ctx).Error("Error checking course access", "course_id", event.CourseID, "error", err)
+		}
+		return false
+	}
+	allowed, err := a.allowsCourse(ctx, course)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error checking course access", "course_id", event.CourseID, "error", err)
+		return false
+	}
+	return allowed
+}
+
+// allowsCourse reports whether the user created the course or its instructor
+func (a *courseAccess) allowsCourse(ctx context.Context, course *models.Course) (bool, error) {
+	allowed := course.UserID == a.userID
+	if !allowed && course.InstructorID != "" {
+		instructor, err := a.h.instructors.GetInstructorByID(ctx, course.InstructorID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return false, err
+		}
+		allowed = err == nil && instructor.UserID == a.userID
+	}
+	a.allowed[course.CourseID] = allowed
+	return allowed, nil
+}
+
+// writeTraceEvent writes one event in the text/event-stream format
+func writeTraceEvent(w http.ResponseWriter, event models.TraceStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.Type == models.TraceStreamReset {
+		data = []byte("{}")
+	}
+	if event.ID > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
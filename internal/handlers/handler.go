@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"api-server/internal/repositories"
+)
+
+// Handler serves the routes backed by the stores. The stores are passed in so the
+// handlers can run against an in-memory store.
+type Handler struct {
+	users         repositories.UserStore
+	courses       repositories.CourseStore
+	traces        repositories.TraceStore
+	instructors   repositories.InstructorStore
+	reference     repositories.ReferenceStore
+	surveyResults repositories.SurveyResultStore
+	analytics     repositories.AnalyticsStore
+	uploads       repositories.UploadStore
+	reports       repositories.ReportStore
+	webhooks      repositories.WebhookStore
+	// store runs units of work whose writes must commit together
+	store repositories.Store
+}
+
+// NewHandler returns a Handler reading and writing through store
+func NewHandler(store repositories.Store) *Handler {
+	return &Handler{
+		users:         store,
+		courses:       store,
+		traces:        store,
+		instructors:   store,
+		reference:     store,
+		surveyResults: store,
+		analytics:     store,
+		uploads:       store,
+		reports:       store,
+		webhooks:      store,
+		store:         store,
+	}
+}
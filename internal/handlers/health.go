@@ -1,56 +1,72 @@
 package handlers
 
 import (
-	"api-server/internal/database"
-	"api-server/internal/repositories"
-	"log"
+	"encoding/json"
 	"net/http"
+
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/services"
 )
 
-func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow GET method
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+// LivenessHandler handles GET /livez. It only reports that the process serves requests,
+// so a failing dependency never gets the server restarted.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	if !validateProbe(w, r) {
 		return
 	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
 
-	// Check for query parameters in the URL
-	if len(r.URL.Query()) > 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Bad Request: Parameters are not allowed"))
+// ReadinessHandler handles GET /readyz with the outcome of every dependency check
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if !validateProbe(w, r) {
 		return
 	}
-
-	// Check for payload (body content)
-	if r.ContentLength > 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Bad Request: Body is not allowed"))
-		return
+	report := services.CheckReadiness(r.Context())
+	status := http.StatusOK
+	if report.Status == models.HealthUnavailable {
+		for name, check := range report.Checks {
+			if check.Status == models.HealthUnavailable && check.Error != nil {
+				logging.FromContext(r.Context()).Warn("Readiness check failed", "check", name, "error", *check.Error)
+			}
+		}
+		status = http.StatusServiceUnavailable
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
 
-	// Set headers
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Set("Pragma", "no-cache")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-
-	// Attempt to connect to DB
-	db := database.GetDB()
-	if db == nil {
-		log.Printf("Health check failed: Database connection unavailable")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte("Service Unavailable"))
+// HealthCheckHandler handles GET /healthz, kept for probes configured before /readyz.
+// It runs the readiness checks and answers OK unless a critical one fails.
+func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	if !validateProbe(w, r) {
 		return
 	}
-
-	// Use the repository to insert a health check record
-	if err := repositories.InsertHealthCheck(db); err != nil {
-		log.Printf("Health check failed: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte("Service Unavailable"))
+	if services.CheckReadiness(r.Context()).Status == models.HealthUnavailable {
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
 		return
 	}
-
-	// Return 200 OK if everything works fine
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
+
+// validateProbe rejects probes with parameters or a body and disables caching of the answer
+func validateProbe(w http.ResponseWriter, r *http.Request) bool {
+	if len(r.URL.Query()) > 0 {
+		respondWithError(w, r, http.StatusBadRequest, "parameters are not allowed")
+		return false
+	}
+	if r.ContentLength > 0 {
+		respondWithError(w, r, http.StatusBadRequest, "body is not allowed")
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return true
+}
@@ -1,77 +1,74 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"log"
 	"net/http"
 	"time"
 
-	"api-server/internal/database"
+	"api-server/internal/logging"
 	"api-server/internal/middleware"
 	"api-server/internal/models"
-	"api-server/internal/repositories"
 	"api-server/internal/validators"
 
 	"github.com/google/uuid"
 )
 
-func InstructorHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) InstructorHandler(w http.ResponseWriter, r *http.Request) {
 	instructorID := validators.ExtractInstructorID(r.URL.Path)
 	if instructorID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	if err := validators.ValidateInstructorID(instructorID); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		GetInstructorHandler(w, r, instructorID)
+		h.GetInstructorHandler(w, r, instructorID)
 	case http.MethodPut:
-		UpdateInstructorHandler(w, r, instructorID)
+		h.UpdateInstructorHandler(w, r, instructorID)
 	case http.MethodPatch:
-		PatchInstructorHandler(w, r, instructorID)
+		h.PatchInstructorHandler(w, r, instructorID)
 	case http.MethodDelete:
-		DeleteInstructorHandler(w, r, instructorID)
+		h.DeleteInstructorHandler(w, r, instructorID)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 	}
 }
 
 // CreateInstructorHandler handles POST /v1/instructor.
-func CreateInstructorHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateInstructorHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Decode request body.
 	var req models.InstructorRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	// Validate name.
-	if err := validators.ValidateInstructorName(req.Name); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	if err := validators.ValidateInstructorRequest(req); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
 
 	// Get authenticated user from context.
 	authUser := middleware.GetUserFromContext(r)
 	if authUser == nil {
-		respondWithError(w, http.StatusUnauthorized, "unauthorized")
+		respondWithError(w, r, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
@@ -83,11 +80,9 @@ func CreateInstructorHandler(w http.ResponseWriter, r *http.Request) {
 		DateCreated:  time.Now().UTC(),
 	}
 
-	db := database.GetDB()
-	createdInstructor, err := repositories.CreateInstructor(db, instructor)
+	createdInstructor, err := h.instructors.CreateInstructor(r.Context(), instructor)
 	if err != nil {
-		log.Printf("Error creating instructor: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 
@@ -97,46 +92,39 @@ func CreateInstructorHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateInstructorHandler handles PUT /v1/instructor/{instructor_id}.
-func UpdateInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
+func (h *Handler) UpdateInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if instructorID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	var req models.InstructorRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.ContentLength == 0 {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	if err := validators.ValidateInstructorName(req.Name); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	if err := validators.ValidateInstructorRequest(req); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	db := database.GetDB()
-	instructor, err := repositories.GetInstructorByID(db, instructorID)
+	instructor, err := h.instructors.GetInstructorByID(r.Context(), instructorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "instructor not found")
-			return
-		}
-		log.Printf("Error retrieving instructor: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 
 	// Update only the name.
 	instructor.Name = req.Name
-	if err := repositories.UpdateInstructor(db, instructor); err != nil {
-		log.Printf("Error updating instructor: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+	if err := h.instructors.UpdateInstructor(r.Context(), instructor); err != nil {
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 
@@ -144,79 +132,66 @@ func UpdateInstructorHandler(w http.ResponseWriter, r *http.Request, instructorI
 }
 
 // PatchInstructorHandler handles PATCH /v1/instructor/{instructor_id}.
-func PatchInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
+func (h *Handler) PatchInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if instructorID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	var req map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.ContentLength == 0 {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	// Validate patch fields (name)
 	if err := validators.ValidateInstructorPatchFields(req); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	db := database.GetDB()
-	instructor, err := repositories.GetInstructorByID(db, instructorID)
+	instructor, err := h.instructors.GetInstructorByID(r.Context(), instructorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "instructor not found")
-			return
-		}
-		log.Printf("Error retrieving instructor: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 
 	// Update the name from validated request
 	instructor.Name = req["name"].(string)
-	if err := repositories.UpdateInstructor(db, instructor); err != nil {
-		log.Printf("Error patching instructor: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+	if err := h.instructors.UpdateInstructor(r.Context(), instructor); err != nil {
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // DeleteInstructorHandler handles DELETE /v1/instructor/{instructor_id}.
-func DeleteInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
+func (h *Handler) DeleteInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if instructorID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
-	db := database.GetDB()
-	if err := repositories.DeleteInstructor(db, instructorID); err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "instructor not found")
-			return
-		}
-		log.Printf("Error deleting instructor: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+	if err := h.instructors.DeleteInstructor(r.Context(), instructorID); err != nil {
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 
@@ -224,33 +199,27 @@ func DeleteInstructorHandler(w http.ResponseWriter, r *http.Request, instructorI
 }
 
 // GetInstructorHandler handles GET /v1/instructor/{instructor_id}.
-func GetInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
+func (h *Handler) GetInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID string) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if instructorID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
-	db := database.GetDB()
-	instructor, err := repositories.GetInstructorByID(db, instructorID)
+	instructor, err := h.instructors.GetInstructorByID(r.Context(), instructorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "instructor not found")
-			return
-		}
-		log.Printf("Error retrieving instructor: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		respondWithRepositoryError(w, r, err, "instructor")
 		return
 	}
 
@@ -259,24 +228,23 @@ func GetInstructorHandler(w http.ResponseWriter, r *http.Request, instructorID s
 }
 
 // GetAllInstructorsHandler handles GET /v1/instructors
-func GetAllInstructorsHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllInstructorsHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	db := database.GetDB()
-	instructors, err := repositories.GetAllInstructors(db)
+	instructors, err := h.instructors.GetAllInstructors(r.Context())
 	if err != nil {
-		log.Printf("Error retrieving instructors: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		logging.FromContext(r.Context()).Error("Error retrieving instructors", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
 		return
 	}
 
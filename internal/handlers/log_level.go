@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/validators"
+)
+
+// LogLevelHandler handles GET and PUT /internal/v1/log-level, so debug logging can be
+// turned on in a running server without a restart. The level is per replica.
+func LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req models.LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		level, err := logging.ParseLevel(req.Level)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "level must be debug, info, warn or error")
+			return
+		}
+		previous := logging.Level()
+		logging.SetLevel(level)
+		logging.FromContext(r.Context()).Warn("Log level changed", "from", previous.String(), "to", level.String())
+	default:
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LogLevel{Level: strings.ToLower(logging.Level().String())})
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	tracing "api-server/internal/observability"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("api-server/handlers")
+
+// instrument names are fixed, so creating them cannot fail
+var uploadSize, _ = meter.Int64Histogram("api.upload.size",
+	metric.WithDescription("Size of uploaded trace files"),
+	metric.WithUnit("By"),
+	metric.WithExplicitBucketBoundaries(1<<10, 16<<10, 128<<10, 512<<10, 1<<20, 4<<20, 16<<20, 64<<20, 256<<20))
+
+// recordUploadSize records an uploaded file's size with whether it was stored, rejected
+// by validation, found infected or failed to be stored
+func recordUploadSize(ctx context.Context, size int, outcome string) {
+	uploadSize.Record(ctx, int64(size), metric.WithAttributes(attribute.String("outcome", outcome)))
+}
+
+// MetricsHandler handles GET /metrics for Prometheus scrapes
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	handler := tracing.MetricsHandler()
+	if handler == nil {
+		respondWithError(w, r, http.StatusNotFound, "metrics are disabled")
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
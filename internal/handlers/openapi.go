@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"embed"
+	"mime"
+	"net/http"
+	"path"
+
+	"api-server/internal/logging"
+	"api-server/internal/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+// swaggerUIAssets are the vendored Swagger UI files served under /docs/
+//
+//go:embed swaggerui/*.js swaggerui/*.css swaggerui/*.png
+var swaggerUIAssets embed.FS
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>TRACE API Server</title>
+<link rel="stylesheet" href="swagger-ui.css">
+<link rel="icon" type="image/png" href="favicon-32x32.png" sizes="32x32">
+<link rel="icon" type="image/png" href="favicon-16x16.png" sizes="16x16">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="swagger-ui-bundle.js"></script>
+<script>
+  window.onload = () => {
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  };
+</script>
+</body>
+</html>
+`
+
+// OpenAPISpecHandler handles GET /openapi.json
+func OpenAPISpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openapi.JSON()); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
+	}
+}
+
+// APIDocsRedirectHandler handles GET /docs
+func APIDocsRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+}
+
+// APIDocsHandler handles GET /docs/
+func APIDocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(swaggerUIPage)); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
+	}
+}
+
+// APIDocsAssetHandler handles GET /docs/{asset}
+func APIDocsAssetHandler(w http.ResponseWriter, r *http.Request) {
+	asset := mux.Vars(r)["asset"]
+	data, err := swaggerUIAssets.ReadFile(path.Join("swaggerui", asset))
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "asset not found")
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(asset))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	// the assets only change with a new server version
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/reports"
+	"api-server/internal/repositories"
+	"api-server/internal/services"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+// Extracts the path segment at index, e.g. index 3 of /v1/reports/{job_id}
+func extractPathSegment(path string, index int) string {
+	parts := strings.Split(path, "/")
+	if len(parts) <= index {
+		return ""
+	}
+	return parts[index]
+}
+
+// InstructorReportHandler handles GET /v1/instructor/{instructor_id}/report?semester_term=...&format=html|pdf
+func (h *Handler) InstructorReportHandler(w http.ResponseWriter, r *http.Request) {
+	instructorID := validators.ExtractInstructorID(r.URL.Path)
+	if err := validators.ValidateInstructorID(instructorID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	if err := validators.ValidateReportParameters(query, "semester_term", "format"); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	semesterTerm := query.Get("semester_term")
+	if err := validators.ValidateSemesterTerm(semesterTerm); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = validators.ReportFormatHTML
+	}
+	if err := validators.ValidateReportFormat(format); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := reports.LoadInstructorReport(r.Context(), h.store, instructorID, semesterTerm, services.GetAnalyticsMinResponses())
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrNotFound):
+			respondWithError(w, r, http.StatusNotFound, "instructor or semester term not found")
+		case errors.Is(err, reports.ErrNoTraces):
+			respondWithError(w, r, http.StatusNotFound, err.Error())
+		default:
+			logging.FromContext(r.Context()).Error("Error loading instructor report", "error", err)
+			respondWithError(w, r, http.StatusServiceUnavailable, "")
+		}
+		return
+	}
+
+	if format == validators.ReportFormatPDF {
+		content := reports.RenderPDF(report)
+		fileName := fmt.Sprintf("%s-%s.pdf", report.Instructor.Name, report.SemesterTerm)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": validators.SanitizeFileName(fileName)}))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(content); err != nil {
+			logging.FromContext(r.Context()).Error("Error writing response", "error", err)
+		}
+		return
+	}
+
+	content, err := reports.RenderHTML(report)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error rendering instructor report", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to render report")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(content); err != nil {
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
+	}
+}
+
+// CreateDepartmentReportJobHandler handles POST /v1/department/{department_id}/reports?semester_term=...
+func (h *Handler) CreateDepartmentReportJobHandler(w http.ResponseWriter, r *http.Request) {
+	departmentID, err := strconv.Atoi(extractPathSegment(r.URL.Path, 3))
+	if err != nil || departmentID <= 0 {
+		respondWithError(w, r, http.StatusBadRequest, "invalid department ID")
+		return
+	}
+	query := r.URL.Query()
+	if err := validators.ValidateReportParameters(query, "semester_term"); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	semesterTerm := query.Get("semester_term")
+	if err := validators.ValidateSemesterTerm(semesterTerm); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if _, err := h.reference.GetDepartmentByID(r.Context(), departmentID); err != nil {
+		respondWithRepositoryError(w, r, err, "department")
+		return
+	}
+	if _, err := h.reference.GetSemesterTerm(r.Context(), semesterTerm); err != nil {
+		respondWithRepositoryError(w, r, err, "semester term")
+		return
+	}
+
+	job, err := h.reports.CreateReportJob(r.Context(), models.ReportJob{
+		JobID:        uuid.New().String(),
+		DepartmentID: departmentID,
+		SemesterTerm: semesterTerm,
+		UserID:       user.UserID,
+		Status:       models.ReportJobPending,
+		DateCreated:  time.Now().UTC(),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating report job", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
+		return
+	}
+	// a worker on any replica picks the job up, the one on this replica is woken at once
+	services.WakeReportWorker()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/reports/"+job.JobID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// loadReportJob validates the job ID in the path and returns the job,
+// writing the error response itself when it returns false
+func (h *Handler) loadReportJob(w http.ResponseWriter, r *http.Request) (*models.ReportJob, bool) {
+	jobID := extractPathSegment(r.URL.Path, 3)
+	if _, err := uuid.Parse(jobID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return nil, false
+	}
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	job, err := h.reports.GetReportJob(r.Context(), jobID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "report job")
+		return nil, false
+	}
+	return job, true
+}
+
+// GetReportJobHandler handles GET /v1/reports/{job_id}
+func (h *Handler) GetReportJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadReportJob(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// DownloadReportFileHandler handles GET /v1/reports/{job_id}/instructor/{instructor_id}/pdf
+func (h *Handler) DownloadReportFileHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadReportJob(w, r)
+	if !ok {
+		return
+	}
+	instructorID := extractPathSegment(r.URL.Path, 5)
+	if err := validators.ValidateInstructorID(instructorID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, err := h.reports.GetReportFile(r.Context(), job.JobID, instructorID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "report")
+		return
+	}
+	if file.Error != nil {
+		respondWithError(w, r, http.StatusNotFound, "report could not be generated: "+*file.Error)
+		return
+	}
+
+	serveTraceFile(w, r, fmt.Sprintf("%s-%s.pdf", instructorID, job.SemesterTerm), file.BucketPath)
+}
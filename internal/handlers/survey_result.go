@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"api-server/internal/kafka"
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+	"api-server/internal/services"
+	"api-server/internal/textanalysis"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+// Extracts traceId from /internal/v1/trace/{traceId}/results
+func extractInternalTraceID(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) < 5 || parts[4] == "" {
+		return ""
+	}
+	return parts[4]
+}
+
+// IngestSurveyResultHandler handles PUT /internal/v1/trace/{trace_id}/results.
+// The processing service calls it with the parsed survey; repeated deliveries replace the stored results.
+func (h *Handler) IngestSurveyResultHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+		return
+	}
+	traceID := extractInternalTraceID(r.URL.Path)
+	if _, err := uuid.Parse(traceID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req models.SurveyResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validators.ValidateSurveyResultRequest(req); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	trace, err := h.traces.GetTraceByID(r.Context(), traceID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "trace")
+		return
+	}
+
+	if err := h.surveyResults.SaveSurveyResult(r.Context(), traceID, req); err != nil {
+		logging.FromContext(r.Context()).Error("Error saving survey results", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to save survey results")
+		return
+	}
+	logging.FromContext(r.Context()).Info("Stored survey results", "trace_id", traceID, "questions", len(req.Questions), "comments", len(req.Comments))
+	services.RequestAnalyticsRefresh()
+	h.publishTraceEvent(r.Context(), kafka.EventTraceResultsProcessed, *trace, 0)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSurveyResultHandler handles GET /v1/course/{course_id}/trace/{trace_id}/results
+func (h *Handler) GetSurveyResultHandler(w http.ResponseWriter, r *http.Request) {
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.surveyResults.GetSurveyResult(r.Context(), trace.TraceID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			respondWithError(w, r, http.StatusNotFound, "survey results not available yet")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Error fetching survey results", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get survey results")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetCommentSummaryHandler handles GET /v1/course/{course_id}/trace/{trace_id}/comments/summary.
+// Comments are redacted before analysis so no student names or contact details are returned.
+func (h *Handler) GetCommentSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.surveyResults.GetSurveyResult(r.Context(), trace.TraceID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			respondWithError(w, r, http.StatusNotFound, "survey results not available yet")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Error fetching survey results", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get survey results")
+		return
+	}
+	comments, err := h.surveyResults.GetSurveyComments(r.Context(), trace.TraceID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching survey comments", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get survey comments")
+		return
+	}
+
+	texts := make([]string, len(comments))
+	for i, comment := range comments {
+		texts[i] = comment.Text
+	}
+	summary := textanalysis.Summarize(texts, textanalysis.DefaultOptions)
+	summary.TraceID = trace.TraceID
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
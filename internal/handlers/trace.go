@@ -1,18 +1,17 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"strings"
-	"time"
 
-	"api-server/internal/database"
 	"api-server/internal/kafka"
+	"api-server/internal/logging"
 	"api-server/internal/middleware"
 	"api-server/internal/models"
 	"api-server/internal/repositories"
@@ -33,68 +32,70 @@ func extractTraceID(path string) string {
 }
 
 // for endpoint: /v1/course/{courseId}/trace
-func TraceHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) TraceHandler(w http.ResponseWriter, r *http.Request) {
 	courseID := extractCourseID(r.URL.Path)
 	if courseID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	if _, err := uuid.Parse(courseID); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid UUID format")
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
 		return
 	}
 	switch r.Method {
 	case http.MethodPost:
-		createTraceHandler(w, r, courseID)
+		h.createTraceHandler(w, r, courseID)
 	case http.MethodGet: //get ALL
-		getAllTraceHandler(w, r, courseID)
+		h.getAllTraceHandler(w, r, courseID)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
 // for endpoint: /v1/course/{courseId}/trace/{traceId}
-func TraceEntityHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) TraceEntityHandler(w http.ResponseWriter, r *http.Request) {
 	//TODO: extract courseID and traceID from the URL
 	courseID := extractCourseID(r.URL.Path)
 	traceID := extractTraceID(r.URL.Path)
 	if courseID == "" || traceID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 	if _, err := uuid.Parse(courseID); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid UUID format")
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
 		return
 	}
 	if _, err := uuid.Parse(traceID); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid UUID format")
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
 		return
 	}
 	switch r.Method {
 	case http.MethodGet:
-		getTraceByIDHandler(w, r, courseID, traceID)
+		h.getTraceByIDHandler(w, r, courseID, traceID)
+	case http.MethodPatch:
+		h.patchTraceHandler(w, r, courseID, traceID)
 	case http.MethodDelete:
-		deleteTraceHandler(w, r, courseID, traceID)
+		h.deleteTraceHandler(w, r, courseID, traceID)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
-func createTraceHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+func (h *Handler) createTraceHandler(w http.ResponseWriter, r *http.Request, courseID string) {
 	//checks
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 	// Parse multipart form to handle file upload
 	err := r.ParseMultipartForm(10 << 20) // 10MB limit
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "failed to parse multipart form")
+		respondWithError(w, r, http.StatusBadRequest, "failed to parse multipart form")
 		return
 	}
 	traceReq := models.TraceRequest{
@@ -103,121 +104,43 @@ func createTraceHandler(w http.ResponseWriter, r *http.Request, courseID string)
 		Section:      r.FormValue("section"),
 	}
 
-	log.Printf("Trace Request: %v", traceReq)
-
 	// Retrieve file
 	file, handler, err := r.FormFile("file")
 	if err != nil {
-		log.Printf("Error retrieving file: %v", err)
-		respondWithError(w, http.StatusBadRequest, "failed to get file from request")
+		logging.FromContext(r.Context()).Error("Error retrieving file", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to get file from request")
 		return
 	}
 	defer file.Close()
-	log.Printf("Received file: %s, size: %d bytes", handler.Filename, handler.Size)
+	logging.FromContext(r.Context()).Debug("Received file", "file_name", handler.Filename, "size", handler.Size)
 
-	if err := validators.ValidateFileName(handler.Filename); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	// Read the upload so its content can be sniffed, validated and scanned
+	fileContent, err := io.ReadAll(file)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error reading file", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to read file from request")
 		return
 	}
 
-	//extract other fields
-
-	// add trace request validation from validators
-	if err := validators.ValidateTraceRequest(traceReq); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
 	if err := validators.ValidateCourseID(courseID); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 	//get user id
 	user := middleware.GetUserFromContext(r)
 	if user == nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-	userID := user.UserID
-
-	// check for instructorid, courseid, semesterterm existence
-	if _, err := repositories.GetInstructorByID(database.GetDB(), traceReq.InstructorID); err != nil {
-		log.Printf("Error fetching instructor: %v", err)
-		http.Error(w, "failed to get instructor", http.StatusBadRequest)
-		return
-	}
-
-	if _, err := repositories.GetCourseByID(database.GetDB(), courseID); err != nil {
-		log.Printf("Error fetching course: %v", err)
-		http.Error(w, "failed to get course", http.StatusBadRequest)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	if _, err := repositories.GetSemesterTerm(database.GetDB(), traceReq.SemesterTerm); err != nil {
-		log.Printf("Error fetching semester term: %v", err)
-		http.Error(w, "failed to get semester term", http.StatusBadRequest)
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
 
-	//upload file to GCS
-	bucketName := os.Getenv("BUCKET_NAME")
-	if bucketName == "" {
-		log.Fatal("Bucket name is not set in environment variables!")
-	}
-
-	uploadedFilePath, err := utils.UploadFileToGCS(file, handler.Filename, bucketName)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to upload file")
-		return
-	}
-
-	trace := models.Trace{
-		TraceID:      uuid.New().String(),
-		UserID:       userID,
-		FileName:     handler.Filename,
-		DateCreated:  time.Now().UTC(),
-		BucketPath:   uploadedFilePath,
-		CourseID:     courseID,
-		InstructorID: traceReq.InstructorID,
-		SemesterTerm: traceReq.SemesterTerm,
-		Section:      traceReq.Section,
-	}
-	log.Printf("Trace: %v", trace)
-
-	//Create trace
-	newTrace, err := repositories.CreateTrace(database.GetDB(), trace)
-
-	// Publish to Kafka if a producer is available
-	kafkaProducer := services.GetKafkaProducer()
-	if kafkaProducer != nil {
-		// Extract bucket name and path from GCS URL
-		bucketName := utils.ExtractBucketNameFromGCS(trace.BucketPath)
-		filePath := utils.ExtractFilePathFromGCS(trace.BucketPath)
-
-		uploadMessage := kafka.TraceUploadMessage{
-			TraceID:      trace.TraceID,
-			CourseID:     trace.CourseID,
-			FileName:     trace.FileName,
-			GCSBucket:    bucketName,
-			GCSPath:      filePath,
-			InstructorID: trace.InstructorID,
-			SemesterTerm: trace.SemesterTerm,
-			Section:      trace.Section,
-			UploadedBy:   trace.UserID,
-			UploadedAt:   trace.DateCreated,
-		}
-
-		err := kafkaProducer.PublishTraceUpload(r.Context(), uploadMessage)
-		if err != nil {
-			// Log error but don't fail the response
-			log.Printf("Error publishing to Kafka: %v", err)
-		} else {
-			log.Printf("Successfully published trace %s to Kafka", trace.TraceID)
-		}
-	}
-
+	newTrace, err := h.processTraceUpload(r.Context(), user.UserID, courseID, traceReq, bufferedTraceFile(user.UserID, handler.Filename, fileContent))
 	if err != nil {
-		log.Printf("Error creating trace: %v", err)
-		http.Error(w, "failed to create trace", http.StatusInternalServerError)
+		respondWithUploadError(w, r, err)
 		return
 	}
 	// return 201 status code
@@ -227,22 +150,22 @@ func createTraceHandler(w http.ResponseWriter, r *http.Request, courseID string)
 
 }
 
-func getAllTraceHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+func (h *Handler) getAllTraceHandler(w http.ResponseWriter, r *http.Request, courseID string) {
 	//checks
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 
 	//get all traces by courseID
-	traces, err := repositories.GetTraceByCourseID(database.GetDB(), courseID)
+	traces, err := h.traces.GetTraceByCourseID(r.Context(), courseID)
 	if err != nil {
-		log.Printf("Error fetching traces: %v", err)
-		http.Error(w, "failed to get traces", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error fetching traces", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get traces")
 		return
 	}
 
@@ -253,32 +176,26 @@ func getAllTraceHandler(w http.ResponseWriter, r *http.Request, courseID string)
 }
 
 // get trace by traceid
-func getTraceByIDHandler(w http.ResponseWriter, r *http.Request, courseID string, traceID string) {
+func (h *Handler) getTraceByIDHandler(w http.ResponseWriter, r *http.Request, courseID string, traceID string) {
 	//checks
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 	//check if course id is valid
-	if _, err := repositories.GetCourseByID(database.GetDB(), courseID); err != nil {
-		log.Printf("Error fetching course: %v", err)
-		http.Error(w, "failed to get course", http.StatusBadRequest)
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
 
 	//get trace by traceID
-	trace, err := repositories.GetTraceByID(database.GetDB(), traceID)
+	trace, err := h.traces.GetTraceByID(r.Context(), traceID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "trace not found", http.StatusNotFound)
-			return
-		}
-		log.Printf("Error fetching trace: %v", err)
-		http.Error(w, "failed to get trace", http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "trace")
 		return
 	}
 
@@ -289,24 +206,23 @@ func getTraceByIDHandler(w http.ResponseWriter, r *http.Request, courseID string
 }
 
 // GetAllTracesHandler handles GET /v1/traces
-func GetAllTracesHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllTracesHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	db := database.GetDB()
-	traces, err := repositories.GetAllTraces(db)
+	traces, err := h.traces.GetAllTraces(r.Context())
 	if err != nil {
-		log.Printf("Error retrieving traces: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		logging.FromContext(r.Context()).Error("Error retrieving traces", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
 		return
 	}
 
@@ -315,116 +231,241 @@ func GetAllTracesHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // delete trace by traceid
-func deleteTraceHandler(w http.ResponseWriter, r *http.Request, courseID string, traceID string) {
+func (h *Handler) deleteTraceHandler(w http.ResponseWriter, r *http.Request, courseID string, traceID string) {
 	//checks
 	if r.Method != http.MethodDelete {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 	if len(r.URL.Query()) > 0 {
-		respondWithError(w, http.StatusBadRequest, "query parameters are not allowed")
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
 		return
 	}
 	//check if course id is valid
-	if _, err := repositories.GetCourseByID(database.GetDB(), courseID); err != nil {
-		log.Printf("Error fetching course: %v", err)
-		http.Error(w, "failed to get course", http.StatusBadRequest)
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return
+	}
+	//get filepath from trace, kept to describe the deleted trace in its event
+	trace, err := h.traces.GetTraceByID(r.Context(), traceID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "trace")
 		return
 	}
-	//get filepath from trace id
-	filePath, err := repositories.GetFilePath(database.GetDB(), traceID)
+	filePath := trace.BucketPath
+	// every version keeps its own object, a rollback can point two versions at the same one
+	versions, err := h.traces.GetTraceVersions(r.Context(), traceID)
 	if err != nil {
-		log.Printf("Error fetching file path: %v", err)
-		http.Error(w, "failed to get file path", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error fetching trace versions", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get trace versions")
 		return
 	}
-	//delete file from GCS
+	filePaths := []string{filePath}
+	seen := map[string]bool{filePath: true}
+	for _, version := range versions {
+		if !seen[version.BucketPath] {
+			seen[version.BucketPath] = true
+			filePaths = append(filePaths, version.BucketPath)
+		}
+	}
 	bucketName := os.Getenv("BUCKET_NAME")
 	if bucketName == "" {
-		log.Fatal("Bucket name is not set in environment variables!")
+		logging.FromContext(r.Context()).Error("Bucket name is not set in environment variables")
+		respondWithError(w, r, http.StatusInternalServerError, "storage is not configured")
+		return
 	}
-	err = utils.DeleteFileFromGCS(filePath, bucketName)
+	//delete trace and its version history together
+	err = h.store.WithTx(r.Context(), func(tx repositories.Store) error {
+		if err := tx.DeleteTraceVersions(r.Context(), traceID); err != nil {
+			return err
+		}
+		return tx.DeleteTrace(r.Context(), traceID)
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to delete file")
+		respondWithRepositoryError(w, r, err, "trace")
 		return
 	}
-	//delete trace by traceID
-	errDelete := repositories.DeleteTrace(database.GetDB(), traceID)
-	if errDelete != nil {
-		if errors.Is(errDelete, sql.ErrNoRows) {
-			http.Error(w, "trace not found", http.StatusNotFound)
-			return
+	// the files are removed once nothing refers to them anymore; one that cannot be removed
+	// is left behind rather than failing a deletion that already happened
+	for _, path := range filePaths {
+		if err := utils.DeleteFileFromGCS(r.Context(), path, bucketName); err != nil {
+			logging.FromContext(r.Context()).Error("Error removing file of deleted trace", "trace_id", traceID, "bucket_path", path, "error", err)
 		}
-		log.Printf("Error deleting trace from database: %v", errDelete)
-		http.Error(w, "failed to delete trace from database", http.StatusInternalServerError)
-		return
 	}
 
+	h.publishTraceEvent(r.Context(), kafka.EventTraceDeleted, *trace, 0)
+	services.RequestAnalyticsRefresh()
+
 	// return 204 status code
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// patch trace metadata using JSON Merge Patch (RFC 7396)
+func (h *Handler) patchTraceHandler(w http.ResponseWriter, r *http.Request, courseID string, traceID string) {
+	//checks
+	if r.Method != http.MethodPatch {
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+		return
+	}
+	if len(r.URL.Query()) > 0 {
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/merge-patch+json" && contentType != "application/json" {
+		respondWithError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validators.ValidateTracePatchFields(patch); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return
+	}
+	trace, err := h.traces.GetTraceByID(r.Context(), traceID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "trace")
+		return
+	}
+	if trace.CourseID != courseID {
+		respondWithError(w, r, http.StatusNotFound, "trace not found")
+		return
+	}
+
+	// apply the validated patch onto the current values
+	traceReq := models.TracePatchRequest{
+		InstructorID: trace.InstructorID,
+		SemesterTerm: trace.SemesterTerm,
+		Section:      trace.Section,
+		CourseID:     trace.CourseID,
+	}
+	if err := json.Unmarshal(body, &traceReq); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	newCourseID := traceReq.CourseID
+
+	// check for instructorid, courseid, semesterterm existence
+	if traceReq.InstructorID != trace.InstructorID {
+		if _, err := h.instructors.GetInstructorByID(r.Context(), traceReq.InstructorID); err != nil {
+			logging.FromContext(r.Context()).Error("Error fetching instructor", "error", err)
+			respondWithError(w, r, http.StatusBadRequest, "instructor not found")
+			return
+		}
+	}
+	if traceReq.SemesterTerm != trace.SemesterTerm {
+		if _, err := h.reference.GetSemesterTerm(r.Context(), traceReq.SemesterTerm); err != nil {
+			logging.FromContext(r.Context()).Error("Error fetching semester term", "error", err)
+			respondWithError(w, r, http.StatusBadRequest, "semester term not found")
+			return
+		}
+	}
+	if newCourseID != trace.CourseID {
+		// a missing target course is a bad request, not a missing resource
+		_, err := h.courses.GetCourseByID(r.Context(), newCourseID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			respondWithError(w, r, http.StatusBadRequest, "course not found")
+			return
+		}
+		if err != nil {
+			respondWithRepositoryError(w, r, err, "course")
+			return
+		}
+	}
+
+	trace.InstructorID = traceReq.InstructorID
+	trace.SemesterTerm = traceReq.SemesterTerm
+	trace.Section = traceReq.Section
+	trace.CourseID = newCourseID
+	if err := h.traces.UpdateTrace(r.Context(), trace); err != nil {
+		respondWithRepositoryError(w, r, err, "trace")
+		return
+	}
+
+	h.publishTraceEvent(r.Context(), kafka.EventTraceUpdated, *trace, 0)
+	services.RequestAnalyticsRefresh()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trace)
+}
+
 // for endpoint: /v1/course/{courseId}/trace/{traceId}/pdf
-func DownloadTraceHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DownloadTraceHandler(w http.ResponseWriter, r *http.Request) {
 	courseID := extractCourseID(r.URL.Path)
 	traceID := extractTraceID(r.URL.Path)
 
 	if courseID == "" || traceID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	if _, err := uuid.Parse(courseID); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid UUID format")
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
 		return
 	}
 
 	if _, err := uuid.Parse(traceID); err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid UUID format")
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
 		return
 	}
 
 	// Check if course exists
-	if _, err := repositories.GetCourseByID(database.GetDB(), courseID); err != nil {
-		log.Printf("Error fetching course: %v", err)
-		http.Error(w, "failed to get course", http.StatusBadRequest)
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
 		return
 	}
 
 	// Get trace by ID
-	trace, err := repositories.GetTraceByID(database.GetDB(), traceID)
+	trace, err := h.traces.GetTraceByID(r.Context(), traceID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "trace not found", http.StatusNotFound)
-			return
-		}
-		log.Printf("Error fetching trace: %v", err)
-		http.Error(w, "failed to get trace", http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "trace")
 		return
 	}
 
+	serveTraceFile(w, r, trace.FileName, trace.BucketPath)
+}
+
+// serveTraceFile streams a stored trace file back to the client
+func serveTraceFile(w http.ResponseWriter, r *http.Request, fileName, bucketPath string) {
 	// Get bucket name from environment
 	bucketName := os.Getenv("BUCKET_NAME")
 	if bucketName == "" {
-		log.Fatal("Bucket name is not set in environment variables!")
+		logging.FromContext(r.Context()).Error("Bucket name is not set in environment variables")
+		respondWithError(w, r, http.StatusInternalServerError, "storage is not configured")
+		return
 	}
 
 	// Get file from GCS
-	fileContent, contentType, err := utils.GetFileFromGCS(trace.BucketPath, bucketName)
+	fileContent, contentType, err := utils.GetFileFromGCS(r.Context(), bucketPath, bucketName)
 	if err != nil {
-		log.Printf("Error retrieving file from GCS: %v", err)
-		http.Error(w, "failed to retrieve file", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error retrieving file from GCS", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to retrieve file")
 		return
 	}
 
 	// Set appropriate headers
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", trace.FileName))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": validators.SanitizeFileName(fileName)}))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileContent)))
 
 	// Write the file to the response
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(fileContent); err != nil {
-		log.Printf("Error writing response: %v", err)
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
 	}
 }
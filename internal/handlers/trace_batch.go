@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// name of the manifest file inside a ZIP archive
+const batchManifestName = "manifest.json"
+
+// batchFile is one file of a batch upload together with its metadata
+type batchFile struct {
+	name     string
+	content  []byte
+	metadata models.TraceRequest
+	err      error
+}
+
+// for endpoint: /v1/course/{courseId}/trace/batch
+//
+// Accepts either a multipart "manifest" JSON field with one or more "files" parts,
+// or a single "archive" ZIP containing manifest.json alongside the files.
+// The instructor_id, semester_term and section form fields act as defaults for
+// manifest entries that leave them blank.
+func (h *Handler) BatchTraceHandler(w http.ResponseWriter, r *http.Request) {
+	courseID := extractCourseID(r.URL.Path)
+	if courseID == "" {
+		respondWithError(w, r, http.StatusNotFound, "")
+		return
+	}
+	if _, err := uuid.Parse(courseID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if r.Method != http.MethodPost {
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+		return
+	}
+	if len(r.URL.Query()) > 0 {
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return
+	}
+
+	policy := services.GetUploadPolicy()
+	r.Body = http.MaxBytesReader(w, r.Body, policy.BatchMaxBytes)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, r, http.StatusRequestEntityTooLarge, "batch upload is too large")
+			return
+		}
+		respondWithError(w, r, http.StatusBadRequest, "failed to parse multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	defaults := models.TraceRequest{
+		InstructorID: r.FormValue("instructor_id"),
+		SemesterTerm: r.FormValue("semester_term"),
+		Section:      r.FormValue("section"),
+	}
+
+	var files []batchFile
+	var err error
+	if archives := r.MultipartForm.File["archive"]; len(archives) > 0 {
+		files, err = readBatchArchive(r, defaults, policy.BatchMaxBytes)
+	} else {
+		files, err = readBatchMultipart(r, defaults)
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(files) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "no files in batch")
+		return
+	}
+	if len(files) > policy.BatchMaxFiles {
+		respondWithError(w, r, http.StatusBadRequest, fmt.Sprintf("batch cannot contain more than %d files", policy.BatchMaxFiles))
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Processing upload batch", "files", len(files), "course_id", courseID)
+	report := h.processTraceBatch(r.Context(), user.UserID, courseID, files, policy.BatchConcurrency)
+
+	// 201 when every file was stored, 207 when the report needs to be inspected
+	status := http.StatusCreated
+	if report.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// processTraceBatch stores the files with at most `concurrency` uploads in flight
+func (h *Handler) processTraceBatch(ctx context.Context, userID, courseID string, files []batchFile, concurrency int) models.BatchTraceReport {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]models.BatchTraceResult, len(files))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, f := range files {
+		if f.err != nil {
+			results[i] = models.BatchTraceResult{FileName: f.name, Status: http.StatusBadRequest, Error: f.err.Error()}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, f batchFile) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			trace, err := h.processTraceUpload(ctx, userID, courseID, f.metadata, bufferedTraceFile(userID, f.name, f.content))
+			if err != nil {
+				results[i] = models.BatchTraceResult{FileName: f.name, Status: uploadErrorStatus(err), Error: err.Error()}
+				return
+			}
+			results[i] = models.BatchTraceResult{FileName: f.name, Status: http.StatusCreated, Trace: &trace}
+		}(i, f)
+	}
+	wg.Wait()
+
+	report := models.BatchTraceReport{Total: len(results), Results: results}
+	for _, result := range results {
+		if result.Error == "" {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// readBatchMultipart pairs the "files" parts with the entries of the "manifest" field
+func readBatchMultipart(r *http.Request, defaults models.TraceRequest) ([]batchFile, error) {
+	manifest, err := parseBatchManifest([]byte(r.FormValue("manifest")))
+	if err != nil {
+		return nil, err
+	}
+
+	contents := map[string][]byte{}
+	order := []string{}
+	for _, header := range r.MultipartForm.File["files"] {
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s", header.Filename)
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s", header.Filename)
+		}
+		if _, exists := contents[header.Filename]; exists {
+			return nil, fmt.Errorf("duplicate file name %s", header.Filename)
+		}
+		contents[header.Filename] = content
+		order = append(order, header.Filename)
+	}
+
+	return matchBatchManifest(manifest, contents, order, defaults), nil
+}
+
+// readBatchArchive extracts the files and manifest.json from the "archive" ZIP part
+func readBatchArchive(r *http.Request, defaults models.TraceRequest, maxBytes int64) ([]batchFile, error) {
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		return nil, errors.New("failed to get archive from request")
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		return nil, errors.New("archive is not a valid ZIP file")
+	}
+
+	var manifestData []byte
+	contents := map[string][]byte{}
+	order := []string{}
+	remaining := maxBytes
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Base(entry.Name)
+		// skip metadata such as __MACOSX/._foo.pdf and .DS_Store
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(entry.Name, "__MACOSX/") {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in archive", entry.Name)
+		}
+		// guard against archives that decompress far beyond the request limit
+		content, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in archive", entry.Name)
+		}
+		remaining -= int64(len(content))
+		if remaining < 0 {
+			return nil, errors.New("archive contents are too large")
+		}
+
+		if name == batchManifestName {
+			manifestData = content
+			continue
+		}
+		if _, exists := contents[name]; exists {
+			return nil, fmt.Errorf("duplicate file name %s", name)
+		}
+		contents[name] = content
+		order = append(order, name)
+	}
+
+	manifest, err := parseBatchManifest(manifestData)
+	if err != nil {
+		return nil, err
+	}
+	return matchBatchManifest(manifest, contents, order, defaults), nil
+}
+
+// parseBatchManifest decodes the manifest; an empty manifest means every file uses the defaults
+func parseBatchManifest(data []byte) ([]models.BatchTraceManifestEntry, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var manifest []models.BatchTraceManifestEntry
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.New("invalid manifest")
+	}
+	return manifest, nil
+}
+
+// matchBatchManifest builds the list of files to process. Files without a manifest entry and
+// manifest entries without a file are reported as failures rather than rejecting the batch.
+func matchBatchManifest(manifest []models.BatchTraceManifestEntry, contents map[string][]byte, order []string, defaults models.TraceRequest) []batchFile {
+	files := []batchFile{}
+
+	if manifest == nil {
+		for _, name := range order {
+			files = append(files, batchFile{name: name, content: contents[name], metadata: defaults})
+		}
+		return files
+	}
+
+	described := map[string]bool{}
+	for _, entry := range manifest {
+		f := batchFile{
+			name: entry.FileName,
+			metadata: models.TraceRequest{
+				InstructorID: firstNonEmpty(entry.InstructorID, defaults.InstructorID),
+				SemesterTerm: firstNonEmpty(entry.SemesterTerm, defaults.SemesterTerm),
+				Section:      firstNonEmpty(entry.Section, defaults.Section),
+			},
+		}
+		content, ok := contents[entry.FileName]
+		switch {
+		case described[entry.FileName]:
+			f.err = errors.New("file listed more than once in manifest")
+		case !ok:
+			f.err = errors.New("file not found in upload")
+		default:
+			f.content = content
+		}
+		described[entry.FileName] = true
+		files = append(files, f)
+	}
+
+	for _, name := range order {
+		if !described[name] {
+			files = append(files, batchFile{name: name, err: errors.New("file has no manifest entry")})
+		}
+	}
+	return files
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
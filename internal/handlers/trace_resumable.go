@@ -0,0 +1,575 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+	"api-server/internal/scanner"
+	"api-server/internal/services"
+	"api-server/internal/utils"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+// Resumable uploads follow the tus 1.0.0 protocol with the creation, checksum,
+// expiration and termination extensions.
+const (
+	tusVersion          = "1.0.0"
+	tusExtensions       = "creation,checksum,expiration,termination"
+	tusChecksumAlgs     = "sha256,sha1,md5"
+	tusOffsetType       = "application/offset+octet-stream"
+	statusChecksumError = 460 // defined by the tus checksum extension
+)
+
+// Extracts uploadId from /v1/course/{courseId}/trace/uploads/{uploadId}
+func extractUploadID(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) < 7 || parts[6] == "" {
+		return ""
+	}
+	return parts[6]
+}
+
+func setTusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// for endpoint: OPTIONS /v1/course/{courseId}/trace/uploads
+func ResumableUploadOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	policy := services.GetUploadPolicy()
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Checksum-Algorithm", tusChecksumAlgs)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(policy.ResumableMaxBytes, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// for endpoint: POST /v1/course/{courseId}/trace/uploads
+//
+// Upload-Length carries the total size and Upload-Metadata the base64 encoded
+// filename, instructor_id, semester_term and section.
+func (h *Handler) CreateResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+	courseID := extractCourseID(r.URL.Path)
+	if _, err := uuid.Parse(courseID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		respondWithError(w, r, http.StatusPreconditionFailed, "unsupported tus version")
+		return
+	}
+
+	policy := services.GetUploadPolicy()
+	uploadLength, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || uploadLength <= 0 {
+		respondWithError(w, r, http.StatusBadRequest, "invalid Upload-Length header")
+		return
+	}
+	if uploadLength > policy.ResumableMaxBytes {
+		respondWithError(w, r, http.StatusRequestEntityTooLarge, "upload exceeds maximum size")
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	traceReq := models.TraceRequest{
+		InstructorID: metadata["instructor_id"],
+		SemesterTerm: metadata["semester_term"],
+		Section:      metadata["section"],
+	}
+	fileName := metadata["filename"]
+	if err := validators.ValidateFileName(fileName); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	// validate up front so clients do not upload hundreds of MB for nothing
+	if err := validators.ValidateTraceRequest(traceReq); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return
+	}
+
+	now := time.Now().UTC()
+	session := models.UploadSession{
+		UploadID:     uuid.New().String(),
+		UserID:       user.UserID,
+		CourseID:     courseID,
+		InstructorID: traceReq.InstructorID,
+		SemesterTerm: traceReq.SemesterTerm,
+		Section:      traceReq.Section,
+		FileName:     fileName,
+		UploadLength: uploadLength,
+		Status:       models.UploadStatusPending,
+		DateCreated:  now,
+		ExpiresAt:    now.Add(policy.ResumableTTL),
+	}
+	if _, err := h.uploads.CreateUploadSession(r.Context(), session); err != nil {
+		logging.FromContext(r.Context()).Error("Error creating upload session", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to create upload session")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v1/course/%s/trace/uploads/%s", courseID, session.UploadID))
+	w.Header().Set("Upload-Offset", "0")
+	w.Header().Set("Upload-Expires", session.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// for endpoint: /v1/course/{courseId}/trace/uploads/{uploadId}
+func (h *Handler) ResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+	courseID := extractCourseID(r.URL.Path)
+	uploadID := extractUploadID(r.URL.Path)
+	if _, err := uuid.Parse(courseID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if _, err := uuid.Parse(uploadID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		respondWithError(w, r, http.StatusPreconditionFailed, "unsupported tus version")
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	session, err := h.uploads.GetUploadSession(r.Context(), uploadID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "upload")
+		return
+	}
+	// sessions belong to the user that created them
+	if session.UserID != user.UserID || session.CourseID != courseID {
+		respondWithError(w, r, http.StatusNotFound, "upload not found")
+		return
+	}
+	if session.Status == models.UploadStatusPending && time.Now().UTC().After(session.ExpiresAt) {
+		respondWithError(w, r, http.StatusGone, "upload has expired")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		headResumableUploadHandler(w, session)
+	case http.MethodPatch:
+		h.patchResumableUploadHandler(w, r, session)
+	case http.MethodDelete:
+		h.deleteResumableUploadHandler(w, r, session)
+	default:
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+	}
+}
+
+func headResumableUploadHandler(w http.ResponseWriter, session *models.UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.UploadLength, 10))
+	if session.Status == models.UploadStatusPending {
+		w.Header().Set("Upload-Expires", session.ExpiresAt.Format(http.TimeFormat))
+	}
+	if session.TraceID != nil {
+		w.Header().Set("Upload-Trace-Id", *session.TraceID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) patchResumableUploadHandler(w http.ResponseWriter, r *http.Request, session *models.UploadSession) {
+	if r.Header.Get("Content-Type") != tusOffsetType {
+		respondWithError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+tusOffsetType)
+		return
+	}
+	if session.Status != models.UploadStatusPending {
+		respondWithError(w, r, http.StatusConflict, "upload is already "+session.Status)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		respondWithError(w, r, http.StatusBadRequest, "invalid Upload-Offset header")
+		return
+	}
+	if offset != session.UploadOffset {
+		respondWithError(w, r, http.StatusConflict, "Upload-Offset does not match current offset")
+		return
+	}
+
+	checksumHash, expectedSum, err := parseUploadChecksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// never accept more than the declared length or the chunk limit
+	policy := services.GetUploadPolicy()
+	limit := session.UploadLength - session.UploadOffset
+	if limit > policy.ResumableChunkMaxBytes {
+		limit = policy.ResumableChunkMaxBytes
+	}
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, r, http.StatusRequestEntityTooLarge, "chunk exceeds maximum size")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Error reading chunk", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to read chunk")
+		return
+	}
+	if len(chunk) == 0 {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	sum := sha256.Sum256(chunk)
+	checksum := base64.StdEncoding.EncodeToString(sum[:])
+	if checksumHash != nil {
+		checksumHash.Write(chunk)
+		if !bytes.Equal(checksumHash.Sum(nil), expectedSum) {
+			respondWithError(w, r, statusChecksumError, "checksum mismatch")
+			return
+		}
+	}
+
+	bucketName := os.Getenv("BUCKET_NAME")
+	if bucketName == "" {
+		logging.FromContext(r.Context()).Error("Bucket name is not set in environment variables")
+		respondWithError(w, r, http.StatusInternalServerError, "storage is not configured")
+		return
+	}
+	chunkPath, err := utils.UploadChunkToGCS(r.Context(), bytes.NewReader(chunk), session.UploadID, offset, bucketName)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "failed to store chunk")
+		return
+	}
+
+	uploadChunk := models.UploadChunk{
+		UploadID:    session.UploadID,
+		ChunkOffset: offset,
+		ChunkSize:   int64(len(chunk)),
+		BucketPath:  chunkPath,
+		Checksum:    checksum,
+	}
+	expiresAt := time.Now().UTC().Add(policy.ResumableTTL)
+	if err := h.uploads.AppendUploadChunk(r.Context(), uploadChunk, expiresAt); err != nil {
+		// another request advanced the offset first, drop our own object of the chunk
+		if delErr := utils.DeleteFileFromGCS(r.Context(), chunkPath, bucketName); delErr != nil {
+			logging.FromContext(r.Context()).Error("Error deleting orphaned chunk", "bucket_path", chunkPath, "error", delErr)
+		}
+		if errors.Is(err, repositories.ErrNotFound) {
+			respondWithError(w, r, http.StatusConflict, "Upload-Offset does not match current offset")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Error recording chunk", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to record chunk")
+		return
+	}
+
+	newOffset := offset + int64(len(chunk))
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+
+	if newOffset < session.UploadLength {
+		w.Header().Set("Upload-Expires", expiresAt.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	trace, err := h.assembleResumableUpload(r, session, bucketName)
+	if err != nil {
+		respondWithUploadError(w, r, err)
+		return
+	}
+	w.Header().Set("Upload-Trace-Id", trace.TraceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// assembleResumableUpload hands the staged chunks to the regular trace upload path, then
+// records the outcome on the session
+func (h *Handler) assembleResumableUpload(r *http.Request, session *models.UploadSession, bucketName string) (models.Trace, error) {
+	chunks, err := h.uploads.GetUploadChunks(r.Context(), session.UploadID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching upload chunks", "error", err)
+		return models.Trace{}, newUploadError(http.StatusInternalServerError, "failed to assemble upload")
+	}
+
+	traceReq := models.TraceRequest{
+		InstructorID: session.InstructorID,
+		SemesterTerm: session.SemesterTerm,
+		Section:      session.Section,
+	}
+	trace, uploadErr := h.processTraceUpload(r.Context(), session.UserID, session.CourseID, traceReq, stagedTraceFile(session, chunks, bucketName))
+
+	status := models.UploadStatusCompleted
+	var traceID, errMessage *string
+	if uploadErr != nil {
+		status = models.UploadStatusFailed
+		message := uploadErr.Error()
+		errMessage = &message
+	} else {
+		traceID = &trace.TraceID
+	}
+	if err := h.uploads.FinishUploadSession(r.Context(), session.UploadID, status, traceID, errMessage); err != nil {
+		logging.FromContext(r.Context()).Error("Error finishing upload session", "upload_id", session.UploadID, "error", err)
+	}
+	deleteUploadChunks(r.Context(), chunks, bucketName)
+
+	return trace, uploadErr
+}
+
+// stagedTraceFile stores the file of a resumable upload from its staged chunks
+func stagedTraceFile(session *models.UploadSession, chunks []models.UploadChunk, bucketName string) traceFileStore {
+	return func(ctx context.Context) (string, string, error) {
+		return storeStagedTraceFile(ctx, session, chunks, bucketName)
+	}
+}
+
+// storeStagedTraceFile streams the chunks into one staging object while validating them,
+// scans that object and copies it under uploads/, so the file is never held in memory
+func storeStagedTraceFile(ctx context.Context, session *models.UploadSession, chunks []models.UploadChunk, bucketName string) (string, string, error) {
+	outcome := "rejected"
+	defer func() { recordUploadSize(ctx, int(session.UploadLength), outcome) }()
+
+	if err := validators.ValidateFileName(session.FileName); err != nil {
+		return "", "", newUploadError(http.StatusBadRequest, err.Error())
+	}
+	fileName := validators.SanitizeFileName(session.FileName)
+
+	inspector := validators.NewFileInspector()
+	chunkReader := &stagedChunkReader{ctx: ctx, chunks: chunks, length: session.UploadLength, bucketName: bucketName}
+	assembledPath, err := utils.UploadAssembledFileToGCS(ctx, io.TeeReader(chunkReader, inspector), session.UploadID, bucketName)
+	chunkReader.Close()
+	if err != nil {
+		logging.FromContext(ctx).Error("Error assembling upload", "upload_id", session.UploadID, "error", err)
+		outcome = "failed"
+		return "", "", newUploadError(http.StatusInternalServerError, "failed to assemble upload")
+	}
+	defer func() {
+		if err := utils.DeleteFileFromGCS(ctx, assembledPath, bucketName); err != nil {
+			logging.FromContext(ctx).Error("Error deleting assembled upload", "upload_id", session.UploadID, "error", err)
+		}
+	}()
+
+	policy := services.GetUploadPolicy()
+	contentType, err := validateTraceFile(inspector, policy)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Scan for malware before anything is stored under uploads/
+	if fileScanner := services.GetScanner(); fileScanner != nil {
+		result, err := scanStoredFile(ctx, fileScanner, assembledPath, bucketName)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error scanning file", "error", err)
+			outcome = "failed"
+			return "", "", newUploadError(http.StatusServiceUnavailable, "failed to scan file")
+		}
+		if result.Infected {
+			outcome = "infected"
+			logging.FromContext(ctx).Warn("Rejected infected upload", "file_name", fileName, "signature", result.Signature)
+			if _, err := utils.CopyFileToQuarantineInGCS(ctx, assembledPath, fileName, bucketName, policy.QuarantinePrefix, result.Signature); err != nil {
+				logging.FromContext(ctx).Error("Error quarantining file", "error", err)
+			}
+			return "", "", newUploadError(http.StatusUnprocessableEntity, "file failed malware scan")
+		}
+	}
+
+	uploadedFilePath, err := utils.CopyFileToUploadsInGCS(ctx, assembledPath, fileName, bucketName, contentType)
+	if err != nil {
+		outcome = "failed"
+		return "", "", newUploadError(http.StatusInternalServerError, "failed to upload file")
+	}
+	outcome = "stored"
+	return fileName, uploadedFilePath, nil
+}
+
+// scanStoredFile streams an object of the bucket to the scanner
+func scanStoredFile(ctx context.Context, fileScanner scanner.Scanner, gcsURL, bucketName string) (scanner.Result, error) {
+	reader, err := utils.OpenFileFromGCS(ctx, gcsURL, bucketName)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	defer reader.Close()
+	return fileScanner.Scan(ctx, reader)
+}
+
+// stagedChunkReader reads the staged chunks of an upload in order, failing on a chunk that
+// does not start where the previous one ended or does not match the checksum recorded on
+// receipt, and on a total that differs from the upload length
+type stagedChunkReader struct {
+	ctx        context.Context
+	chunks     []models.UploadChunk
+	length     int64
+	bucketName string
+
+	next    int
+	offset  int64
+	current io.ReadCloser
+	sum     hash.Hash
+}
+
+func (c *stagedChunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if c.next == len(c.chunks) {
+				if c.offset != c.length {
+					return 0, fmt.Errorf("chunks hold %d bytes, upload length is %d", c.offset, c.length)
+				}
+				return 0, io.EOF
+			}
+			chunk := c.chunks[c.next]
+			if chunk.ChunkOffset != c.offset {
+				return 0, fmt.Errorf("chunk %s starts at %d, want %d", chunk.BucketPath, chunk.ChunkOffset, c.offset)
+			}
+			reader, err := utils.OpenFileFromGCS(c.ctx, chunk.BucketPath, c.bucketName)
+			if err != nil {
+				return 0, err
+			}
+			c.current, c.sum = reader, sha256.New()
+		}
+
+		n, err := c.current.Read(p)
+		c.sum.Write(p[:n])
+		c.offset += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+		c.current.Close()
+		c.current = nil
+		chunk := c.chunks[c.next]
+		c.next++
+		if base64.StdEncoding.EncodeToString(c.sum.Sum(nil)) != chunk.Checksum {
+			return n, fmt.Errorf("chunk %s does not match its checksum", chunk.BucketPath)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (c *stagedChunkReader) Close() error {
+	if c.current == nil {
+		return nil
+	}
+	err := c.current.Close()
+	c.current = nil
+	return err
+}
+
+func (h *Handler) deleteResumableUploadHandler(w http.ResponseWriter, r *http.Request, session *models.UploadSession) {
+	if session.Status == models.UploadStatusAssembling {
+		respondWithError(w, r, http.StatusConflict, "upload is being assembled")
+		return
+	}
+	chunks, err := h.uploads.GetUploadChunks(r.Context(), session.UploadID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching upload chunks", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to delete upload")
+		return
+	}
+	deleteUploadChunks(r.Context(), chunks, os.Getenv("BUCKET_NAME"))
+
+	if err := h.uploads.DeleteUploadSession(r.Context(), session.UploadID); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		logging.FromContext(r.Context()).Error("Error deleting upload session", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to delete upload")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteUploadChunks(ctx context.Context, chunks []models.UploadChunk, bucketName string) {
+	for _, chunk := range chunks {
+		if err := utils.DeleteFileFromGCS(ctx, chunk.BucketPath, bucketName); err != nil {
+			logging.FromContext(ctx).Error("Error deleting chunk", "upload_id", chunk.UploadID, "bucket_path", chunk.BucketPath, "error", err)
+		}
+	}
+}
+
+// parseUploadMetadata decodes "key base64value,key base64value"
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.New("invalid Upload-Metadata header")
+		}
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, errors.New("invalid Upload-Metadata header")
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, nil
+}
+
+// parseUploadChecksum decodes "algorithm base64digest"; an empty header means no checksum
+func parseUploadChecksum(header string) (hash.Hash, []byte, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil, nil
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, nil, errors.New("invalid Upload-Checksum header")
+	}
+	expected, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, nil, errors.New("invalid Upload-Checksum header")
+	}
+	switch strings.ToLower(fields[0]) {
+	case "sha256":
+		return sha256.New(), expected, nil
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "md5":
+		return md5.New(), expected, nil
+	default:
+		return nil, nil, errors.New("unsupported checksum algorithm")
+	}
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"api-server/internal/kafka"
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+	"api-server/internal/services"
+	"api-server/internal/utils"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+// uploadError carries the HTTP status a failed upload should be reported with
+type uploadError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+func (e *uploadError) Unwrap() error {
+	return e.err
+}
+
+func newUploadError(status int, message string) *uploadError {
+	return &uploadError{status: status, message: message}
+}
+
+// uploadErrorStatus returns the status for an error returned by processTraceUpload
+func uploadErrorStatus(err error) int {
+	if uploadErr, ok := err.(*uploadError); ok {
+		return uploadErr.status
+	}
+	return http.StatusInternalServerError
+}
+
+// respondWithUploadError sends the problem for an error returned by processTraceUpload
+func respondWithUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors validators.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	respondWithError(w, r, uploadErrorStatus(err), err.Error())
+}
+
+// traceFileStore validates, scans and stores the file of an upload. It returns the
+// sanitized file name and the gs:// path of the stored object.
+type traceFileStore func(ctx context.Context) (string, string, error)
+
+// bufferedTraceFile stores a file received whole in the request
+func bufferedTraceFile(userID, originalName string, fileContent []byte) traceFileStore {
+	return func(ctx context.Context) (string, string, error) {
+		return storeTraceFile(ctx, userID, originalName, fileContent)
+	}
+}
+
+// processTraceUpload validates, scans and stores a single file, records the trace and
+// publishes the upload to Kafka. The course is expected to have been checked by the caller.
+func (h *Handler) processTraceUpload(ctx context.Context, userID, courseID string, traceReq models.TraceRequest, storeFile traceFileStore) (models.Trace, error) {
+	// add trace request validation from validators
+	if err := validators.ValidateTraceRequest(traceReq); err != nil {
+		return models.Trace{}, &uploadError{status: http.StatusBadRequest, message: err.Error(), err: err}
+	}
+
+	// check for instructorid and semesterterm existence
+	if _, err := h.instructors.GetInstructorByID(ctx, traceReq.InstructorID); err != nil {
+		logging.FromContext(ctx).Error("Error fetching instructor", "error", err)
+		return models.Trace{}, newUploadError(http.StatusBadRequest, "failed to get instructor")
+	}
+	if _, err := h.reference.GetSemesterTerm(ctx, traceReq.SemesterTerm); err != nil {
+		logging.FromContext(ctx).Error("Error fetching semester term", "error", err)
+		return models.Trace{}, newUploadError(http.StatusBadRequest, "failed to get semester term")
+	}
+
+	fileName, uploadedFilePath, err := storeFile(ctx)
+	if err != nil {
+		return models.Trace{}, err
+	}
+
+	trace := models.Trace{
+		TraceID:      uuid.New().String(),
+		UserID:       userID,
+		FileName:     fileName,
+		DateCreated:  time.Now().UTC(),
+		BucketPath:   uploadedFilePath,
+		CourseID:     courseID,
+		InstructorID: traceReq.InstructorID,
+		SemesterTerm: traceReq.SemesterTerm,
+		Section:      traceReq.Section,
+	}
+
+	// the trace, its first version, its stream event and its webhook deliveries are written
+	// together, and the stored file is removed again when they cannot be, so a failed upload
+	// leaves nothing behind
+	var newTrace models.Trace
+	err = h.store.WithTx(ctx, func(tx repositories.Store) error {
+		var err error
+		newTrace, err = tx.CreateTrace(ctx, trace)
+		if err != nil {
+			return err
+		}
+		return services.RecordTraceEvent(ctx, tx, traceUploadMessage(kafka.EventTraceUploaded, newTrace, 1))
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error creating trace", "error", err)
+		if err := utils.DeleteFileFromGCS(ctx, uploadedFilePath, os.Getenv("BUCKET_NAME")); err != nil {
+			logging.FromContext(ctx).Error("Error removing file of failed upload", "error", err)
+		}
+		return models.Trace{}, newUploadError(http.StatusInternalServerError, "failed to create trace")
+	}
+
+	services.WakeWebhookDispatcher()
+	services.SendTraceEventToKafka(ctx, traceUploadMessage(kafka.EventTraceUploaded, newTrace, 1))
+	return newTrace, nil
+}
+
+// storeTraceFile validates and scans the content, then uploads it to the bucket.
+// It returns the sanitized file name and the gs:// path of the stored object.
+func storeTraceFile(ctx context.Context, userID, originalName string, fileContent []byte) (string, string, error) {
+	outcome := "rejected"
+	defer func() { recordUploadSize(ctx, len(fileContent), outcome) }()
+
+	if err := validators.ValidateFileName(originalName); err != nil {
+		return "", "", newUploadError(http.StatusBadRequest, err.Error())
+	}
+	fileName := validators.SanitizeFileName(originalName)
+
+	policy := services.GetUploadPolicy()
+	inspector := validators.NewFileInspector()
+	inspector.Write(fileContent)
+	contentType, err := validateTraceFile(inspector, policy)
+	if err != nil {
+		return "", "", err
+	}
+
+	bucketName := os.Getenv("BUCKET_NAME")
+	if bucketName == "" {
+		logging.FromContext(ctx).Error("Bucket name is not set in environment variables")
+		outcome = "failed"
+		return "", "", newUploadError(http.StatusInternalServerError, "storage is not configured")
+	}
+
+	// Scan for malware before anything is stored under uploads/
+	if fileScanner := services.GetScanner(); fileScanner != nil {
+		result, err := fileScanner.Scan(ctx, bytes.NewReader(fileContent))
+		if err != nil {
+			logging.FromContext(ctx).Error("Error scanning file", "error", err)
+			outcome = "failed"
+			return "", "", newUploadError(http.StatusServiceUnavailable, "failed to scan file")
+		}
+		if result.Infected {
+			outcome = "infected"
+			logging.FromContext(ctx).Warn("Rejected infected upload", "file_name", fileName, "signature", result.Signature)
+			if _, err := utils.QuarantineFileInGCS(ctx, bytes.NewReader(fileContent), fileName, bucketName, policy.QuarantinePrefix, result.Signature); err != nil {
+				logging.FromContext(ctx).Error("Error quarantining file", "error", err)
+			}
+			return "", "", newUploadError(http.StatusUnprocessableEntity, "file failed malware scan")
+		}
+	}
+
+	uploadedFilePath, err := utils.UploadFileToGCS(ctx, bytes.NewReader(fileContent), fileName, bucketName, contentType)
+	if err != nil {
+		outcome = "failed"
+		return "", "", newUploadError(http.StatusInternalServerError, "failed to upload file")
+	}
+	outcome = "stored"
+	return fileName, uploadedFilePath, nil
+}
+
+// validateTraceFile checks an inspected file against the upload policy and returns its
+// content type
+func validateTraceFile(inspector *validators.FileInspector, policy services.UploadPolicy) (string, error) {
+	contentType, err := inspector.ValidateContent(policy.AllowedTypes)
+	if err != nil {
+		return "", newUploadError(http.StatusUnsupportedMediaType, err.Error())
+	}
+	if contentType == "application/pdf" {
+		if _, err := inspector.ValidatePDF(policy.MaxPDFPages); err != nil {
+			return "", newUploadError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	return contentType, nil
+}
+
+// publishTraceEvent sends a trace event to the event streams, to Kafka and to the webhooks
+// subscribed to it
+func (h *Handler) publishTraceEvent(ctx context.Context, eventType string, trace models.Trace, version int) {
+	services.PublishTraceEvent(ctx, h.store, traceUploadMessage(eventType, trace, version))
+}
+
+// traceUploadMessage describes a trace event
+func traceUploadMessage(eventType string, trace models.Trace, version int) kafka.TraceUploadMessage {
+	// Extract bucket name and path from GCS URL
+	return kafka.TraceUploadMessage{
+		EventType:    eventType,
+		Version:      version,
+		TraceID:      trace.TraceID,
+		CourseID:     trace.CourseID,
+		FileName:     trace.FileName,
+		GCSBucket:    utils.ExtractBucketNameFromGCS(trace.BucketPath),
+		GCSPath:      utils.ExtractFilePathFromGCS(trace.BucketPath),
+		InstructorID: trace.InstructorID,
+		SemesterTerm: trace.SemesterTerm,
+		Section:      trace.Section,
+		UploadedBy:   trace.UserID,
+		UploadedAt:   trace.DateCreated,
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-server/internal/kafka"
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+	"api-server/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// Extracts version from /v1/course/{courseId}/trace/{traceId}/versions/{version}
+func extractVersionNumber(path string) (int, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 8 || parts[7] == "" {
+		return 0, errors.New("version is required")
+	}
+	version, err := strconv.Atoi(parts[7])
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version number")
+	}
+	return version, nil
+}
+
+// loadCourseTrace validates the course and trace IDs in the path and returns the trace,
+// writing the error response itself when it returns false
+func (h *Handler) loadCourseTrace(w http.ResponseWriter, r *http.Request) (*models.Trace, bool) {
+	courseID := extractCourseID(r.URL.Path)
+	traceID := extractTraceID(r.URL.Path)
+	if courseID == "" || traceID == "" {
+		respondWithError(w, r, http.StatusNotFound, "")
+		return nil, false
+	}
+	if _, err := uuid.Parse(courseID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return nil, false
+	}
+	if _, err := uuid.Parse(traceID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return nil, false
+	}
+	if len(r.URL.Query()) > 0 {
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
+		return nil, false
+	}
+
+	if _, err := h.courses.GetCourseByID(r.Context(), courseID); err != nil {
+		respondWithRepositoryError(w, r, err, "course")
+		return nil, false
+	}
+	trace, err := h.traces.GetTraceByID(r.Context(), traceID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "trace")
+		return nil, false
+	}
+	if trace.CourseID != courseID {
+		respondWithError(w, r, http.StatusNotFound, "trace not found")
+		return nil, false
+	}
+	return trace, true
+}
+
+// loadTraceVersion resolves the {version} path segment for an already loaded trace
+func (h *Handler) loadTraceVersion(w http.ResponseWriter, r *http.Request, trace *models.Trace) (*models.TraceVersion, bool) {
+	versionNumber, err := extractVersionNumber(r.URL.Path)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	version, err := h.traces.GetTraceVersion(r.Context(), trace.TraceID, versionNumber)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			// traces uploaded before versioning only have their original file
+			if versionNumber == 1 {
+				return legacyTraceVersion(trace), true
+			}
+			respondWithError(w, r, http.StatusNotFound, "version not found")
+			return nil, false
+		}
+		logging.FromContext(r.Context()).Error("Error fetching trace version", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get trace version")
+		return nil, false
+	}
+	return version, true
+}
+
+func legacyTraceVersion(trace *models.Trace) *models.TraceVersion {
+	return &models.TraceVersion{
+		TraceID:       trace.TraceID,
+		VersionNumber: 1,
+		FileName:      trace.FileName,
+		BucketPath:    trace.BucketPath,
+		UserID:        trace.UserID,
+		DateCreated:   trace.DateCreated,
+	}
+}
+
+// ReplaceTraceFileHandler handles PUT /v1/course/{course_id}/trace/{trace_id}/file
+func (h *Handler) ReplaceTraceFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+		return
+	}
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse multipart form to handle file upload
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "failed to parse multipart form")
+		return
+	}
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error retrieving file", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to get file from request")
+		return
+	}
+	defer file.Close()
+	fileContent, err := io.ReadAll(file)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error reading file", "error", err)
+		respondWithError(w, r, http.StatusBadRequest, "failed to read file from request")
+		return
+	}
+
+	fileName, bucketPath, err := storeTraceFile(r.Context(), user.UserID, handler.Filename, fileContent)
+	if err != nil {
+		respondWithUploadError(w, r, err)
+		return
+	}
+
+	version, err := h.traces.AddTraceVersion(r.Context(), models.TraceVersion{
+		TraceID:     trace.TraceID,
+		FileName:    fileName,
+		BucketPath:  bucketPath,
+		UserID:      user.UserID,
+		DateCreated: time.Now().UTC(),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error adding trace version", "error", err)
+		if err := utils.DeleteFileFromGCS(r.Context(), bucketPath, os.Getenv("BUCKET_NAME")); err != nil {
+			logging.FromContext(r.Context()).Error("Error removing file of failed upload", "error", err)
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "failed to update trace")
+		return
+	}
+	logging.FromContext(r.Context()).Info("Trace file replaced", "trace_id", trace.TraceID, "version", version.VersionNumber)
+
+	trace.FileName = version.FileName
+	trace.BucketPath = version.BucketPath
+	h.publishTraceEvent(r.Context(), kafka.EventTraceFileReplaced, *trace, version.VersionNumber)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(version)
+}
+
+// GetTraceVersionsHandler handles GET /v1/course/{course_id}/trace/{trace_id}/versions
+func (h *Handler) GetTraceVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+	versions, err := h.traces.GetTraceVersions(r.Context(), trace.TraceID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching trace versions", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to get trace versions")
+		return
+	}
+	if len(versions) == 0 {
+		versions = append(versions, *legacyTraceVersion(trace))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// GetTraceVersionHandler handles GET /v1/course/{course_id}/trace/{trace_id}/versions/{version}
+func (h *Handler) GetTraceVersionHandler(w http.ResponseWriter, r *http.Request) {
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+	version, ok := h.loadTraceVersion(w, r, trace)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+// DownloadTraceVersionHandler handles GET /v1/course/{course_id}/trace/{trace_id}/versions/{version}/pdf
+func (h *Handler) DownloadTraceVersionHandler(w http.ResponseWriter, r *http.Request) {
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+	version, ok := h.loadTraceVersion(w, r, trace)
+	if !ok {
+		return
+	}
+	serveTraceFile(w, r, version.FileName, version.BucketPath)
+}
+
+// RollbackTraceVersionHandler handles POST /v1/course/{course_id}/trace/{trace_id}/versions/{version}/rollback.
+// The old file becomes a new version so the history is never rewritten.
+func (h *Handler) RollbackTraceVersionHandler(w http.ResponseWriter, r *http.Request) {
+	trace, ok := h.loadCourseTrace(w, r)
+	if !ok {
+		return
+	}
+	target, ok := h.loadTraceVersion(w, r, trace)
+	if !ok {
+		return
+	}
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if target.BucketPath == trace.BucketPath {
+		respondWithError(w, r, http.StatusConflict, "version is already current")
+		return
+	}
+
+	version, err := h.traces.AddTraceVersion(r.Context(), models.TraceVersion{
+		TraceID:     trace.TraceID,
+		FileName:    target.FileName,
+		BucketPath:  target.BucketPath,
+		UserID:      user.UserID,
+		DateCreated: time.Now().UTC(),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error rolling back trace", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to roll back trace")
+		return
+	}
+	logging.FromContext(r.Context()).Info("Trace rolled back", "trace_id", trace.TraceID, "target_version", target.VersionNumber, "version", version.VersionNumber)
+
+	trace.FileName = version.FileName
+	trace.BucketPath = version.BucketPath
+	h.publishTraceEvent(r.Context(), kafka.EventTraceFileReplaced, *trace, version.VersionNumber)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(version)
+}
@@ -1,15 +1,14 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"strings"
 
-	"api-server/internal/database"
+	"api-server/internal/logging"
 	"api-server/internal/models"
-	"api-server/internal/repositories"
+	"api-server/internal/problem"
 	"api-server/internal/validators"
 
 	"golang.org/x/crypto/bcrypt"
@@ -23,50 +22,45 @@ func extractUserID(path string) string {
 	return parts[3]
 }
 
-func UserHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UserHandler(w http.ResponseWriter, r *http.Request) {
 	userID := extractUserID(r.URL.Path)
 	if userID == "" {
-		http.NotFound(w, r)
+		respondWithError(w, r, http.StatusNotFound, "")
 		return
 	}
 
 	// Validate the user ID
 	if err := validators.ValidateUserID(userID); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		GetUserHandler(w, r, userID)
+		h.GetUserHandler(w, r, userID)
 	case http.MethodPut:
-		UpdateUserHandler(w, r, userID)
+		h.UpdateUserHandler(w, r, userID)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 	}
 }
 
-func GetUserHandler(w http.ResponseWriter, r *http.Request, userID string) {
+func (h *Handler) GetUserHandler(w http.ResponseWriter, r *http.Request, userID string) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate request body
 	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	user, err := repositories.GetUserByID(database.GetDB(), userID)
+	user, err := h.users.GetUserByID(r.Context(), userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNoContent)
-		} else {
-			log.Printf("Error retrieving user: %v", err)
-			w.WriteHeader(http.StatusServiceUnavailable)
-		}
+		respondWithRepositoryError(w, r, err, "user")
 		return
 	}
 
@@ -74,15 +68,15 @@ func GetUserHandler(w http.ResponseWriter, r *http.Request, userID string) {
 	json.NewEncoder(w).Encode(user)
 }
 
-func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
 		return
 	}
 
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -94,34 +88,33 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Validate user request
 	if err := validators.ValidateUserRequest(req); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	existingUser, err := repositories.GetUserByUsername(database.GetDB(), req.Username)
+	existingUser, err := h.users.GetUserByUsername(r.Context(), req.Username)
 	if err != nil {
-		log.Printf("Error checking existing user: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error checking existing user", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "")
 		return
 	}
 	if existingUser != nil {
-		respondWithError(w, http.StatusConflict, "user already exists")
+		respondWithError(w, r, http.StatusConflict, "user already exists")
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("Error hashing password: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error hashing password", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "")
 		return
 	}
 	req.Password = string(hashedPassword)
 
-	user, err := repositories.CreateUser(database.GetDB(), req)
+	user, err := h.users.CreateUser(r.Context(), req)
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithRepositoryError(w, r, err, "user")
 		return
 	}
 
@@ -130,34 +123,29 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
-func UpdateUserHandler(w http.ResponseWriter, r *http.Request, userID string) {
+func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request, userID string) {
 	// Validate query parameters
 	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Parse request body
 	var req map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && r.ContentLength > 0 {
-		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	// Validate update fields
 	if err := validators.ValidateUserUpdateFields(req); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	user, err := repositories.GetUserByID(database.GetDB(), userID)
+	user, err := h.users.GetUserByID(r.Context(), userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "user not found")
-		} else {
-			log.Printf("Error retrieving user: %v", err)
-			w.WriteHeader(http.StatusServiceUnavailable)
-		}
+		respondWithRepositoryError(w, r, err, "user")
 		return
 	}
 
@@ -173,24 +161,48 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request, userID string) {
 		// Hash the new password before updating it
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		if err != nil {
-			log.Printf("Error hashing password: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logging.FromContext(r.Context()).Error("Error hashing password", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "")
 			return
 		}
 		user.Password = string(hashedPassword)
 	}
 
-	if err := repositories.UpdateUser(database.GetDB(), user); err != nil {
-		log.Printf("Error updating user: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+	if err := h.users.UpdateUser(r.Context(), user); err != nil {
+		respondWithRepositoryError(w, r, err, "user")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+// respondWithError sends an application/problem+json error response with the provided status code
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	problem.Error(w, r, code, message)
+}
+
+// respondWithValidationError sends a validation problem listing every invalid field,
+// or a plain 400 problem for errors that are not about a specific field
+func respondWithValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors validators.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	problemErrors := make([]problem.FieldError, len(fieldErrors))
+	for i, fieldErr := range fieldErrors {
+		problemErrors[i] = problem.FieldError{
+			Field:   fieldErr.Field,
+			Pointer: fieldErr.Pointer,
+			Code:    fieldErr.Code,
+			Message: fieldErr.Message,
+		}
+	}
+	problem.Write(w, r, problem.Validation(err.Error(), problemErrors))
+}
+
+// respondWithRepositoryError maps a repository error to its problem response.
+// resource names the entity that was looked up, e.g. "course".
+func respondWithRepositoryError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	problem.WriteError(w, r, err, resource)
 }
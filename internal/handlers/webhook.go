@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/services"
+	"api-server/internal/validators"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 200
+)
+
+// CreateWebhookHandler handles POST /v1/webhooks. The signing secret is only returned here.
+func (h *Handler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if len(r.URL.Query()) > 0 {
+		respondWithError(w, r, http.StatusBadRequest, "query parameters are not allowed")
+		return
+	}
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validators.ValidateWebhookRequest(req); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	if !checkWebhookURL(w, r, req.URL) {
+		return
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if req.DepartmentID != nil {
+		if _, err := h.reference.GetDepartmentByID(r.Context(), *req.DepartmentID); err != nil {
+			logging.FromContext(r.Context()).Error("Error fetching department", "error", err)
+			respondWithError(w, r, http.StatusBadRequest, "department not found")
+			return
+		}
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating webhook secret", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	now := time.Now().UTC()
+	webhook, err := h.webhooks.CreateWebhook(r.Context(), models.WebhookSubscription{
+		WebhookID:    uuid.New().String(),
+		UserID:       user.UserID,
+		URL:          req.URL,
+		Secret:       secret,
+		EventTypes:   req.EventTypes,
+		DepartmentID: req.DepartmentID,
+		Description:  req.Description,
+		Active:       true,
+		DateCreated:  now,
+		DateUpdated:  now,
+	})
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "webhook")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/webhooks/"+webhook.WebhookID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// ListWebhooksHandler handles GET /v1/webhooks
+func (h *Handler) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhooks, err := h.webhooks.GetWebhooksByUser(r.Context(), user.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error retrieving webhooks", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// WebhookHandler handles GET, PATCH and DELETE /v1/webhooks/{webhook_id}
+func (h *Handler) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	webhook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		webhook.Secret = ""
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhook)
+	case http.MethodPatch:
+		h.PatchWebhookHandler(w, r, webhook)
+	case http.MethodDelete:
+		if err := h.webhooks.DeleteWebhook(r.Context(), webhook.WebhookID); err != nil {
+			respondWithRepositoryError(w, r, err, "webhook")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondWithError(w, r, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// PatchWebhookHandler changes a subscription. Re-enabling a disabled subscription clears
+// its failures and lets its pending deliveries be sent again.
+func (h *Handler) PatchWebhookHandler(w http.ResponseWriter, r *http.Request, webhook *models.WebhookSubscription) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validators.ValidateWebhookPatchFields(fields); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	// apply the validated patch onto the current values
+	req := models.WebhookPatchRequest{
+		URL:          webhook.URL,
+		EventTypes:   webhook.EventTypes,
+		DepartmentID: webhook.DepartmentID,
+		Description:  webhook.Description,
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.URL != webhook.URL && !checkWebhookURL(w, r, req.URL) {
+		return
+	}
+
+	if req.DepartmentID != nil && (webhook.DepartmentID == nil || *req.DepartmentID != *webhook.DepartmentID) {
+		if _, err := h.reference.GetDepartmentByID(r.Context(), *req.DepartmentID); err != nil {
+			logging.FromContext(r.Context()).Error("Error fetching department", "error", err)
+			respondWithError(w, r, http.StatusBadRequest, "department not found")
+			return
+		}
+	}
+
+	webhook.URL = req.URL
+	webhook.EventTypes = req.EventTypes
+	webhook.DepartmentID = req.DepartmentID
+	webhook.Description = req.Description
+	if req.Active != nil {
+		if *req.Active && !webhook.Active {
+			webhook.FailureCount = 0
+			webhook.DisabledReason = nil
+		}
+		webhook.Active = *req.Active
+	}
+	webhook.DateUpdated = time.Now().UTC()
+	if err := h.webhooks.UpdateWebhook(r.Context(), webhook); err != nil {
+		respondWithRepositoryError(w, r, err, "webhook")
+		return
+	}
+
+	webhook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// ListWebhookDeliveriesHandler handles GET /v1/webhooks/{webhook_id}/deliveries?status=...&limit=...
+func (h *Handler) ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := validators.ValidateReportParameters(query, "status", "limit"); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := query.Get("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		respondWithError(w, r, http.StatusBadRequest, "status must be pending, succeeded or failed")
+		return
+	}
+	limit := defaultWebhookDeliveryLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxWebhookDeliveryLimit {
+			respondWithError(w, r, http.StatusBadRequest, "limit must be an integer between 1 and 200")
+			return
+		}
+		limit = parsed
+	}
+
+	webhook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := h.webhooks.GetWebhookDeliveries(r.Context(), webhook.WebhookID, status, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error retrieving webhook deliveries", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReplayWebhookDeliveryHandler handles POST /v1/webhooks/{webhook_id}/deliveries/{delivery_id}/replay.
+// The event is queued again as a new delivery, signed with the current secret.
+func (h *Handler) ReplayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	deliveryID := extractPathSegment(r.URL.Path, 5)
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return
+	}
+	if err := validators.ValidateRequestParameters(r.URL.Query()); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validators.ValidateRequestBody(r.ContentLength, false); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	webhook, ok := h.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	original, err := h.webhooks.GetWebhookDelivery(r.Context(), webhook.WebhookID, deliveryID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "delivery")
+		return
+	}
+	delivery, err := services.ReplayWebhookDelivery(r.Context(), h.webhooks, *original)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "delivery")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// checkWebhookURL responds with a validation error and returns false if url is not an
+// https URL on a public address
+func checkWebhookURL(w http.ResponseWriter, r *http.Request, url string) bool {
+	if err := services.CheckWebhookURL(r.Context(), url); err != nil {
+		var errs validators.ValidationErrors
+		errs.Add("url", "url", err.Error())
+		respondWithValidationError(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// loadWebhook validates the webhook ID in the path and returns the subscription if it
+// belongs to the authenticated user, writing the error response itself when it returns false
+func (h *Handler) loadWebhook(w http.ResponseWriter, r *http.Request) (*models.WebhookSubscription, bool) {
+	webhookID := extractPathSegment(r.URL.Path, 3)
+	if _, err := uuid.Parse(webhookID); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "invalid UUID format")
+		return nil, false
+	}
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	webhook, err := h.webhooks.GetWebhook(r.Context(), webhookID)
+	if err != nil {
+		respondWithRepositoryError(w, r, err, "webhook")
+		return nil, false
+	}
+	// subscriptions of other users are not disclosed
+	if webhook.UserID != user.UserID {
+		respondWithError(w, r, http.StatusNotFound, "webhook not found")
+		return nil, false
+	}
+	return webhook, true
+}
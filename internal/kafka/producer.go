@@ -4,20 +4,50 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl/plain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"api-server/internal/logging"
 )
 
+// instrument names are fixed, so creating them cannot fail
+var publishDuration, _ = otel.Meter("api-server/kafka").Float64Histogram("messaging.publish.duration",
+	metric.WithDescription("Duration of publishing an event to Kafka"),
+	metric.WithUnit("s"))
+
 type Producer struct {
-	writer *kafka.Writer
-	topic  string
+	writer  *kafka.Writer
+	dialer  *kafka.Dialer
+	brokers []string
+	topic   string
 }
 
+// Trace event types carried in TraceUploadMessage.EventType
+const (
+	EventTraceUploaded         = "trace.uploaded"
+	EventTraceFileReplaced     = "trace.file_replaced"
+	EventTraceUpdated          = "trace.updated"
+	EventTraceResultsProcessed = "trace.results_processed"
+	EventTraceDeleted          = "trace.deleted"
+)
+
+// Course event types carried in CourseMessage.EventType
+const (
+	EventCourseCreated = "course.created"
+	EventCourseUpdated = "course.updated"
+	EventCourseDeleted = "course.deleted"
+)
+
 // Metadata for an uploaded trace survey
 type TraceUploadMessage struct {
+	EventType    string    `json:"eventType"`
+	Version      int       `json:"version,omitempty"`
 	TraceID      string    `json:"traceId"`
 	CourseID     string    `json:"courseId"`
 	FileName     string    `json:"fileName"`
@@ -30,6 +60,19 @@ type TraceUploadMessage struct {
 	UploadedAt   time.Time `json:"uploadedAt"`
 }
 
+// Metadata for a created, updated or deleted course
+type CourseMessage struct {
+	EventType    string    `json:"eventType"`
+	CourseID     string    `json:"courseId"`
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	InstructorID string    `json:"instructorId"`
+	DepartmentID int       `json:"departmentId"`
+	CreditHours  int       `json:"creditHours"`
+	UpdatedBy    string    `json:"updatedBy"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
 // New Kafka producer
 func NewProducer(brokers []string, topic, username, password string, enableAuth bool) (*Producer, error) {
 	// Basic writer configuration
@@ -43,6 +86,8 @@ func NewProducer(brokers []string, topic, username, password string, enableAuth
 		// Logger:       kafka.LoggerFunc(logf), // Uncomment for debugging
 	}
 
+	dialer := kafka.DefaultDialer
+
 	// Add authentication if enabled
 	if enableAuth {
 		if username == "" || password == "" {
@@ -54,7 +99,7 @@ func NewProducer(brokers []string, topic, username, password string, enableAuth
 			Password: password,
 		}
 
-		dialer := &kafka.Dialer{
+		dialer = &kafka.Dialer{
 			Timeout:       10 * time.Second,
 			DualStack:     true,
 			SASLMechanism: mechanism,
@@ -62,40 +107,83 @@ func NewProducer(brokers []string, topic, username, password string, enableAuth
 		}
 
 		writerConfig.Dialer = dialer
-		log.Println("Kafka SASL authentication enabled")
+		slog.Info("Kafka SASL authentication enabled")
 	}
 
 	writer := kafka.NewWriter(writerConfig)
 
 	return &Producer{
-		writer: writer,
-		topic:  topic,
+		writer:  writer,
+		dialer:  dialer,
+		brokers: brokers,
+		topic:   topic,
 	}, nil
 }
 
+// Ping connects to the brokers in turn and succeeds once one accepts the connection
+func (p *Producer) Ping(ctx context.Context) error {
+	var err error
+	for _, broker := range p.brokers {
+		var conn *kafka.Conn
+		conn, err = p.dialer.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+	}
+	return fmt.Errorf("no broker reachable: %w", err)
+}
+
 // Send a trace survey upload notification to Kafka
 func (p *Producer) PublishTraceUpload(ctx context.Context, message TraceUploadMessage) error {
+	if err := p.publish(ctx, message.TraceID, message.EventType, message); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Debug("Published trace message to Kafka", "event_type", message.EventType, "trace_id", message.TraceID)
+	return nil
+}
+
+// Send a course change notification to Kafka
+func (p *Producer) PublishCourseEvent(ctx context.Context, message CourseMessage) error {
+	if err := p.publish(ctx, message.CourseID, message.EventType, message); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Debug("Published course message to Kafka", "event_type", message.EventType, "course_id", message.CourseID)
+	return nil
+}
+
+func (p *Producer) publish(ctx context.Context, key, eventType string, message interface{}) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("error marshaling message: %w", err)
 	}
 
+	start := time.Now()
 	err = p.writer.WriteMessages(ctx, kafka.Message{
-		Key:   []byte(message.TraceID),
+		Key:   []byte(key),
 		Value: data,
 		Time:  time.Now(),
 
 		Headers: []kafka.Header{
 			{Key: "content-type", Value: []byte("application/json")},
 			{Key: "source", Value: []byte("api-server")},
+			{Key: "event-type", Value: []byte(eventType)},
 		},
 	})
 
+	outcome := "success"
 	if err != nil {
-		return fmt.Errorf("error writing message to Kafka: %w", err)
+		outcome = "error"
 	}
+	publishDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", p.topic),
+		attribute.String("event.type", eventType),
+		attribute.String("outcome", outcome),
+	))
 
-	log.Printf("Published trace upload message to Kafka for trace ID: %s", message.TraceID)
+	if err != nil {
+		return fmt.Errorf("error writing message to Kafka: %w", err)
+	}
 	return nil
 }
 
@@ -108,6 +196,6 @@ func (p *Producer) Close() error {
 }
 
 // Helper function for debugging
-func logf(fmt string, args ...interface{}) {
-	log.Printf(fmt, args...)
+func logf(format string, args ...interface{}) {
+	slog.Debug(fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type component struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+var (
+	components     []component
+	componentsLock sync.Mutex
+	ready          atomic.Bool
+)
+
+// Register adds a component to stop on shutdown. Components are stopped in the reverse
+// order they were registered in, so a component can rely on everything started before it.
+func Register(name string, stop func(ctx context.Context) error) {
+	componentsLock.Lock()
+	defer componentsLock.Unlock()
+	components = append(components, component{name: name, stop: stop})
+}
+
+// RegisterFunc registers a component whose stop function cannot fail
+func RegisterFunc(name string, stop func()) {
+	Register(name, func(context.Context) error {
+		stop()
+		return nil
+	})
+}
+
+// SetReady marks whether the server should receive traffic
+func SetReady(isReady bool) {
+	ready.Store(isReady)
+}
+
+// Ready reports whether the server should receive traffic
+func Ready() bool {
+	return ready.Load()
+}
+
+// Shutdown marks the server not ready and stops every registered component, newest
+// first. Every component is stopped even when an earlier one fails or ctx expires;
+// the errors are returned together.
+func Shutdown(ctx context.Context) error {
+	SetReady(false)
+
+	componentsLock.Lock()
+	stopping := components
+	components = nil
+	componentsLock.Unlock()
+
+	var errs []error
+	for i := len(stopping) - 1; i >= 0; i-- {
+		c := stopping[i]
+		start := time.Now()
+		if err := c.stop(ctx); err != nil {
+			slog.Error("Error stopping component", "component", c.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		slog.Info("Stopped component", "component", c.name, "duration_ms", time.Since(start).Milliseconds())
+	}
+	return errors.Join(errs...)
+}
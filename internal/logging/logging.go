@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Policy holds how log lines are written
+type Policy struct {
+	Level slog.Level
+	// RedactKeys hides the value of every attribute whose key contains one of them,
+	// compared case-insensitively, e.g. "password" also hides "kafka_password"
+	RedactKeys []string
+	// RedactBucketPaths hides gs:// object paths and bucket_path and object_path attributes
+	RedactBucketPaths bool
+}
+
+const redacted = "[REDACTED]"
+
+var (
+	level             slog.LevelVar
+	bucketPathPattern = regexp.MustCompile(`gs://[^\s"']+`)
+)
+
+// Init makes the default logger write JSON lines to w. Lines written through the log
+// package are written by it as well, at the info level.
+func Init(w io.Writer, policy Policy) {
+	level.Set(policy.Level)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       &level,
+		ReplaceAttr: redactor(policy),
+	})))
+}
+
+// SetLevel changes the minimum level written, taking effect immediately
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Level returns the minimum level written
+func Level() slog.Level {
+	return level.Level()
+}
+
+// ParseLevel parses debug, info, warn or error, optionally with an offset such as info+2
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+func redactor(policy Policy) func(groups []string, a slog.Attr) slog.Attr {
+	keys := make([]string, 0, len(policy.RedactKeys)+2)
+	for _, key := range policy.RedactKeys {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if policy.RedactBucketPaths {
+		keys = append(keys, "bucket_path", "object_path")
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		key := strings.ToLower(a.Key)
+		for _, k := range keys {
+			if strings.Contains(key, k) {
+				return slog.String(a.Key, redacted)
+			}
+		}
+		if !policy.RedactBucketPaths {
+			return a
+		}
+		// errors of the storage client name the object they failed on
+		var value string
+		switch v := a.Value.Any().(type) {
+		case string:
+			value = v
+		case error:
+			value = v.Error()
+		default:
+			return a
+		}
+		if strings.Contains(value, "gs://") {
+			return slog.String(a.Key, bucketPathPattern.ReplaceAllString(value, "gs://"+redacted))
+		}
+		return a
+	}
+}
+
+// requestLogger is shared by everything handling one request, so attributes added
+// deep in the handlers also appear on the access log line written at the end
+type requestLogger struct {
+	mu     sync.RWMutex
+	logger *slog.Logger
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger for FromContext
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestLogger{logger: logger})
+}
+
+// FromContext returns the logger of the current request, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if rl, ok := ctx.Value(contextKey{}).(*requestLogger); ok {
+		rl.mu.RLock()
+		defer rl.mu.RUnlock()
+		return rl.logger
+	}
+	return slog.Default()
+}
+
+// AddAttrs adds attributes to every later line logged for the request ctx belongs to.
+// It does nothing outside a request.
+func AddAttrs(ctx context.Context, args ...any) {
+	if rl, ok := ctx.Value(contextKey{}).(*requestLogger); ok {
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+		rl.logger = rl.logger.With(args...)
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/utils"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// probes and scrapes arrive every few seconds, so they are only logged at debug level
+var quietPaths = map[string]bool{
+	"/livez":   true,
+	"/readyz":  true,
+	"/healthz": true,
+	"/metrics": true,
+}
+
+// AccessLogMiddleware gives every request a logger carrying its request and trace IDs,
+// and logs one line per request once it is answered. It must run inside
+// RequestIDMiddleware and the OpenTelemetry handler.
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logger := slog.Default().With("request_id", utils.RequestIDFromContext(r.Context()))
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String(), "span_id", span.SpanID().String())
+		}
+		ctx := logging.WithLogger(r.Context(), logger)
+
+		rec := &accessLogRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case quietPaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// accessLogRecorder captures the status and size of a response as it is written
+type accessLogRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *accessLogRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *accessLogRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps event streams working through the recorder
+func (rec *accessLogRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection's writer
+func (rec *accessLogRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
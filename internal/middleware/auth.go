@@ -1,45 +1,68 @@
 package middleware
 
 import (
-	"api-server/internal/database"
+	"api-server/internal/logging"
+	"api-server/internal/problem"
 	"api-server/internal/repositories"
 	"context"
-	"database/sql"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// UserLookup finds the user a request authenticates as, with its password hash
+type UserLookup interface {
+	GetUserWithPasswordByUsername(ctx context.Context, username string) (*repositories.UserWithPassword, error)
+}
+
+var (
+	userLookup     UserLookup
+	userLookupLock sync.RWMutex
+)
+
+// SetUserLookup sets where AuthMiddleware looks up users, usually the repositories.Store
+// the handlers use
+func SetUserLookup(lookup UserLookup) {
+	userLookupLock.Lock()
+	defer userLookupLock.Unlock()
+	userLookup = lookup
+}
+
 // AuthMiddleware wraps handlers requiring authentication
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			respondWithError(w, http.StatusUnauthorized, "Authorization required")
+			recordAuthFailure(r.Context(), "basic", "missing_credentials")
+			respondWithError(w, r, http.StatusUnauthorized, "Authorization required")
 			return
 		}
 
 		// Check if it's Basic auth
 		if !strings.HasPrefix(authHeader, "Basic ") {
-			respondWithError(w, http.StatusUnauthorized, "Invalid authorization method")
+			recordAuthFailure(r.Context(), "basic", "invalid_scheme")
+			respondWithError(w, r, http.StatusUnauthorized, "Invalid authorization method")
 			return
 		}
 
 		// Decode credentials
 		credentials, err := base64.StdEncoding.DecodeString(authHeader[6:])
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid authorization format")
+			recordAuthFailure(r.Context(), "basic", "malformed_credentials")
+			respondWithError(w, r, http.StatusUnauthorized, "Invalid authorization format")
 			return
 		}
 
 		// Split username and password
 		pair := strings.SplitN(string(credentials), ":", 2)
 		if len(pair) != 2 {
-			respondWithError(w, http.StatusUnauthorized, "Invalid authorization format")
+			recordAuthFailure(r.Context(), "basic", "malformed_credentials")
+			respondWithError(w, r, http.StatusUnauthorized, "Invalid authorization format")
 			return
 		}
 
@@ -47,9 +70,10 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		password := pair[1]
 
 		// Authenticate user
-		user, err := authenticateUser(username, password)
+		user, err := authenticateUser(r.Context(), username, password)
 		if err != nil || user == nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+			recordAuthFailure(r.Context(), "basic", "invalid_credentials")
+			respondWithError(w, r, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
 
@@ -57,12 +81,14 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		if strings.HasPrefix(r.URL.Path, "/v1/user/") {
 			userIDFromPath := extractUserIDFromPath(r.URL.Path)
 			if userIDFromPath == "" {
-				respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				recordAuthFailure(r.Context(), "basic", "forbidden")
+				respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 
 			if user.UserID != userIDFromPath {
-				respondWithError(w, http.StatusForbidden, "Forbidden")
+				recordAuthFailure(r.Context(), "basic", "forbidden")
+				respondWithError(w, r, http.StatusForbidden, "Forbidden")
 				return
 			}
 		}
@@ -74,13 +100,18 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 }
 
 // authenticateUser validates username/password and returns user if valid
-func authenticateUser(username, password string) (*repositories.UserWithPassword, error) {
-	db := database.GetDB()
+func authenticateUser(ctx context.Context, username, password string) (*repositories.UserWithPassword, error) {
+	userLookupLock.RLock()
+	lookup := userLookup
+	userLookupLock.RUnlock()
+	if lookup == nil {
+		return nil, errors.New("user lookup is not configured")
+	}
 
 	// Get user with password
-	user, err := repositories.GetUserWithPasswordByUsername(db, username)
+	user, err := lookup.GetUserWithPasswordByUsername(ctx, username)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repositories.ErrNotFound) {
 			return nil, nil
 		}
 		return nil, err
@@ -112,7 +143,9 @@ const userContextKey contextKey = "user"
 
 // setUserContext adds user to request context
 func setUserContext(r *http.Request, user *repositories.UserWithPassword) *http.Request {
-	ctx := r.Context()
+	// the user's reads follow their own writes to the primary
+	ctx := repositories.WithSession(r.Context(), user.UserID)
+	logging.AddAttrs(ctx, "user_id", user.UserID)
 	return r.WithContext(context.WithValue(ctx, userContextKey, user))
 }
 
@@ -124,9 +157,7 @@ func GetUserFromContext(r *http.Request) *repositories.UserWithPassword {
 	return nil
 }
 
-// respondWithError sends a JSON error response with the provided status code
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+// respondWithError sends an application/problem+json error response with the provided status code
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	problem.Error(w, r, code, message)
 }
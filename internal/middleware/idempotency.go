@@ -0,0 +1,366 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+)
+
+const (
+	// IdempotencyKeyHeader is sent by clients that may retry a POST
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks responses replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// bodies up to this size are fingerprinted in memory, larger ones are spooled to disk
+	maxInMemoryBody = 1 << 20
+	// responses larger than this are not stored, so retries run the request again
+	maxStoredResponse = 1 << 20
+	// bounds the writes that release or complete a key after the request is done
+	idempotencyWriteTimeout = 5 * time.Second
+)
+
+var (
+	idempotencyStore   repositories.IdempotencyStore
+	idempotencyTTL     = 24 * time.Hour
+	idempotencyLease   = 30 * time.Second
+	idempotencyTTLLock sync.RWMutex
+)
+
+// SetIdempotencyStore sets where IdempotencyMiddleware keeps the responses of requests,
+// usually the repositories.Store the handlers use. Without a store requests are not deduplicated.
+func SetIdempotencyStore(store repositories.IdempotencyStore) {
+	idempotencyTTLLock.Lock()
+	defer idempotencyTTLLock.Unlock()
+	idempotencyStore = store
+}
+
+// SetIdempotencyKeyTTL sets how long the response to an idempotent request is replayed
+func SetIdempotencyKeyTTL(ttl time.Duration) {
+	idempotencyTTLLock.Lock()
+	defer idempotencyTTLLock.Unlock()
+	idempotencyTTL = ttl
+}
+
+// SetIdempotencyLease sets how long a running request holds its key before another request
+// may take it over. The lease is renewed while the request runs, so it only runs out when
+// the replica serving the request died.
+func SetIdempotencyLease(lease time.Duration) {
+	idempotencyTTLLock.Lock()
+	defer idempotencyTTLLock.Unlock()
+	idempotencyLease = lease
+}
+
+// IdempotencyMiddleware makes POST handlers safe to retry. The first response to a request
+// with an Idempotency-Key header is stored for the authenticated user, key and request
+// fingerprint; retries get the stored response and duplicates that arrive while the first
+// request is still running get 409. Server errors are not stored so they can be retried.
+// A running request holds its key for a short lease that is renewed until it completes.
+func IdempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyTTLLock.RLock()
+		store, ttl, lease := idempotencyStore, idempotencyTTL, idempotencyLease
+		idempotencyTTLLock.RUnlock()
+
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" || store == nil {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength || strings.TrimSpace(key) != key {
+			respondWithError(w, r, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters without surrounding spaces")
+			return
+		}
+
+		body, cleanup, err := bufferBody(r)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		defer cleanup()
+		fingerprint, err := requestFingerprint(r, body)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+
+		userID := ""
+		if user := GetUserFromContext(r); user != nil {
+			userID = user.UserID
+		}
+		now := time.Now().UTC()
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Status:      models.IdempotencyStatusInProgress,
+			DateCreated: now,
+			ExpiresAt:   now.Add(lease),
+		}
+
+		reserved, err := store.ReserveIdempotencyKey(r.Context(), record)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error reserving idempotency key", "error", err)
+			respondWithError(w, r, http.StatusServiceUnavailable, "")
+			return
+		}
+		if !reserved {
+			replayIdempotentResponse(w, r, store, userID, key, fingerprint)
+			return
+		}
+
+		// the key is released or completed after the handler returned, even if the client
+		// went away and cancelled the request context
+		writeCtx := context.WithoutCancel(r.Context())
+		release := func() {
+			ctx, cancel := context.WithTimeout(writeCtx, idempotencyWriteTimeout)
+			defer cancel()
+			if err := store.DeleteIdempotencyKey(ctx, userID, key, fingerprint); err != nil {
+				logging.FromContext(r.Context()).Error("Error releasing idempotency key", "error", err)
+			}
+		}
+
+		stopRenewing := renewIdempotencyLease(writeCtx, store, userID, key, fingerprint, lease)
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			// release the key if the handler panicked so the request can be retried
+			if !completed {
+				stopRenewing()
+				release()
+			}
+		}()
+		next(recorder, r)
+		completed = true
+		stopRenewing()
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if recorder.status >= http.StatusInternalServerError || recorder.overflow {
+			release()
+			return
+		}
+		record.ResponseStatus = recorder.status
+		record.ResponseHeaders = recorder.header
+		record.ResponseBody = recorder.body.Bytes()
+		record.ExpiresAt = time.Now().UTC().Add(ttl)
+		ctx, cancel := context.WithTimeout(writeCtx, idempotencyWriteTimeout)
+		defer cancel()
+		if err := store.CompleteIdempotencyKey(ctx, record); err != nil {
+			logging.FromContext(r.Context()).Error("Error storing idempotent response", "error", err)
+		}
+	}
+}
+
+// renewIdempotencyLease extends the lease on a key every third of the lease until the
+// returned function is called, which waits for a renewal in flight to finish
+func renewIdempotencyLease(ctx context.Context, store repositories.IdempotencyStore, userID, key, fingerprint string, lease time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewCtx, cancel := context.WithTimeout(ctx, idempotencyWriteTimeout)
+				err := store.RenewIdempotencyKey(renewCtx, userID, key, fingerprint, time.Now().UTC().Add(lease))
+				cancel()
+				if err != nil {
+					logging.FromContext(ctx).Warn("Error renewing idempotency key lease", "error", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// replayIdempotentResponse sends the stored response of an earlier request
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, store repositories.IdempotencyStore, userID, key, fingerprint string) {
+	stored, err := store.GetIdempotencyKey(r.Context(), userID, key, fingerprint)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			// the first request failed and released the key in the meantime
+			respondWithError(w, r, http.StatusConflict, "a request with this Idempotency-Key was just released, retry it")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Error fetching idempotency key", "error", err)
+		respondWithError(w, r, http.StatusServiceUnavailable, "")
+		return
+	}
+	if stored.Status != models.IdempotencyStatusCompleted {
+		w.Header().Set("Retry-After", "1")
+		respondWithError(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	for name, values := range stored.ResponseHeaders {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.ResponseStatus)
+	w.Write(stored.ResponseBody)
+}
+
+// bufferBody replaces the request body with a rewindable copy so it can be fingerprinted
+// and still be read by the handler. The returned function removes any spooled file.
+func bufferBody(r *http.Request) (io.ReadSeeker, func(), error) {
+	noop := func() {}
+	if r.Body == nil || r.Body == http.NoBody {
+		body := bytes.NewReader(nil)
+		r.Body = io.NopCloser(body)
+		return body, noop, nil
+	}
+	defer r.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxInMemoryBody+1))
+	if err != nil {
+		return nil, noop, err
+	}
+	if len(data) <= maxInMemoryBody {
+		body := bytes.NewReader(data)
+		r.Body = io.NopCloser(body)
+		return body, noop, nil
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, noop, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		_, err = io.Copy(file, r.Body)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+	r.Body = io.NopCloser(file)
+	return file, cleanup, nil
+}
+
+// requestFingerprint hashes the method, URL and body of a buffered request and rewinds the body.
+// Multipart bodies are hashed part by part since clients pick a new boundary on every retry.
+func requestFingerprint(r *http.Request, body io.ReadSeeker) (string, error) {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	prefix := r.Method + " " + r.URL.RequestURI() + "\n" + mediaType + "\n"
+
+	hash := sha256.New()
+	io.WriteString(hash, prefix)
+	hashed := false
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		hashed = hashMultipart(hash, multipart.NewReader(body, params["boundary"])) == nil
+		if !hashed {
+			// malformed multipart bodies are hashed as they are
+			hash.Reset()
+			io.WriteString(hash, prefix)
+		}
+	}
+	if !hashed {
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", err
+		}
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashMultipart(hash io.Writer, reader *multipart.Reader) error {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		io.WriteString(hash, "--"+part.FormName()+"\x00"+part.FileName()+"\x00"+part.Header.Get("Content-Type")+"\n")
+		if _, err := io.Copy(hash, part); err != nil {
+			return err
+		}
+	}
+}
+
+// idempotencyRecorder passes a response through while keeping a copy to store
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+		// replays carry the request ID of the retry, not of the first request
+		rec.header.Del(RequestIDHeader)
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(data) > maxStoredResponse {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(data)
+		}
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *idempotencyRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection's writer
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
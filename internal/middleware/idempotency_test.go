@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"api-server/internal/repositories/memory"
+)
+
+// useIdempotencyStore points the middleware at a new memory store for the test
+func useIdempotencyStore(t *testing.T) *memory.Store {
+	t.Helper()
+	store := memory.NewStore()
+	SetIdempotencyStore(store)
+	t.Cleanup(func() { SetIdempotencyStore(nil) })
+	return store
+}
+
+func postWithKey(handler http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/course", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysTheStoredResponse(t *testing.T) {
+	useIdempotencyStore(t)
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Location", "/v1/course/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"course_id":"1"}`))
+	})
+
+	first := postWithKey(handler, "key-1", `{"code":"CS101"}`)
+	second := postWithKey(handler, "key-1", `{"code":"CS101"}`)
+
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want once", calls.Load())
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("the first response is marked as replayed")
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != `{"course_id":"1"}` {
+		t.Errorf("replay = %d %s, want the stored 201 response", second.Code, second.Body)
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" || second.Header().Get("Location") != "/v1/course/1" {
+		t.Errorf("replay headers = %v, want the stored headers and %s", second.Header(), IdempotentReplayedHeader)
+	}
+}
+
+func TestIdempotencyRejectsDuplicatesWhileInProgress(t *testing.T) {
+	useIdempotencyStore(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postWithKey(handler, "key-1", `{}`) }()
+	<-started
+
+	duplicate := postWithKey(handler, "key-1", `{}`)
+	close(release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("first request: status %d, want 201", first.Code)
+	}
+	if duplicate.Code != http.StatusConflict || duplicate.Header().Get("Retry-After") == "" {
+		t.Errorf("duplicate: status %d, Retry-After %q; want 409 with Retry-After", duplicate.Code, duplicate.Header().Get("Retry-After"))
+	}
+}
+
+func TestIdempotencyReleasesTheKeyAfterAServerError(t *testing.T) {
+	useIdempotencyStore(t)
+	statuses := []int{http.StatusServiceUnavailable, http.StatusCreated}
+	var calls atomic.Int32
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[calls.Add(1)-1])
+	})
+
+	if rec := postWithKey(handler, "key-1", `{}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first attempt: status %d, want 503", rec.Code)
+	}
+	retry := postWithKey(handler, "key-1", `{}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry: status %d, replayed %q; want the request to run again", retry.Code, retry.Header().Get(IdempotentReplayedHeader))
+	}
+	if calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want twice", calls.Load())
+	}
+}
+
+func TestIdempotencyRunsADifferentBodyUnderTheSameKey(t *testing.T) {
+	useIdempotencyStore(t)
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+
+	postWithKey(handler, "key-1", `{"code":"CS101"}`)
+	other := postWithKey(handler, "key-1", `{"code":"CS102"}`)
+	if other.Header().Get(IdempotentReplayedHeader) != "" || other.Body.String() != `{"code":"CS102"}` {
+		t.Errorf("different body: replayed %q, body %s; want the request run with its own body", other.Header().Get(IdempotentReplayedHeader), other.Body)
+	}
+	if replay := postWithKey(handler, "key-1", `{"code":"CS101"}`); replay.Header().Get(IdempotentReplayedHeader) != "true" || replay.Body.String() != `{"code":"CS101"}` {
+		t.Errorf("original body: replayed %q, body %s; want the first response", replay.Header().Get(IdempotentReplayedHeader), replay.Body)
+	}
+}
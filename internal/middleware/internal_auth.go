@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	internalAPIToken     string
+	internalAPITokenLock sync.RWMutex
+)
+
+// SetInternalAPIToken sets the shared secret used by other services to call internal routes
+func SetInternalAPIToken(token string) {
+	internalAPITokenLock.Lock()
+	defer internalAPITokenLock.Unlock()
+	internalAPIToken = token
+}
+
+// InternalAuthMiddleware wraps handlers that are only called by other services.
+// Callers authenticate with "Authorization: Bearer <INTERNAL_API_TOKEN>".
+func InternalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		internalAPITokenLock.RLock()
+		token := internalAPIToken
+		internalAPITokenLock.RUnlock()
+
+		// Internal routes are disabled until a token is configured
+		if token == "" {
+			respondWithError(w, r, http.StatusServiceUnavailable, "Internal API is not configured")
+			return
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			recordAuthFailure(r.Context(), "internal", "missing_credentials")
+			respondWithError(w, r, http.StatusUnauthorized, "Authorization required")
+			return
+		}
+		provided := strings.TrimPrefix(authHeader, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			recordAuthFailure(r.Context(), "internal", "invalid_credentials")
+			respondWithError(w, r, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var meter = otel.Meter("api-server/middleware")
+
+// instrument names are fixed, so creating them cannot fail
+var authFailures, _ = meter.Int64Counter("auth.failures",
+	metric.WithDescription("Requests rejected by authentication or authorization"),
+	metric.WithUnit("{request}"))
+
+// recordAuthFailure counts a rejected request by the reason it was rejected for
+func recordAuthFailure(ctx context.Context, scheme, reason string) {
+	authFailures.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("auth.scheme", scheme),
+		attribute.String("reason", reason),
+	))
+}
+
+// RouteMetricsMiddleware labels the HTTP metrics and span of a request with its route
+// template, e.g. /v1/course/{course_id}, instead of the path with its IDs
+func RouteMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+					labeler.Add(semconv.HTTPRouteKey.String(template))
+				}
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(semconv.HTTPRouteKey.String(template))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
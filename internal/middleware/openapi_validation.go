@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"api-server/internal/logging"
+	"api-server/internal/openapi"
+	"api-server/internal/problem"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	validateRequests  = true
+	validateResponses = false
+	openAPILock       sync.RWMutex
+)
+
+// SetOpenAPIValidation enables request and response validation against the OpenAPI document.
+// Response validation buffers every response and is meant for tests and CI.
+func SetOpenAPIValidation(requests, responses bool) {
+	openAPILock.Lock()
+	defer openAPILock.Unlock()
+	validateRequests = requests
+	validateResponses = responses
+}
+
+// OpenAPIValidationMiddleware validates requests, and optionally responses, of matched routes
+// against the operation documented for the route's path template
+func OpenAPIValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openAPILock.RLock()
+		requests, responses := validateRequests, validateResponses
+		openAPILock.RUnlock()
+
+		route := mux.CurrentRoute(r)
+		if route == nil || (!requests && !responses) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		doc := openapi.Spec()
+		op := doc.Operation(template, r.Method)
+		if op == nil {
+			logging.FromContext(r.Context()).Warn("Route is not documented in the OpenAPI document", "method", r.Method, "route", template)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if requests {
+			if err := doc.ValidateRequest(op, r, mux.Vars(r)); err != nil {
+				var schemaErr *openapi.ValidationError
+				if errors.As(err, &schemaErr) {
+					problem.Write(w, r, validationProblem(schemaErr))
+					return
+				}
+				status := http.StatusBadRequest
+				var requestErr *openapi.RequestError
+				if errors.As(err, &requestErr) {
+					status = requestErr.Status
+				}
+				respondWithError(w, r, status, err.Error())
+				return
+			}
+		}
+
+		if !responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK, header: http.Header{}}
+		next.ServeHTTP(recorder, r)
+		if recorder.streaming {
+			return
+		}
+		if err := doc.ValidateResponse(op, recorder.status, recorder.header, recorder.body.Bytes()); err != nil {
+			logging.FromContext(r.Context()).Warn("Response does not match the OpenAPI document", "method", r.Method, "route", template, "operation_id", op.OperationID, "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "response does not match the OpenAPI document: "+err.Error())
+			return
+		}
+		recorder.flushTo(w)
+	})
+}
+
+// validationProblem lists every schema violation of a request body as a field error
+func validationProblem(err *openapi.ValidationError) *problem.Problem {
+	fieldErrors := make([]problem.FieldError, len(err.Errors))
+	messages := make([]string, len(err.Errors))
+	for i, schemaErr := range err.Errors {
+		field := pointerField(schemaErr.Pointer)
+		message := schemaErr.Message
+		if field != "" {
+			message = field + " " + message
+		}
+		fieldErrors[i] = problem.FieldError{Field: field, Pointer: schemaErr.Pointer, Code: schemaErr.Code, Message: message}
+		messages[i] = message
+	}
+	return problem.Validation(strings.Join(messages, "; "), fieldErrors)
+}
+
+// pointerField converts a JSON pointer such as /questions/0/text to the field path questions[0].text
+func pointerField(pointer string) string {
+	if pointer == "" {
+		return ""
+	}
+	var field strings.Builder
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		if _, err := strconv.Atoi(part); err == nil && field.Len() > 0 {
+			field.WriteString("[" + part + "]")
+			continue
+		}
+		if field.Len() > 0 {
+			field.WriteString(".")
+		}
+		field.WriteString(part)
+	}
+	return field.String()
+}
+
+// responseRecorder buffers a response so it can be validated before it is sent.
+// Handlers that flush, such as event streams, are passed through unvalidated.
+type responseRecorder struct {
+	http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	streaming   bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	if rec.streaming {
+		return rec.ResponseWriter.Header()
+	}
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.streaming {
+		rec.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if rec.streaming {
+		return rec.ResponseWriter.Write(data)
+	}
+	rec.wroteHeader = true
+	return rec.body.Write(data)
+}
+
+func (rec *responseRecorder) Flush() {
+	if !rec.streaming {
+		rec.streaming = true
+		rec.flushTo(rec.ResponseWriter)
+	}
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection's writer
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *responseRecorder) flushTo(w http.ResponseWriter) {
+	for key, values := range rec.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
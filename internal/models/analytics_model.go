@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// QuestionTermStats is one row of the api.survey_question_stats materialized view:
+// the combined rating distribution of a question for a course, instructor and term
+type QuestionTermStats struct {
+	CourseID      string    `json:"course_id"`
+	DepartmentID  int       `json:"department_id"`
+	InstructorID  string    `json:"instructor_id"`
+	SemesterTerm  string    `json:"semester_term"`
+	TermStarted   time.Time `json:"term_started"`
+	Position      int       `json:"position"`
+	Category      string    `json:"category"`
+	Text          string    `json:"text"`
+	SectionCount  int       `json:"section_count"`
+	ResponseCount int       `json:"response_count"`
+	EnrolledCount int       `json:"enrolled_count"`
+	Ratings       [5]int    `json:"ratings"`
+}
+
+// AnalyticsReport summarises survey results for an instructor or a course across terms
+type AnalyticsReport struct {
+	SubjectType      string          `json:"subject_type"`
+	SubjectID        string          `json:"subject_id"`
+	MinResponseCount int             `json:"min_response_count"`
+	Terms            []TermAnalytics `json:"terms"`
+}
+
+// TermAnalytics holds the per-question analytics of one semester term
+type TermAnalytics struct {
+	SemesterTerm string              `json:"semester_term"`
+	Questions    []QuestionAnalytics `json:"questions"`
+}
+
+// QuestionAnalytics holds the statistics of one question in one term. Statistics are
+// omitted when the question has fewer responses than the suppression threshold.
+type QuestionAnalytics struct {
+	Position             int           `json:"position"`
+	Category             string        `json:"category"`
+	Text                 string        `json:"text"`
+	SectionCount         int           `json:"section_count"`
+	ResponseCount        int           `json:"response_count"`
+	Suppressed           bool          `json:"suppressed"`
+	ResponseRate         *float64      `json:"response_rate,omitempty"`
+	Mean                 *float64      `json:"mean,omitempty"`
+	Median               *float64      `json:"median,omitempty"`
+	Distribution         []RatingCount `json:"distribution,omitempty"`
+	DeltaFromPrevious    *float64      `json:"delta_from_previous_term,omitempty"`
+	DepartmentPercentile *float64      `json:"department_percentile,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// CommentSummary is the text analysis of the free-text comments of a trace
+type CommentSummary struct {
+	TraceID      string           `json:"trace_id"`
+	CommentCount int              `json:"comment_count"`
+	Sentiment    SentimentSummary `json:"sentiment"`
+	Keywords     []TermCount      `json:"keywords"`
+	Phrases      []TermCount      `json:"phrases"`
+	Themes       []CommentTheme   `json:"themes"`
+}
+
+// SentimentSummary counts comments per sentiment label. Scores range from -1 to 1.
+type SentimentSummary struct {
+	AverageScore float64 `json:"average_score"`
+	Positive     int     `json:"positive"`
+	Neutral      int     `json:"neutral"`
+	Negative     int     `json:"negative"`
+}
+
+// TermCount is a keyword or phrase and the number of comments it appears in
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// CommentTheme is a group of comments sharing keywords. The comments themselves are not
+// returned since redaction cannot catch every student name.
+type CommentTheme struct {
+	Label            string   `json:"label"`
+	Keywords         []string `json:"keywords"`
+	CommentCount     int      `json:"comment_count"`
+	AverageSentiment float64  `json:"average_sentiment"`
+}
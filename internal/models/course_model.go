@@ -4,12 +4,12 @@ import "time"
 
 // CourseRequest is the model for creating a new course
 type CourseRequest struct {
-	Code         string `json:"code"`
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-	InstructorID string `json:"instructor_id"`
-	DepartmentID int    `json:"department_id"`
-	CreditHours  int    `json:"credit_hours"`
+	Code         string `json:"code" validate:"required,max=15"`
+	Name         string `json:"name" validate:"required,max=100"`
+	Description  string `json:"description" validate:"max=500"`
+	InstructorID string `json:"instructor_id" validate:"required,uuid"`
+	DepartmentID int    `json:"department_id" validate:"required,min=1"`
+	CreditHours  int    `json:"credit_hours" validate:"min=0,max=4"`
 }
 type Course struct {
 	CourseID        string    `json:"course_id"`
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Health states of a readiness check and of the report as a whole
+const (
+	HealthOK = "ok"
+	// HealthDegraded means only non-critical checks failed, the server keeps receiving traffic
+	HealthDegraded = "degraded"
+	// HealthUnavailable means a critical check failed or the server is shutting down
+	HealthUnavailable = "unavailable"
+	// HealthSkipped means the dependency is not configured
+	HealthSkipped = "skipped"
+)
+
+// HealthCheck is the outcome of checking one dependency
+type HealthCheck struct {
+	Status     string  `json:"status"`
+	Critical   bool    `json:"critical"`
+	DurationMs int64   `json:"duration_ms"`
+	Error      *string `json:"error,omitempty"`
+}
+
+// HealthReport is the response of GET /readyz
+type HealthReport struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Checks    map[string]HealthCheck `json:"checks"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Idempotency key states
+const (
+	IdempotencyStatusInProgress = "in_progress"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+// IdempotencyKey records the first response to a POST sent with an Idempotency-Key header,
+// so retries of the same request get the same response instead of repeating its effects
+type IdempotencyKey struct {
+	UserID          string              `json:"user_id"`
+	Key             string              `json:"key"`
+	Fingerprint     string              `json:"fingerprint"`
+	Method          string              `json:"method"`
+	Path            string              `json:"path"`
+	Status          string              `json:"status"`
+	ResponseStatus  int                 `json:"response_status"`
+	ResponseHeaders map[string][]string `json:"response_headers"`
+	ResponseBody    []byte              `json:"-"`
+	DateCreated     time.Time           `json:"date_created"`
+	ExpiresAt       time.Time           `json:"expires_at"`
+}
@@ -4,7 +4,7 @@ import "time"
 
 // InstructorRequest is used for creating/updating an instructor.
 type InstructorRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,nodigits"`
 }
 
 // Instructor represents the instructor model.
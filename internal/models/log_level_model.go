@@ -0,0 +1,6 @@
+package models
+
+// LogLevel is the minimum level of the lines the server logs
+type LogLevel struct {
+	Level string `json:"level"`
+}
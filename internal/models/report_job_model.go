@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Report job states
+const (
+	ReportJobPending   = "pending"
+	ReportJobRunning   = "running"
+	ReportJobCompleted = "completed"
+	ReportJobFailed    = "failed"
+)
+
+// ReportJob tracks the generation of the PDF reports of every instructor in a department
+type ReportJob struct {
+	JobID        string       `json:"job_id"`
+	DepartmentID int          `json:"department_id"`
+	SemesterTerm string       `json:"semester_term"`
+	UserID       string       `json:"user_id"`
+	Status       string       `json:"status"`
+	Total        int          `json:"total"`
+	Completed    int          `json:"completed"`
+	Failed       int          `json:"failed"`
+	Error        *string      `json:"error,omitempty"`
+	DateCreated  time.Time    `json:"date_created"`
+	DateFinished *time.Time   `json:"date_finished,omitempty"`
+	Files        []ReportFile `json:"files"`
+}
+
+// ReportFile is the generated report of one instructor in a report job
+type ReportFile struct {
+	JobID        string    `json:"-"`
+	InstructorID string    `json:"instructor_id"`
+	BucketPath   string    `json:"-"`
+	Error        *string   `json:"error,omitempty"`
+	DateCreated  time.Time `json:"date_created"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// SurveyResultRequest is delivered by the processing service once a trace PDF has been parsed
+type SurveyResultRequest struct {
+	ResponseCount    int                     `json:"response_count" validate:"min=0"`
+	EnrolledCount    int                     `json:"enrolled_count" validate:"min=0"`
+	ProcessorVersion string                  `json:"processor_version"`
+	Questions        []SurveyQuestionRequest `json:"questions"`
+	Comments         []SurveyCommentRequest  `json:"comments"`
+}
+
+// SurveyQuestionRequest is a rated question with the number of responses per rating
+type SurveyQuestionRequest struct {
+	Position      int           `json:"position"`
+	Category      string        `json:"category"`
+	Text          string        `json:"text" validate:"required"`
+	ResponseCount int           `json:"response_count" validate:"min=0"`
+	Ratings       []RatingCount `json:"ratings"`
+}
+
+// SurveyCommentRequest is a single free-text answer
+type SurveyCommentRequest struct {
+	Question string `json:"question"`
+	Text     string `json:"text" validate:"required"`
+}
+
+// RatingCount is the number of responses that gave a rating
+type RatingCount struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count" validate:"min=0"`
+}
+
+// SurveyResult holds the parsed results of a trace survey
+type SurveyResult struct {
+	TraceID          string           `json:"trace_id"`
+	ResponseCount    int              `json:"response_count"`
+	EnrolledCount    int              `json:"enrolled_count"`
+	ProcessorVersion string           `json:"processor_version"`
+	DateProcessed    time.Time        `json:"date_processed"`
+	Questions        []SurveyQuestion `json:"questions"`
+	Comments         []SurveyComment  `json:"comments"`
+}
+
+// SurveyQuestion is a rated question of a survey result
+type SurveyQuestion struct {
+	QuestionID    string        `json:"question_id"`
+	Position      int           `json:"position"`
+	Category      string        `json:"category"`
+	Text          string        `json:"text"`
+	ResponseCount int           `json:"response_count"`
+	Mean          float64       `json:"mean"`
+	Ratings       []RatingCount `json:"ratings"`
+}
+
+// SurveyComment is a free-text answer of a survey result
+type SurveyComment struct {
+	CommentID string `json:"comment_id"`
+	Question  string `json:"question"`
+	Text      string `json:"text"`
+}
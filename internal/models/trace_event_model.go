@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Trace stream event types sent by GET /v1/events
+const (
+	TraceStreamCreated       = "trace.created"
+	TraceStreamDeleted       = "trace.deleted"
+	TraceStreamStatusChanged = "trace.status_changed"
+	// TraceStreamReset tells a client that events may have been missed, so it should
+	// fetch the traces again instead of relying on the stream
+	TraceStreamReset = "stream.reset"
+)
+
+// Trace processing states carried by trace stream events
+const (
+	// TraceStatusUploaded means the current file is waiting to be processed
+	TraceStatusUploaded = "uploaded"
+	// TraceStatusProcessed means the survey results of the current file are stored
+	TraceStatusProcessed = "processed"
+)
+
+// TraceStreamEvent is a change to a trace pushed to the clients of GET /v1/events.
+// IDs come from a database sequence so they are shared by every api-server replica.
+type TraceStreamEvent struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	TraceID    string    `json:"trace_id"`
+	CourseID   string    `json:"course_id"`
+	Status     string    `json:"status,omitempty"`
+	Version    int       `json:"version,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
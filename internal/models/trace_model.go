@@ -3,9 +3,17 @@ package models
 import "time"
 
 type TraceRequest struct {
-	InstructorID string `json:"instructor_id"`
-	SemesterTerm string `json:"semester_term"`
-	Section      string `json:"section"`
+	InstructorID string `json:"instructor_id" validate:"required,uuid"`
+	SemesterTerm string `json:"semester_term" validate:"required"`
+	Section      string `json:"section" validate:"required"`
+}
+
+// TracePatchRequest lists the trace fields that can be changed with a merge patch
+type TracePatchRequest struct {
+	InstructorID string `json:"instructor_id" validate:"required,uuid"`
+	SemesterTerm string `json:"semester_term" validate:"required"`
+	Section      string `json:"section" validate:"required"`
+	CourseID     string `json:"course_id" validate:"required,uuid"`
 }
 
 type Trace struct {
@@ -19,3 +27,27 @@ type Trace struct {
 	SemesterTerm string    `json:"semester_term"`
 	Section      string    `json:"section"`
 }
+
+// BatchTraceManifestEntry describes the metadata for one file of a batch upload
+type BatchTraceManifestEntry struct {
+	FileName     string `json:"file_name"`
+	InstructorID string `json:"instructor_id"`
+	SemesterTerm string `json:"semester_term"`
+	Section      string `json:"section"`
+}
+
+// BatchTraceResult is the outcome of a single file in a batch upload
+type BatchTraceResult struct {
+	FileName string `json:"file_name"`
+	Status   int    `json:"status"`
+	Trace    *Trace `json:"trace,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchTraceReport is returned by the batch upload endpoint
+type BatchTraceReport struct {
+	Total     int                `json:"total"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Results   []BatchTraceResult `json:"results"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TraceVersion is one uploaded file of a trace; the highest version is the current file
+type TraceVersion struct {
+	TraceID       string    `json:"trace_id"`
+	VersionNumber int       `json:"version"`
+	FileName      string    `json:"file_name"`
+	BucketPath    string    `json:"bucket_path"`
+	UserID        string    `json:"user_id"`
+	DateCreated   time.Time `json:"date_created"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Upload session states
+const (
+	UploadStatusPending    = "pending"
+	UploadStatusAssembling = "assembling"
+	UploadStatusCompleted  = "completed"
+	UploadStatusFailed     = "failed"
+)
+
+// UploadSession tracks a resumable upload until it is assembled into a trace
+type UploadSession struct {
+	UploadID     string     `json:"upload_id"`
+	UserID       string     `json:"user_id"`
+	CourseID     string     `json:"course_id"`
+	InstructorID string     `json:"instructor_id"`
+	SemesterTerm string     `json:"semester_term"`
+	Section      string     `json:"section"`
+	FileName     string     `json:"file_name"`
+	UploadLength int64      `json:"upload_length"`
+	UploadOffset int64      `json:"upload_offset"`
+	Status       string     `json:"status"`
+	TraceID      *string    `json:"trace_id,omitempty"`
+	Error        *string    `json:"error,omitempty"`
+	DateCreated  time.Time  `json:"date_created"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	DateFinished *time.Time `json:"date_finished,omitempty"`
+}
+
+// UploadChunk is one stored chunk of a resumable upload
+type UploadChunk struct {
+	UploadID    string `json:"upload_id"`
+	ChunkOffset int64  `json:"chunk_offset"`
+	ChunkSize   int64  `json:"chunk_size"`
+	BucketPath  string `json:"bucket_path"`
+	Checksum    string `json:"checksum"`
+}
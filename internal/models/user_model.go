@@ -3,10 +3,10 @@ package models
 import "time"
 
 type UserRequest struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Username  string `json:"username"`
-	Password  string `json:"password"`
+	FirstName string `json:"first_name" validate:"required,nodigits"`
+	LastName  string `json:"last_name" validate:"required,nodigits"`
+	Username  string `json:"username" validate:"required,email"`
+	Password  string `json:"password" validate:"required,min=8"`
 }
 
 type User struct {
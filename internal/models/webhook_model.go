@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookRequest is used for creating a webhook subscription. An empty department
+// receives the events of every department.
+type WebhookRequest struct {
+	URL          string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes   []string `json:"event_types" validate:"required"`
+	DepartmentID *int     `json:"department_id" validate:"min=1"`
+	Description  string   `json:"description" validate:"max=200"`
+}
+
+// WebhookPatchRequest lists the subscription fields that can be changed with PATCH
+type WebhookPatchRequest struct {
+	URL          string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes   []string `json:"event_types" validate:"required"`
+	DepartmentID *int     `json:"department_id" validate:"min=1"`
+	Description  string   `json:"description" validate:"max=200"`
+	Active       *bool    `json:"active"`
+}
+
+// WebhookSubscription is an endpoint notified of trace and course events
+type WebhookSubscription struct {
+	WebhookID      string    `json:"webhook_id"`
+	UserID         string    `json:"user_id"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"secret,omitempty"`
+	EventTypes     []string  `json:"event_types"`
+	DepartmentID   *int      `json:"department_id"`
+	Description    string    `json:"description"`
+	Active         bool      `json:"active"`
+	FailureCount   int       `json:"failure_count"`
+	DisabledReason *string   `json:"disabled_reason,omitempty"`
+	DateCreated    time.Time `json:"date_created"`
+	DateUpdated    time.Time `json:"date_updated"`
+}
+
+// WebhookEvent is the body posted to webhook endpoints
+type WebhookEvent struct {
+	EventID      string          `json:"event_id"`
+	EventType    string          `json:"event_type"`
+	DepartmentID int             `json:"department_id"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a subscription
+type WebhookDelivery struct {
+	DeliveryID     string          `json:"delivery_id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DateCreated    time.Time       `json:"date_created"`
+	DateDelivered  *time.Time      `json:"date_delivered,omitempty"`
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/credentials"
+)
+
+// MetricsPolicy holds where metrics are exported to
+type MetricsPolicy struct {
+	// Prometheus serves metrics on GET /metrics
+	Prometheus bool
+	// OTLP pushes metrics to the collector every ExportInterval
+	OTLP           bool
+	Collector      OTLPPolicy
+	ExportInterval time.Duration
+}
+
+var (
+	metricsHandler     http.Handler
+	metricsHandlerLock sync.RWMutex
+)
+
+// InitMeter installs the global meter provider that every instrument of the server
+// records to. Instruments created before are connected to it as well. When the OTLP
+// exporter cannot be created the error is returned with a provider that still serves
+// Prometheus scrapes.
+func InitMeter(res *resource.Resource, policy MetricsPolicy) (func(context.Context) error, error) {
+	ctx := context.Background()
+
+	options := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	if policy.Prometheus {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdkmetric.WithReader(exporter))
+
+		metricsHandlerLock.Lock()
+		metricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+		metricsHandlerLock.Unlock()
+	}
+	var otlpErr error
+	if policy.OTLP {
+		var exporter sdkmetric.Exporter
+		exporter, otlpErr = newOTLPMetricExporter(ctx, policy.Collector)
+		if otlpErr == nil {
+			options = append(options, sdkmetric.WithReader(
+				sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(policy.ExportInterval)),
+			))
+		}
+	}
+
+	mp := sdkmetric.NewMeterProvider(options...)
+	otel.SetMeterProvider(mp)
+
+	// Return shutdown func, which pushes the last OTLP export
+	return mp.Shutdown, otlpErr
+}
+
+func newOTLPMetricExporter(ctx context.Context, policy OTLPPolicy) (sdkmetric.Exporter, error) {
+	insecure, tlsConfig, err := policy.transport()
+	if err != nil {
+		return nil, err
+	}
+	if policy.Protocol == "http/protobuf" {
+		var options []otlpmetrichttp.Option
+		if policy.Endpoint != "" {
+			options = append(options, otlpmetrichttp.WithEndpoint(policy.Endpoint))
+		}
+		if len(policy.Headers) > 0 {
+			options = append(options, otlpmetrichttp.WithHeaders(policy.Headers))
+		}
+		if insecure {
+			options = append(options, otlpmetrichttp.WithInsecure())
+		} else if tlsConfig != nil {
+			options = append(options, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+		}
+		return otlpmetrichttp.New(ctx, options...)
+	}
+
+	// the connection is made in the background, so a missing collector does not block startup
+	var options []otlpmetricgrpc.Option
+	if policy.Endpoint != "" {
+		options = append(options, otlpmetricgrpc.WithEndpoint(policy.Endpoint))
+	}
+	if len(policy.Headers) > 0 {
+		options = append(options, otlpmetricgrpc.WithHeaders(policy.Headers))
+	}
+	if insecure {
+		options = append(options, otlpmetricgrpc.WithInsecure())
+	} else if tlsConfig != nil {
+		options = append(options, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	return otlpmetricgrpc.New(ctx, options...)
+}
+
+// MetricsHandler returns the handler serving metrics in the Prometheus text format,
+// or nil when the Prometheus exporter is disabled
+func MetricsHandler() http.Handler {
+	metricsHandlerLock.RLock()
+	defer metricsHandlerLock.RUnlock()
+	return metricsHandler
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// OTLPPolicy holds how traces and metrics reach the collector. An empty endpoint or
+// empty headers leave them to the OTEL_EXPORTER_OTLP_* variables read by the exporters.
+type OTLPPolicy struct {
+	// Protocol is grpc or http/protobuf
+	Protocol string
+	// Endpoint is the host:port of the collector
+	Endpoint string
+	Headers  map[string]string
+	// Insecure sends in plain text when true and requires TLS, verified with CACert or the
+	// system roots, when false. It is nil when OTLP_INSECURE is unset.
+	Insecure *bool
+	CACert   string
+	// ClientCert and ClientKey authenticate the server to the collector with mutual TLS
+	ClientCert string
+	ClientKey  string
+}
+
+// transport reports whether the server's own settings ask for plain text, and otherwise the
+// TLS configuration they ask for. Both are zero when none of them is set, so the exporters
+// follow OTEL_EXPORTER_OTLP_INSECURE, OTEL_EXPORTER_OTLP_CERTIFICATE and the scheme of
+// OTEL_EXPORTER_OTLP_ENDPOINT.
+func (p OTLPPolicy) transport() (bool, *tls.Config, error) {
+	if p.Insecure != nil && *p.Insecure {
+		return true, nil, nil
+	}
+	if p.Insecure == nil && p.CACert == "" && p.ClientCert == "" {
+		return false, nil, nil
+	}
+	config, err := p.tlsConfig()
+	return false, config, err
+}
+
+func (p OTLPPolicy) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if p.CACert != "" {
+		pem, err := os.ReadFile(p.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading OTLP CA certificate: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", p.CACert)
+		}
+		config.RootCAs = roots
+	}
+	if p.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(p.ClientCert, p.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading OTLP client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// ServiceInfo describes the server on every span and metric it exports
+type ServiceInfo struct {
+	Name string
+	// Version defaults to the VCS revision the binary was built from
+	Version     string
+	Environment string
+}
+
+// NewResource describes the server, its host, process and Kubernetes pod. The standard
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES variables take precedence. A resource
+// is returned even when some detectors fail, with the error.
+func NewResource(ctx context.Context, service ServiceInfo) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String(service.Name)}
+	version := service.Version
+	if version == "" {
+		version = buildVersion()
+	}
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(version))
+	}
+	if service.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(service.Environment))
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attrs...),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithProcessPID(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithDetectors(kubernetesDetector{}),
+		resource.WithFromEnv(),
+	)
+	if errors.Is(err, resource.ErrPartialResource) {
+		return res, err
+	}
+	if err != nil {
+		return resource.NewSchemaless(attrs...), err
+	}
+	return res, nil
+}
+
+// buildVersion returns the VCS revision the binary was built from, if recorded
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return setting.Value[:12]
+		}
+	}
+	return ""
+}
+
+// serviceAccountNamespace holds the namespace of the pod in every Kubernetes container
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// kubernetesDetector describes the pod the server runs in. Deployments pass the pod,
+// namespace and node names through the downward API as K8S_POD_NAME, K8S_NAMESPACE and
+// K8S_NODE_NAME; without them the pod name is the hostname and the namespace is read
+// from the service account.
+type kubernetesDetector struct{}
+
+func (kubernetesDetector) Detect(context.Context) (*resource.Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return resource.Empty(), nil
+	}
+
+	var attrs []attribute.KeyValue
+	pod := os.Getenv("K8S_POD_NAME")
+	if pod == "" {
+		pod, _ = os.Hostname()
+	}
+	if pod != "" {
+		attrs = append(attrs, semconv.K8SPodNameKey.String(pod))
+	}
+	namespace := os.Getenv("K8S_NAMESPACE")
+	if namespace == "" {
+		if data, err := os.ReadFile(serviceAccountNamespace); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+	if namespace != "" {
+		attrs = append(attrs, semconv.K8SNamespaceNameKey.String(namespace))
+	}
+	if node := os.Getenv("K8S_NODE_NAME"); node != "" {
+		attrs = append(attrs, semconv.K8SNodeNameKey.String(node))
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
@@ -2,51 +2,143 @@ package tracing
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-func InitTracer(serviceName, otlpEndpoint string) func() {
-	ctx := context.Background()
+// TracingPolicy holds how spans are sampled and where they are exported to
+type TracingPolicy struct {
+	// Exporter is otlp, stdout, file or none
+	Exporter  string
+	Collector OTLPPolicy
+	// FilePath receives one JSON span per line with the file exporter
+	FilePath string
+	// SampleRatio of the traces started by the server that are recorded. Traces
+	// continued from a caller follow the caller's decision.
+	SampleRatio float64
+	// SamplerFromEnv leaves sampling to OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG
+	SamplerFromEnv bool
+	// Propagators read and write the trace context of requests: tracecontext, baggage or none
+	Propagators []string
+}
 
-	// Set up OTLP exporter
-	exp, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(otlpEndpoint),
-		otlptracegrpc.WithDialOption(grpc.WithBlock()),
-	)
-	if err != nil {
-		log.Fatalf("failed to create OTLP exporter: %v", err)
-	}
+// InitTracer installs the global tracer provider. It never blocks on the collector:
+// spans are exported in the background and failed exports are logged. When the
+// exporter cannot be created the error is returned with a provider that still gives
+// spans IDs for log correlation but exports nothing, so the server can run degraded.
+func InitTracer(res *resource.Resource, policy TracingPolicy) (func(context.Context) error, error) {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("OpenTelemetry export failed", "error", err)
+	}))
+	otel.SetTextMapPropagator(newPropagator(policy.Propagators))
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(serviceName),
-		),
-	)
-	if err != nil {
-		log.Fatalf("failed to create resource: %v", err)
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if !policy.SamplerFromEnv {
+		options = append(options, sdktrace.WithSampler(
+			sdktrace.ParentBased(sdktrace.TraceIDRatioBased(policy.SampleRatio)),
+		))
+	}
+	exporter, closer, err := newSpanExporter(context.Background(), policy)
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(res),
-	)
-
+	tp := sdktrace.NewTracerProvider(options...)
 	otel.SetTracerProvider(tp)
 
-	log.Printf("From tracing.go: Initializing OpenTelemetry with endpoint: '%s' and service '%s'", otlpEndpoint, serviceName)
+	// Return shutdown func, which flushes the spans still queued
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, err
+}
+
+// newSpanExporter returns the configured exporter, and the file it writes to if any
+func newSpanExporter(ctx context.Context, policy TracingPolicy) (sdktrace.SpanExporter, io.Closer, error) {
+	switch policy.Exporter {
+	case "none":
+		return nil, nil, nil
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case "file":
+		file, err := os.OpenFile(policy.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	default:
+		exporter, err := newOTLPSpanExporter(ctx, policy.Collector)
+		return exporter, nil, err
+	}
+}
+
+func newOTLPSpanExporter(ctx context.Context, policy OTLPPolicy) (sdktrace.SpanExporter, error) {
+	insecure, tlsConfig, err := policy.transport()
+	if err != nil {
+		return nil, err
+	}
+	if policy.Protocol == "http/protobuf" {
+		var options []otlptracehttp.Option
+		if policy.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(policy.Endpoint))
+		}
+		if len(policy.Headers) > 0 {
+			options = append(options, otlptracehttp.WithHeaders(policy.Headers))
+		}
+		if insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		} else if tlsConfig != nil {
+			options = append(options, otlptracehttp.WithTLSClientConfig(tlsConfig))
+		}
+		return otlptracehttp.New(ctx, options...)
+	}
+
+	// the connection is made in the background, so a missing collector does not block startup
+	var options []otlptracegrpc.Option
+	if policy.Endpoint != "" {
+		options = append(options, otlptracegrpc.WithEndpoint(policy.Endpoint))
+	}
+	if len(policy.Headers) > 0 {
+		options = append(options, otlptracegrpc.WithHeaders(policy.Headers))
+	}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	} else if tlsConfig != nil {
+		options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	return otlptracegrpc.New(ctx, options...)
+}
 
-	// Return shutdown func
-	return func() {
-		if err := tp.Shutdown(ctx); err != nil {
-			log.Fatalf("error shutting down tracer provider: %v", err)
+func newPropagator(names []string) propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
 		}
 	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
 }
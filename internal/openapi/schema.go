@@ -0,0 +1,230 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of JSON Schema used by the document
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 json.RawMessage    `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+}
+
+// FieldError is a single schema violation, located by a JSON pointer
+type FieldError struct {
+	Pointer string
+	Code    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
+
+// ValidationError collects every schema violation of a value
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// types returns the allowed types of a schema, which may be a single name or a list in 3.1
+func (s *Schema) types() []string {
+	if len(s.Type) == 0 {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(s.Type, &single); err == nil {
+		return []string{single}
+	}
+	var list []string
+	json.Unmarshal(s.Type, &list)
+	return list
+}
+
+// ValidateValue validates a value decoded with json.Decoder.UseNumber against a schema
+func (d *Document) ValidateValue(schema *Schema, value any) error {
+	errs := d.validate(schema, value, "", nil)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+func (d *Document) validate(schema *Schema, value any, pointer string, errs []FieldError) []FieldError {
+	if schema == nil {
+		return errs
+	}
+	if schema.Ref != "" {
+		resolved, ok := d.components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return append(errs, FieldError{pointer, "unknown_schema", "unknown schema " + schema.Ref})
+		}
+		return d.validate(resolved, value, pointer, errs)
+	}
+
+	if types := schema.types(); len(types) > 0 {
+		matched := ""
+		for _, t := range types {
+			if hasType(value, t) {
+				matched = t
+				break
+			}
+		}
+		if matched == "" {
+			return append(errs, FieldError{pointer, "invalid_type", "must be of type " + strings.Join(types, " or ")})
+		}
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		allowed := make([]string, len(schema.Enum))
+		for i, v := range schema.Enum {
+			allowed[i] = fmt.Sprint(v)
+		}
+		errs = append(errs, FieldError{pointer, "enum", "must be one of " + strings.Join(allowed, ", ")})
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			errs = append(errs, FieldError{pointer, "min_length", fmt.Sprintf("must be at least %d characters", *schema.MinLength)})
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			errs = append(errs, FieldError{pointer, "max_length", fmt.Sprintf("must be at most %d characters", *schema.MaxLength)})
+		}
+		if code, message := checkFormat(schema.Format, v); code != "" {
+			errs = append(errs, FieldError{pointer, code, message})
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if schema.Minimum != nil && n < *schema.Minimum {
+			errs = append(errs, FieldError{pointer, "min", fmt.Sprintf("must be at least %v", *schema.Minimum)})
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			errs = append(errs, FieldError{pointer, "max", fmt.Sprintf("must be at most %v", *schema.Maximum)})
+		}
+	case []any:
+		for i, item := range v {
+			errs = d.validate(schema.Items, item, fmt.Sprintf("%s/%d", pointer, i), errs)
+		}
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, FieldError{pointer + "/" + escapePointer(name), "required", "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property := v[name]
+			child := pointer + "/" + escapePointer(name)
+			if propertySchema, ok := schema.Properties[name]; ok {
+				errs = d.validate(propertySchema, property, child, errs)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				errs = append(errs, FieldError{child, "unknown_field", "is not allowed"})
+			}
+		}
+	}
+	return errs
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if n, ok := value.(json.Number); ok {
+			if f, err := n.Float64(); err == nil && allowed == f {
+				return true
+			}
+			continue
+		}
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFormat returns the code and message of a format violation, or empty strings
+func checkFormat(format, value string) (string, string) {
+	switch format {
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return "uuid", "must be a UUID"
+		}
+	case "email":
+		if !emailPattern.MatchString(value) {
+			return "email", "must be an email address"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return "date_time", "must be an RFC 3339 date-time"
+		}
+	}
+	return "", ""
+}
+
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
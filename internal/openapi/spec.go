@@ -0,0 +1,199 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+// Document is the subset of an OpenAPI 3.1 document used for request and response validation
+type Document struct {
+	Paths      map[string]map[string]*Operation
+	components components
+}
+
+// Operation is a single method of a path, with the path-level parameters merged in
+type Operation struct {
+	OperationID string
+	Parameters  []Parameter
+	RequestBody *RequestBody
+	Responses   map[string]*Response
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody lists the accepted media types of a request
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response lists the media types of a documented status code
+type Response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*MediaType `json:"content"`
+}
+
+// MediaType holds the schema of one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+	Responses  map[string]*Response  `json:"responses"`
+}
+
+type rawOperation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var (
+	spec     *Document
+	specOnce sync.Once
+)
+
+// JSON returns the embedded OpenAPI document
+func JSON() []byte {
+	return specJSON
+}
+
+// Spec returns the parsed embedded OpenAPI document
+func Spec() *Document {
+	specOnce.Do(func() {
+		doc, err := Parse(specJSON)
+		if err != nil {
+			panic(fmt.Sprintf("invalid embedded OpenAPI document: %v", err))
+		}
+		spec = doc
+	})
+	return spec
+}
+
+// Parse parses an OpenAPI document and resolves its parameter and response references
+func Parse(data []byte) (*Document, error) {
+	var raw struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components components                            `json:"components"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Paths: map[string]map[string]*Operation{}, components: raw.Components}
+	for path, item := range raw.Paths {
+		var shared []Parameter
+		if params, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(params, &shared); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		doc.Paths[path] = map[string]*Operation{}
+		for _, method := range methods {
+			body, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op rawOperation
+			if err := json.Unmarshal(body, &op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+
+			params, err := doc.resolveParameters(append(append([]Parameter{}, shared...), op.Parameters...))
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			responses := map[string]*Response{}
+			for status, response := range op.Responses {
+				if response.Ref != "" {
+					resolved, ok := doc.components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: unknown response %s", method, path, response.Ref)
+					}
+					response = resolved
+				}
+				responses[status] = response
+			}
+
+			doc.Paths[path][strings.ToUpper(method)] = &Operation{
+				OperationID: op.OperationID,
+				Parameters:  params,
+				RequestBody: op.RequestBody,
+				Responses:   responses,
+			}
+		}
+	}
+	return doc, nil
+}
+
+func (d *Document) resolveParameters(params []Parameter) ([]Parameter, error) {
+	resolved := make([]Parameter, 0, len(params))
+	for _, param := range params {
+		if param.Ref != "" {
+			target, ok := d.components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+			if !ok {
+				return nil, fmt.Errorf("unknown parameter %s", param.Ref)
+			}
+			param = *target
+		}
+		resolved = append(resolved, param)
+	}
+	return resolved, nil
+}
+
+// Operation returns the operation documented for a path template and method
+func (d *Document) Operation(pathTemplate, method string) *Operation {
+	if method == http.MethodHead {
+		if op := d.Paths[pathTemplate][method]; op != nil {
+			return op
+		}
+		method = http.MethodGet
+	}
+	return d.Paths[pathTemplate][method]
+}
+
+// MissingRoutes returns the routes registered on the router that the document does not describe
+func (d *Document) MissingRoutes(router *mux.Router) []string {
+	missing := []string{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		routeMethods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range routeMethods {
+			if d.Operation(template, method) == nil {
+				missing = append(missing, method+" "+template)
+			}
+		}
+		return nil
+	})
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,186 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxValidatedBody is the largest JSON request body read for validation
+const maxValidatedBody = 1 << 20
+
+// RequestError is returned when a request does not match its operation
+type RequestError struct {
+	Status int
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func badRequest(format string, args ...any) *RequestError {
+	return &RequestError{Status: http.StatusBadRequest, Err: fmt.Errorf(format, args...)}
+}
+
+// ValidateRequest checks the parameters and JSON body of a request against an operation.
+// A validated JSON body is put back on the request so handlers can read it again.
+func (d *Document) ValidateRequest(op *Operation, r *http.Request, pathParams map[string]string) error {
+	query := r.URL.Query()
+	documented := map[string]bool{}
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			if err := d.validateParameter(param, pathParams[param.Name]); err != nil {
+				return err
+			}
+		case "query":
+			documented[param.Name] = true
+			values, present := query[param.Name]
+			if !present {
+				if param.Required {
+					return badRequest("query parameter %q is required", param.Name)
+				}
+				continue
+			}
+			if err := d.validateParameter(param, values[0]); err != nil {
+				return err
+			}
+		case "header":
+			// a missing header is left to the handler, which knows the protocol specific status
+			if value := r.Header.Get(param.Name); value != "" {
+				if err := d.validateParameter(param, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name := range query {
+		if !documented[name] {
+			return badRequest("query parameter %q is not allowed", name)
+		}
+	}
+
+	if op.RequestBody == nil || !hasBody(r) {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return &RequestError{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("unsupported content type %q", mediaType)}
+	}
+	if !isJSON(mediaType) || content.Schema == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	r.Body.Close()
+	if err != nil {
+		return badRequest("failed to read request body")
+	}
+	if len(data) > maxValidatedBody {
+		return &RequestError{Status: http.StatusRequestEntityTooLarge, Err: errors.New("request body is too large")}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.RequestBody.Required {
+			return badRequest("request body is required")
+		}
+		return nil
+	}
+
+	value, err := decodeJSON(data)
+	if err != nil {
+		return badRequest("invalid JSON body")
+	}
+	if err := d.ValidateValue(content.Schema, value); err != nil {
+		return &RequestError{Status: http.StatusBadRequest, Err: err}
+	}
+	return nil
+}
+
+// validateParameter converts a raw parameter value to its schema type and validates it
+func (d *Document) validateParameter(param Parameter, raw string) error {
+	var value any = raw
+	for _, t := range param.Schema.types() {
+		if t == "integer" || t == "number" {
+			if _, err := strconv.ParseFloat(raw, 64); err != nil {
+				return badRequest("%s parameter %q must be a number", param.In, param.Name)
+			}
+			value = json.Number(raw)
+		}
+	}
+	if err := d.ValidateValue(param.Schema, value); err != nil {
+		return badRequest("%s parameter %q %s", param.In, param.Name, err.Error())
+	}
+	return nil
+}
+
+// ValidateResponse checks that a response status and body are documented for an operation
+func (d *Document) ValidateResponse(op *Operation, status int, header http.Header, body []byte) error {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = op.Responses[fmt.Sprintf("%dXX", status/100)]
+	}
+	if !ok {
+		response, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = http.DetectContentType(body)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("content type %q is not documented for status %d", mediaType, status)
+	}
+	if !isJSON(mediaType) || content.Schema == nil {
+		return nil
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return d.ValidateValue(content.Schema, value)
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && (r.ContentLength > 0 || r.ContentLength == -1)
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
@@ -0,0 +1,113 @@
+// Package problem writes RFC 7807 application/problem+json error responses.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"api-server/internal/logging"
+	"api-server/internal/repositories"
+	"api-server/internal/utils"
+)
+
+// ContentType is the media type of every error response
+const ContentType = "application/problem+json"
+
+// Problem types. Statuses without a more specific type use about:blank,
+// in which case the title is the HTTP status text.
+const (
+	TypeDefault          = "about:blank"
+	TypeValidation       = "/problems/validation-error"
+	TypeNotFound         = "/problems/not-found"
+	TypeConflict         = "/problems/conflict"
+	TypeReferenceMissing = "/problems/reference-not-found"
+)
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single field of the request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Pointer string `json:"pointer,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// New returns a problem of the default type for a status
+func New(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	if title == "" {
+		// extension statuses such as tus' 460 have no standard text
+		title = "Error"
+	}
+	return &Problem{Type: TypeDefault, Title: title, Status: status, Detail: detail}
+}
+
+// Validation returns a 400 problem listing invalid fields
+func Validation(detail string, fieldErrors []FieldError) *Problem {
+	return &Problem{
+		Type:   TypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusBadRequest,
+		Detail: detail,
+		Errors: fieldErrors,
+	}
+}
+
+// Write sends a problem, filling in the instance and request ID from the request
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if r != nil {
+		if p.Instance == "" {
+			p.Instance = r.URL.Path
+		}
+		if p.RequestID == "" {
+			p.RequestID = utils.RequestIDFromContext(r.Context())
+		}
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error sends a problem of the default type for a status
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	Write(w, r, New(status, detail))
+}
+
+// FromError maps a repository error to a problem. resource names the entity in the
+// detail of not found errors, e.g. "course". Unclassified errors are logged and
+// reported as 503 since they come from the database or another backing service.
+func FromError(r *http.Request, err error, resource string) *Problem {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return &Problem{Type: TypeNotFound, Title: "Resource not found", Status: http.StatusNotFound, Detail: resource + " not found"}
+	case errors.Is(err, repositories.ErrConflict):
+		return &Problem{Type: TypeConflict, Title: "Resource already exists", Status: http.StatusConflict, Detail: err.Error()}
+	case errors.Is(err, repositories.ErrForeignKeyViolation):
+		return &Problem{Type: TypeReferenceMissing, Title: "Referenced resource not found", Status: http.StatusConflict, Detail: err.Error()}
+	}
+
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	logging.FromContext(ctx).Error("Error handling request", "resource", resource, "error", err)
+	return New(http.StatusServiceUnavailable, "")
+}
+
+// WriteError maps a repository error to a problem and sends it
+func WriteError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	Write(w, r, FromError(r, err, resource))
+}
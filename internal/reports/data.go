@@ -0,0 +1,125 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-server/internal/analytics"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+)
+
+// ErrNoTraces is returned when an instructor has no traces in the requested term
+var ErrNoTraces = errors.New("no traces found for instructor in semester term")
+
+// InstructorReport is everything rendered on an instructor's term summary
+type InstructorReport struct {
+	Instructor       models.Instructor
+	SemesterTerm     string
+	SemesterName     string
+	MinResponseCount int
+	Courses          []CourseReport
+	GeneratedAt      time.Time
+}
+
+// CourseReport is one course taught by the instructor in the term
+type CourseReport struct {
+	Course    models.Course
+	Traces    []models.Trace
+	Questions []models.QuestionAnalytics
+}
+
+// Source is the part of repositories.Store a report is read from
+type Source interface {
+	GetInstructorByID(ctx context.Context, instructorID string) (models.Instructor, error)
+	GetSemesterTerm(ctx context.Context, semesterTerm string) (*models.SemesterTermModel, error)
+	GetCourseByID(ctx context.Context, courseID string) (*models.Course, error)
+	GetTracesByInstructorAndTerm(ctx context.Context, instructorID, semesterTerm string) ([]models.Trace, error)
+	GetQuestionStatsByInstructor(ctx context.Context, instructorID string) ([]models.QuestionTermStats, error)
+	GetDepartmentQuestionStats(ctx context.Context, departmentIDs []int, semesterTerms []string) ([]models.QuestionTermStats, error)
+}
+
+var _ Source = (repositories.Store)(nil)
+
+// LoadInstructorReport gathers the course metadata, traces and aggregated survey results
+// of an instructor for one semester term from src
+func LoadInstructorReport(ctx context.Context, src Source, instructorID, semesterTerm string, minResponses int) (*InstructorReport, error) {
+	instructor, err := src.GetInstructorByID(ctx, instructorID)
+	if err != nil {
+		return nil, err
+	}
+	semester, err := src.GetSemesterTerm(ctx, semesterTerm)
+	if err != nil {
+		return nil, err
+	}
+
+	traces, err := src.GetTracesByInstructorAndTerm(ctx, instructorID, semesterTerm)
+	if err != nil {
+		return nil, err
+	}
+	if len(traces) == 0 {
+		return nil, ErrNoTraces
+	}
+
+	stats, err := src.GetQuestionStatsByInstructor(ctx, instructorID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &InstructorReport{
+		Instructor:       instructor,
+		SemesterTerm:     semester.SemesterTerm,
+		SemesterName:     semester.Name,
+		MinResponseCount: minResponses,
+		GeneratedAt:      time.Now().UTC(),
+	}
+
+	courseIndex := map[string]int{}
+	for _, trace := range traces {
+		i, ok := courseIndex[trace.CourseID]
+		if !ok {
+			course, err := src.GetCourseByID(ctx, trace.CourseID)
+			if err != nil {
+				return nil, err
+			}
+			i = len(report.Courses)
+			courseIndex[trace.CourseID] = i
+			report.Courses = append(report.Courses, CourseReport{Course: *course})
+		}
+		report.Courses[i].Traces = append(report.Courses[i].Traces, trace)
+	}
+
+	for i := range report.Courses {
+		courseID := report.Courses[i].Course.CourseID
+		// keep earlier terms of the course so the previous-term delta can be computed
+		rows := []models.QuestionTermStats{}
+		terms := []string{}
+		seenTerms := map[string]bool{}
+		for _, row := range stats {
+			if row.CourseID != courseID {
+				continue
+			}
+			rows = append(rows, row)
+			if !seenTerms[row.SemesterTerm] {
+				seenTerms[row.SemesterTerm] = true
+				terms = append(terms, row.SemesterTerm)
+			}
+		}
+		if !seenTerms[semesterTerm] {
+			continue
+		}
+		peers, err := src.GetDepartmentQuestionStats(ctx, []int{report.Courses[i].Course.DepartmentID}, terms)
+		if err != nil {
+			return nil, err
+		}
+		built := analytics.BuildReport(analytics.SubjectInstructor, instructorID, rows, peers, minResponses)
+		for _, term := range built.Terms {
+			if term.SemesterTerm == semesterTerm {
+				report.Courses[i].Questions = term.Questions
+			}
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,138 @@
+package reports
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templateFuncs = template.FuncMap{
+	"fmtFloat": formatFloat,
+	"fmtDelta": formatDelta,
+	"barWidth": func(v *float64) int {
+		if v == nil {
+			return 0
+		}
+		return int(*v * 16)
+	},
+}
+
+var instructorTemplate = template.Must(
+	template.New("instructor_report.html").Funcs(templateFuncs).ParseFS(templateFS, "templates/instructor_report.html"),
+)
+
+// RenderHTML renders an instructor report as a standalone HTML page
+func RenderHTML(report *InstructorReport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := instructorTemplate.Execute(&buf, report); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPDF renders an instructor report as a PDF document
+func RenderPDF(report *InstructorReport) []byte {
+	doc := newPDFDocument()
+	y := margin + 10
+	contentWidth := pageWidth - 2*margin
+
+	ensureSpace := func(height float64) {
+		if y+height > pageHeight-margin {
+			doc.addPage()
+			y = margin + 10
+		}
+	}
+
+	doc.text(margin, y, 20, fontBold, report.Instructor.Name)
+	y += 20
+	doc.text(margin, y, 11, fontRegular, fmt.Sprintf("TRACE survey summary - %s (%s)", report.SemesterName, report.SemesterTerm))
+	y += 24
+
+	// column layout for the question table
+	columns := []struct {
+		title string
+		x     float64
+	}{
+		{"Resp.", margin + 290},
+		{"Mean", margin + 330},
+		{"Median", margin + 400},
+		{"Delta", margin + 445},
+		{"Pctl.", margin + 480},
+	}
+	questionWidth := 280.0
+
+	for _, course := range report.Courses {
+		ensureSpace(60)
+		doc.text(margin, y, 13, fontBold, fmt.Sprintf("%s - %s", course.Course.Code, course.Course.Name))
+		y += 6
+		doc.line(margin, y, margin+contentWidth, y, 0.5)
+		y += 14
+
+		sections := []string{}
+		for _, trace := range course.Traces {
+			sections = append(sections, trace.Section)
+		}
+		doc.text(margin, y, 9, fontRegular, "Sections: "+strings.Join(sections, ", "))
+		y += 16
+
+		if len(course.Questions) == 0 {
+			doc.text(margin, y, 9, fontRegular, "Survey results have not been processed yet.")
+			y += 20
+			continue
+		}
+
+		doc.text(margin, y, 8, fontBold, "Question")
+		for _, column := range columns {
+			doc.text(column.x, y, 8, fontBold, column.title)
+		}
+		y += 12
+
+		for _, question := range course.Questions {
+			lines := wrapText(question.Text, 8, questionWidth)
+			ensureSpace(float64(len(lines))*10 + 4)
+			for i, line := range lines {
+				doc.text(margin, y+float64(i)*10, 8, fontRegular, line)
+			}
+			doc.text(columns[0].x, y, 8, fontRegular, fmt.Sprintf("%d", question.ResponseCount))
+			if question.Suppressed {
+				doc.text(columns[1].x, y, 8, fontRegular, "Too few responses to report")
+			} else {
+				doc.text(columns[1].x, y, 8, fontRegular, formatFloat(question.Mean))
+				if question.Mean != nil {
+					doc.rect(columns[1].x+22, y-6, *question.Mean*8, 6, 0.55)
+				}
+				doc.text(columns[2].x, y, 8, fontRegular, formatFloat(question.Median))
+				doc.text(columns[3].x, y, 8, fontRegular, formatDelta(question.DeltaFromPrevious))
+				doc.text(columns[4].x, y, 8, fontRegular, formatFloat(question.DepartmentPercentile))
+			}
+			y += float64(len(lines))*10 + 4
+		}
+		y += 12
+	}
+
+	ensureSpace(20)
+	footer := fmt.Sprintf("Statistics are suppressed for questions with fewer than %d responses. Generated %s.",
+		report.MinResponseCount, report.GeneratedAt.Format("2006-01-02 15:04 MST"))
+	doc.text(margin, y, 7, fontRegular, footer)
+
+	return doc.bytes()
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+func formatDelta(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f", *v)
+}
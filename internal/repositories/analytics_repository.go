@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"api-server/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const questionStatsColumns = "course_id, department_id, instructor_id, semester_term, term_started, position, category, text, section_count, response_count, enrolled_count, rating_1, rating_2, rating_3, rating_4, rating_5"
+
+func queryQuestionStats(ctx context.Context, db DBTX, query string, args ...any) ([]models.QuestionTermStats, error) {
+	rows, err := queryContext(ctx, db, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	stats := []models.QuestionTermStats{}
+	for rows.Next() {
+		var s models.QuestionTermStats
+		if err := rows.Scan(&s.CourseID, &s.DepartmentID, &s.InstructorID, &s.SemesterTerm, &s.TermStarted, &s.Position, &s.Category, &s.Text, &s.SectionCount, &s.ResponseCount, &s.EnrolledCount, &s.Ratings[0], &s.Ratings[1], &s.Ratings[2], &s.Ratings[3], &s.Ratings[4]); err != nil {
+			return nil, translateError(err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, translateError(rows.Err())
+}
+
+// GetQuestionStatsByInstructor retrieves the question statistics of every course an instructor taught
+func GetQuestionStatsByInstructor(ctx context.Context, db DBTX, instructorID string) ([]models.QuestionTermStats, error) {
+	return queryQuestionStats(ctx, db,
+		"SELECT "+questionStatsColumns+" FROM api.survey_question_stats WHERE instructor_id = $1",
+		instructorID,
+	)
+}
+
+// GetQuestionStatsByCourse retrieves the question statistics of a course
+func GetQuestionStatsByCourse(ctx context.Context, db DBTX, courseID string) ([]models.QuestionTermStats, error) {
+	return queryQuestionStats(ctx, db,
+		"SELECT "+questionStatsColumns+" FROM api.survey_question_stats WHERE course_id = $1",
+		courseID,
+	)
+}
+
+// GetDepartmentQuestionStats retrieves the question statistics of the given departments and terms
+func GetDepartmentQuestionStats(ctx context.Context, db DBTX, departmentIDs []int, semesterTerms []string) ([]models.QuestionTermStats, error) {
+	ids := make([]int64, len(departmentIDs))
+	for i, id := range departmentIDs {
+		ids[i] = int64(id)
+	}
+	return queryQuestionStats(ctx, db,
+		"SELECT "+questionStatsColumns+" FROM api.survey_question_stats WHERE department_id = ANY($1) AND semester_term = ANY($2)",
+		pq.Array(ids), pq.Array(semesterTerms),
+	)
+}
+
+// RefreshSurveyQuestionStats rebuilds the analytics materialized view without blocking readers
+func RefreshSurveyQuestionStats(ctx context.Context, db *sql.DB) error {
+	_, err := execContext(ctx, db, "REFRESH MATERIALIZED VIEW CONCURRENTLY api.survey_question_stats")
+	return translateError(err)
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"context"
+
 	"api-server/internal/models"
 	"database/sql"
 	"time"
@@ -8,35 +10,35 @@ import (
 
 // CreateCourse creates a new course in the database
 
-func CreateCourse(db *sql.DB, course models.Course) (models.Course, error) {
+func CreateCourse(ctx context.Context, db DBTX, course models.Course) (models.Course, error) {
 
-	_, err := db.Exec(
+	_, err := execContext(ctx, db,
 		"INSERT INTO api.courses (course_id, date_added, date_last_updated, user_id, code, name, description, instructor_id, department_id, credit_hours) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
 		course.CourseID, course.DateAdded, course.DateLastUpdated, course.UserID, course.Code, course.Name, course.Description, course.InstructorID, course.DepartmentID, course.CreditHours,
 	)
 	if err != nil {
-		return models.Course{}, err
+		return models.Course{}, translateError(err)
 	}
-	return course, err
+	return course, translateError(err)
 }
 
 // GetCourseByID retrieves a course by its ID
-func GetCourseByID(db *sql.DB, courseID string) (*models.Course, error) {
+func GetCourseByID(ctx context.Context, db DBTX, courseID string) (*models.Course, error) {
 	course := &models.Course{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT course_id, date_added, date_last_updated, user_id, code, name, description, instructor_id, department_id, credit_hours FROM api.courses WHERE course_id = $1",
 		courseID,
 	).Scan(&course.CourseID, &course.DateAdded, &course.DateLastUpdated, &course.UserID, &course.Code, &course.Name, &course.Description, &course.InstructorID, &course.DepartmentID, &course.CreditHours)
-	return course, err
+	return course, translateError(err)
 }
 
 // GetAllCourses retrieves all courses
-func GetAllCourses(db *sql.DB) ([]models.Course, error) {
-	rows, err := db.Query(
+func GetAllCourses(ctx context.Context, db DBTX) ([]models.Course, error) {
+	rows, err := queryContext(ctx, db,
 		"SELECT course_id, date_added, date_last_updated, user_id, code, name, description, instructor_id, department_id, credit_hours FROM api.courses",
 	)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -44,7 +46,7 @@ func GetAllCourses(db *sql.DB) ([]models.Course, error) {
 	for rows.Next() {
 		var course models.Course
 		if err := rows.Scan(&course.CourseID, &course.DateAdded, &course.DateLastUpdated, &course.UserID, &course.Code, &course.Name, &course.Description, &course.InstructorID, &course.DepartmentID, &course.CreditHours); err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		courses = append(courses, course)
 	}
@@ -52,31 +54,31 @@ func GetAllCourses(db *sql.DB) ([]models.Course, error) {
 }
 
 // UpdateCourse updates a course in the database
-func UpdateCourse(db *sql.DB, course *models.Course) error {
+func UpdateCourse(ctx context.Context, db DBTX, course *models.Course) error {
 	course.DateLastUpdated = time.Now().UTC()
-	_, err := db.Exec(
+	_, err := execContext(ctx, db,
 		"UPDATE api.courses SET date_last_updated=$1, code=$2, name=$3, description=$4, instructor_id=$5, department_id=$6, credit_hours=$7 WHERE course_id=$8",
 		course.DateLastUpdated, course.Code, course.Name, course.Description, course.InstructorID, course.DepartmentID, course.CreditHours, course.CourseID,
 	)
-	return err
+	return translateError(err)
 }
 
 // delete course by ID
-func DeleteCourse(db *sql.DB, courseID string) error {
-	result, err := db.Exec(
+func DeleteCourse(ctx context.Context, db DBTX, courseID string) error {
+	result, err := execContext(ctx, db,
 		"DELETE FROM api.courses WHERE course_id = $1",
 		courseID,
 	)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return translateError(sql.ErrNoRows)
 	}
 
 	return nil
@@ -2,27 +2,27 @@
 package repositories
 
 import (
-	"database/sql"
+	"context"
 
 	"api-server/internal/models"
 )
 
-func GetDepartmentByID(db *sql.DB, departmentID int) (*models.Department, error) {
+func GetDepartmentByID(ctx context.Context, db DBTX, departmentID int) (*models.Department, error) {
 	department := &models.Department{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT department_id, name FROM api.departments WHERE department_id = $1",
 		departmentID,
 	).Scan(&department.DepartmentID, &department.Name)
-	return department, err
+	return department, translateError(err)
 }
 
 // GetAllDepartments retrieves all departments
-func GetAllDepartments(db *sql.DB) ([]models.Department, error) {
-	rows, err := db.Query(
+func GetAllDepartments(ctx context.Context, db DBTX) ([]models.Department, error) {
+	rows, err := queryContext(ctx, db,
 		"SELECT department_id, name FROM api.departments",
 	)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -30,7 +30,7 @@ func GetAllDepartments(db *sql.DB) ([]models.Department, error) {
 	for rows.Next() {
 		var department models.Department
 		if err := rows.Scan(&department.DepartmentID, &department.Name); err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		departments = append(departments, department)
 	}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Domain errors returned by the repositories. The original database error stays
+// in the chain, so errors.Is(err, sql.ErrNoRows) keeps working.
+var (
+	ErrNotFound            = errors.New("resource not found")
+	ErrConflict            = errors.New("resource already exists")
+	ErrForeignKeyViolation = errors.New("referenced resource does not exist")
+)
+
+// PostgreSQL error codes mapped to domain errors
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+// Error is a database error classified as one of the domain errors
+type Error struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + " (" + e.Constraint + ")"
+	}
+	return e.Kind.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// translateError classifies database errors as domain errors and leaves other errors untouched
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pqUniqueViolation:
+			return &Error{Kind: ErrConflict, Constraint: pqErr.Constraint, Err: err}
+		case pqForeignKeyViolation:
+			return &Error{Kind: ErrForeignKeyViolation, Constraint: pqErr.Constraint, Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"api-server/internal/models"
+)
+
+// ReserveIdempotencyKey records a request as in progress. It returns false when an
+// unexpired record for the same user, key and fingerprint already exists; an expired
+// record is taken over as if it did not exist.
+func ReserveIdempotencyKey(ctx context.Context, db DBTX, record models.IdempotencyKey) (bool, error) {
+	var reserved bool
+	err := queryRowContext(ctx, db,
+		`INSERT INTO api.idempotency_keys (user_id, idempotency_key, fingerprint, method, path, status, date_created, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, idempotency_key, fingerprint) DO UPDATE
+		SET method = EXCLUDED.method, path = EXCLUDED.path, status = EXCLUDED.status,
+			response_status = NULL, response_headers = NULL, response_body = NULL,
+			date_created = EXCLUDED.date_created, expires_at = EXCLUDED.expires_at
+		WHERE api.idempotency_keys.expires_at <= EXCLUDED.date_created
+		RETURNING true`,
+		record.UserID, record.Key, record.Fingerprint, record.Method, record.Path, record.Status, record.DateCreated, record.ExpiresAt,
+	).Scan(&reserved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, translateError(err)
+	}
+	return reserved, nil
+}
+
+// GetIdempotencyKey retrieves the record of a request
+func GetIdempotencyKey(ctx context.Context, db DBTX, userID, key, fingerprint string) (*models.IdempotencyKey, error) {
+	record := &models.IdempotencyKey{}
+	var responseStatus sql.NullInt64
+	var responseHeaders []byte
+	err := queryRowContext(ctx, db,
+		"SELECT user_id, idempotency_key, fingerprint, method, path, status, response_status, response_headers, response_body, date_created, expires_at FROM api.idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND fingerprint = $3",
+		userID, key, fingerprint,
+	).Scan(&record.UserID, &record.Key, &record.Fingerprint, &record.Method, &record.Path, &record.Status, &responseStatus, &responseHeaders, &record.ResponseBody, &record.DateCreated, &record.ExpiresAt)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	record.ResponseStatus = int(responseStatus.Int64)
+	if len(responseHeaders) > 0 {
+		if err := json.Unmarshal(responseHeaders, &record.ResponseHeaders); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// RenewIdempotencyKey extends the lease of a request that is still in progress
+func RenewIdempotencyKey(ctx context.Context, db DBTX, userID, key, fingerprint string, expiresAt time.Time) error {
+	_, err := execContext(ctx, db,
+		"UPDATE api.idempotency_keys SET expires_at = $1 WHERE user_id = $2 AND idempotency_key = $3 AND fingerprint = $4 AND status = $5",
+		expiresAt, userID, key, fingerprint, models.IdempotencyStatusInProgress,
+	)
+	return translateError(err)
+}
+
+// CompleteIdempotencyKey stores the response of a request so retries can replay it until
+// record.ExpiresAt
+func CompleteIdempotencyKey(ctx context.Context, db DBTX, record models.IdempotencyKey) error {
+	headers, err := json.Marshal(record.ResponseHeaders)
+	if err != nil {
+		return err
+	}
+	result, err := execContext(ctx, db,
+		"UPDATE api.idempotency_keys SET status = $1, response_status = $2, response_headers = $3, response_body = $4, expires_at = $5 WHERE user_id = $6 AND idempotency_key = $7 AND fingerprint = $8",
+		models.IdempotencyStatusCompleted, record.ResponseStatus, headers, record.ResponseBody, record.ExpiresAt, record.UserID, record.Key, record.Fingerprint,
+	)
+	if err != nil {
+		return translateError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return translateError(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// DeleteIdempotencyKey removes the record of a request so it can be retried
+func DeleteIdempotencyKey(ctx context.Context, db DBTX, userID, key, fingerprint string) error {
+	_, err := execContext(ctx, db,
+		"DELETE FROM api.idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND fingerprint = $3",
+		userID, key, fingerprint,
+	)
+	return translateError(err)
+}
+
+// DeleteExpiredIdempotencyKeys removes records that expired before now and returns how many were removed
+func DeleteExpiredIdempotencyKeys(ctx context.Context, db DBTX, now time.Time) (int64, error) {
+	result, err := execContext(ctx, db, "DELETE FROM api.idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	count, err := result.RowsAffected()
+	return count, translateError(err)
+}
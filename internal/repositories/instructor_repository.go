@@ -1,48 +1,50 @@
 package repositories
 
 import (
+	"context"
+
 	"api-server/internal/models"
 	"database/sql"
 )
 
 // CreateInstructor inserts a new instructor record into the database.
-func CreateInstructor(db *sql.DB, instructor models.Instructor) (models.Instructor, error) {
+func CreateInstructor(ctx context.Context, db DBTX, instructor models.Instructor) (models.Instructor, error) {
 	query := `
         INSERT INTO api.instructors (instructor_id, user_id, name, date_created)
         VALUES ($1, $2, $3, $4)
     `
-	_, err := db.Exec(query, instructor.InstructorID, instructor.UserID, instructor.Name, instructor.DateCreated)
+	_, err := execContext(ctx, db, query, instructor.InstructorID, instructor.UserID, instructor.Name, instructor.DateCreated)
 	if err != nil {
-		return models.Instructor{}, err
+		return models.Instructor{}, translateError(err)
 	}
 	return instructor, nil
 }
 
 // GetInstructorByID retrieves an instructor by instructor_id.
-func GetInstructorByID(db *sql.DB, instructorID string) (models.Instructor, error) {
+func GetInstructorByID(ctx context.Context, db DBTX, instructorID string) (models.Instructor, error) {
 	query := `
         SELECT instructor_id, user_id, name, date_created
         FROM api.instructors
         WHERE instructor_id = $1
     `
-	row := db.QueryRow(query, instructorID)
+	row := queryRowContext(ctx, db, query, instructorID)
 	var instructor models.Instructor
 	err := row.Scan(&instructor.InstructorID, &instructor.UserID, &instructor.Name, &instructor.DateCreated)
 	if err != nil {
-		return models.Instructor{}, err
+		return models.Instructor{}, translateError(err)
 	}
 	return instructor, nil
 }
 
 // GetAllInstructors retrieves all instructors
-func GetAllInstructors(db *sql.DB) ([]models.Instructor, error) {
+func GetAllInstructors(ctx context.Context, db DBTX) ([]models.Instructor, error) {
 	query := `
         SELECT instructor_id, user_id, name, date_created
         FROM api.instructors
     `
-	rows, err := db.Query(query)
+	rows, err := queryContext(ctx, db, query)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -51,7 +53,7 @@ func GetAllInstructors(db *sql.DB) ([]models.Instructor, error) {
 		var instructor models.Instructor
 		err := rows.Scan(&instructor.InstructorID, &instructor.UserID, &instructor.Name, &instructor.DateCreated)
 		if err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		instructors = append(instructors, instructor)
 	}
@@ -59,30 +61,30 @@ func GetAllInstructors(db *sql.DB) ([]models.Instructor, error) {
 }
 
 // UpdateInstructor updates the instructor's name.
-func UpdateInstructor(db *sql.DB, instructor models.Instructor) error {
+func UpdateInstructor(ctx context.Context, db DBTX, instructor models.Instructor) error {
 	query := `
         UPDATE api.instructors
         SET name = $1
         WHERE instructor_id = $2
     `
-	_, err := db.Exec(query, instructor.Name, instructor.InstructorID)
-	return err
+	_, err := execContext(ctx, db, query, instructor.Name, instructor.InstructorID)
+	return translateError(err)
 }
 
 // DeleteInstructor deletes an instructor by instructor_id.
-func DeleteInstructor(db *sql.DB, instructorID string) error {
+func DeleteInstructor(ctx context.Context, db DBTX, instructorID string) error {
 	query := `DELETE FROM api.instructors WHERE instructor_id = $1`
-	result, err := db.Exec(query, instructorID)
+	result, err := execContext(ctx, db, query, instructorID)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return translateError(sql.ErrNoRows)
 	}
 
 	return nil
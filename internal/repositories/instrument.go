@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTimeouts bound how long a single statement may run, on top of any deadline
+// of the request context it runs in
+type QueryTimeouts struct {
+	// Read applies to SELECT statements
+	Read time.Duration
+	// Write applies to every other statement
+	Write time.Duration
+	// Refresh applies to materialized view refreshes, which read every survey result
+	Refresh time.Duration
+}
+
+var (
+	queryTimeouts = QueryTimeouts{
+		Read:    5 * time.Second,
+		Write:   10 * time.Second,
+		Refresh: 5 * time.Minute,
+	}
+	queryTimeoutsLock sync.RWMutex
+)
+
+// SetQueryTimeouts overrides the statement timeouts that are non-zero
+func SetQueryTimeouts(timeouts QueryTimeouts) {
+	queryTimeoutsLock.Lock()
+	defer queryTimeoutsLock.Unlock()
+	if timeouts.Read > 0 {
+		queryTimeouts.Read = timeouts.Read
+	}
+	if timeouts.Write > 0 {
+		queryTimeouts.Write = timeouts.Write
+	}
+	if timeouts.Refresh > 0 {
+		queryTimeouts.Refresh = timeouts.Refresh
+	}
+}
+
+var tracer = otel.Tracer("api-server/repositories")
+
+var (
+	sqlStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlWhitespace    = regexp.MustCompile(`\s+`)
+)
+
+// sanitizeStatement masks string literals and collapses whitespace. Values are always
+// passed as parameters, so the statement itself carries no request data.
+func sanitizeStatement(query string) string {
+	query = sqlStringLiteral.ReplaceAllString(query, "'?'")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(query, " "))
+}
+
+// statementOperation returns the leading keyword of a statement, e.g. SELECT
+func statementOperation(query string) string {
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return strings.ToUpper(operation)
+}
+
+// startStatement starts the span of a statement and applies the timeout of its kind
+func startStatement(ctx context.Context, query string) (context.Context, trace.Span, context.CancelFunc) {
+	operation := statementOperation(query)
+	queryTimeoutsLock.RLock()
+	timeout := queryTimeouts.Write
+	readOnly := operation == "SELECT" && !strings.Contains(strings.ToUpper(query), "FOR UPDATE")
+	switch {
+	case readOnly:
+		timeout = queryTimeouts.Read
+	case operation == "REFRESH":
+		timeout = queryTimeouts.Refresh
+	}
+	queryTimeoutsLock.RUnlock()
+	if !readOnly {
+		replicas.recordWrite(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(sanitizeStatement(query)),
+		),
+	)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, span, cancel
+}
+
+// endStatement records the outcome of a statement; missing rows are not an error
+func endStatement(span trace.Span, cancel context.CancelFunc, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	cancel()
+}
+
+// execContext runs a statement that returns no rows
+func execContext(ctx context.Context, db DBTX, query string, args ...any) (sql.Result, error) {
+	ctx, span, cancel := startStatement(ctx, query)
+	result, err := db.ExecContext(ctx, query, args...)
+	if err == nil {
+		if affected, err := result.RowsAffected(); err == nil {
+			span.SetAttributes(attribute.Int64("db.rows_affected", affected))
+		}
+	}
+	endStatement(span, cancel, err)
+	return result, err
+}
+
+// Rows ends the span and timeout of a query when it is closed
+type Rows struct {
+	*sql.Rows
+	span     trace.Span
+	cancel   context.CancelFunc
+	returned int64
+	closed   bool
+}
+
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.returned++
+		return true
+	}
+	return false
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.span.SetAttributes(attribute.Int64("db.rows_returned", r.returned))
+		endStatement(r.span, r.cancel, r.Rows.Err())
+	}
+	return err
+}
+
+// queryContext runs a statement returning rows, which the caller must close
+func queryContext(ctx context.Context, db DBTX, query string, args ...any) (*Rows, error) {
+	ctx, span, cancel := startStatement(ctx, query)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		endStatement(span, cancel, err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, span: span, cancel: cancel}, nil
+}
+
+// Row ends the span and timeout of a single row query when it is scanned
+type Row struct {
+	row    *sql.Row
+	span   trace.Span
+	cancel context.CancelFunc
+}
+
+func (r *Row) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	endStatement(r.span, r.cancel, err)
+	return err
+}
+
+// queryRowContext runs a statement returning at most one row, which the caller must scan
+func queryRowContext(ctx context.Context, db DBTX, query string, args ...any) *Row {
+	ctx, span, cancel := startStatement(ctx, query)
+	return &Row{row: db.QueryRowContext(ctx, query, args...), span: span, cancel: cancel}
+}
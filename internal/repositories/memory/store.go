@@ -0,0 +1,1290 @@
+// Package memory provides an in-memory repositories.Store for handler unit tests
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// Store keeps every record in maps and reports missing and conflicting records with the
+// same domain errors as the PostgreSQL store. References to users are not checked.
+type Store struct {
+	db *database
+	// undo restores what the unit of work of this Store changed, newest change last.
+	// It is nil outside WithTx.
+	undo *[]func()
+}
+
+// database holds the records shared by a Store and the Stores of its units of work
+type database struct {
+	mu sync.Mutex
+	// txMu runs units of work one at a time
+	txMu  sync.Mutex
+	state state
+}
+
+type state struct {
+	users         map[string]models.User
+	courses       map[string]models.Course
+	traces        map[string]models.Trace
+	traceVersions map[string][]models.TraceVersion
+	instructors   map[string]models.Instructor
+	departments   map[int]models.Department
+	semesterTerms map[string]models.SemesterTermModel
+	surveyResults map[string]models.SurveyResult
+	questionStats map[questionStatsKey]models.QuestionTermStats
+	uploads       map[string]models.UploadSession
+	uploadChunks  map[string][]models.UploadChunk
+	reportJobs    map[string]models.ReportJob
+	reportClaims  map[string]reportClaim
+	webhooks      map[string]models.WebhookSubscription
+	deliveries    map[string]models.WebhookDelivery
+	idempotency   map[idempotencyKeyID]models.IdempotencyKey
+	traceEvents   map[int64]models.TraceStreamEvent
+	// lastEventID numbers trace events; like a sequence it is not rolled back
+	lastEventID int64
+}
+
+// reportClaim is the claim a worker holds on a running report job
+type reportClaim struct {
+	token      string
+	leaseUntil time.Time
+}
+
+// idempotencyKeyID identifies a row of api.idempotency_keys
+type idempotencyKeyID struct {
+	userID      string
+	key         string
+	fingerprint string
+}
+
+// questionStatsKey identifies a row of the survey_question_stats view
+type questionStatsKey struct {
+	courseID     string
+	instructorID string
+	semesterTerm string
+	position     int
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+	return &Store{db: &database{state: state{
+		users:         map[string]models.User{},
+		courses:       map[string]models.Course{},
+		traces:        map[string]models.Trace{},
+		traceVersions: map[string][]models.TraceVersion{},
+		instructors:   map[string]models.Instructor{},
+		departments:   map[int]models.Department{},
+		semesterTerms: map[string]models.SemesterTermModel{},
+		surveyResults: map[string]models.SurveyResult{},
+		questionStats: map[questionStatsKey]models.QuestionTermStats{},
+		uploads:       map[string]models.UploadSession{},
+		uploadChunks:  map[string][]models.UploadChunk{},
+		reportJobs:    map[string]models.ReportJob{},
+		reportClaims:  map[string]reportClaim{},
+		webhooks:      map[string]models.WebhookSubscription{},
+		deliveries:    map[string]models.WebhookDelivery{},
+		idempotency:   map[idempotencyKeyID]models.IdempotencyKey{},
+		traceEvents:   map[int64]models.TraceStreamEvent{},
+	}}}
+}
+
+var _ repositories.Store = (*Store)(nil)
+
+func notFound() error {
+	return &repositories.Error{Kind: repositories.ErrNotFound, Err: sql.ErrNoRows}
+}
+
+func conflict(constraint string) error {
+	return &repositories.Error{Kind: repositories.ErrConflict, Constraint: constraint}
+}
+
+func missingReference(constraint string) error {
+	return &repositories.Error{Kind: repositories.ErrForeignKeyViolation, Constraint: constraint}
+}
+
+// AddDepartment adds a department, which the API only reads
+func (s *Store) AddDepartment(department models.Department) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	remember(s, s.db.state.departments, department.DepartmentID)
+	s.db.state.departments[department.DepartmentID] = department
+}
+
+// AddSemesterTerm adds a semester term, which the API only reads
+func (s *Store) AddSemesterTerm(semesterTerm models.SemesterTermModel) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	remember(s, s.db.state.semesterTerms, semesterTerm.SemesterTerm)
+	s.db.state.semesterTerms[semesterTerm.SemesterTerm] = semesterTerm
+}
+
+// AddQuestionStats adds a row of question statistics, which PostgreSQL aggregates from the
+// survey results in a materialized view
+func (s *Store) AddQuestionStats(stats models.QuestionTermStats) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	key := questionStatsKey{stats.CourseID, stats.InstructorID, stats.SemesterTerm, stats.Position}
+	remember(s, s.db.state.questionStats, key)
+	s.db.state.questionStats[key] = stats
+}
+
+// TraceEvents returns the trace stream events sent so far, oldest first
+func (s *Store) TraceEvents() []models.TraceStreamEvent {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	events := make([]models.TraceStreamEvent, 0, len(s.db.state.traceEvents))
+	for _, event := range s.db.state.traceEvents {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+// WithTx runs fn with a Store that logs how to undo each of its writes, and undoes them
+// newest first when fn fails. Writes made outside the unit of work are kept. Units of
+// work run one at a time; nested ones join the outer one.
+func (s *Store) WithTx(ctx context.Context, fn func(tx repositories.Store) error) error {
+	if s.undo != nil {
+		return fn(s)
+	}
+	s.db.txMu.Lock()
+	defer s.db.txMu.Unlock()
+
+	undo := []func(){}
+	if err := fn(&Store{db: s.db, undo: &undo}); err != nil {
+		s.db.mu.Lock()
+		defer s.db.mu.Unlock()
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return err
+	}
+	return nil
+}
+
+// remember logs how to restore the record at key of m before a unit of work changes it.
+// The caller holds db.mu.
+func remember[K comparable, V any](s *Store, m map[K]V, key K) {
+	if s.undo == nil {
+		return
+	}
+	previous, existed := m[key]
+	*s.undo = append(*s.undo, func() {
+		if existed {
+			m[key] = previous
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+func (s *Store) CreateUser(ctx context.Context, userReq models.UserRequest) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, existing := range s.db.state.users {
+		if existing.Username == userReq.Username {
+			return nil, conflict("users_username_key")
+		}
+	}
+	now := time.Now().UTC()
+	user := models.User{
+		UserID:         uuid.New().String(),
+		FirstName:      userReq.FirstName,
+		LastName:       userReq.LastName,
+		Password:       userReq.Password,
+		Username:       userReq.Username,
+		AccountCreated: now,
+		AccountUpdated: now,
+	}
+	remember(s, s.db.state.users, user.UserID)
+	s.db.state.users[user.UserID] = user
+	return &user, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	user, ok := s.db.state.users[userID]
+	if !ok {
+		return &models.User{}, notFound()
+	}
+	return &user, nil
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, user := range s.db.state.users {
+		if user.Username == username {
+			// like the PostgreSQL store, the lookup does not return the password or timestamps
+			return &models.User{UserID: user.UserID, FirstName: user.FirstName, LastName: user.LastName, Username: user.Username}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Store) GetUserWithPasswordByUsername(ctx context.Context, username string) (*repositories.UserWithPassword, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, user := range s.db.state.users {
+		if user.Username == username {
+			password := user.Password
+			user.Password = ""
+			return &repositories.UserWithPassword{User: user, Password: password}, nil
+		}
+	}
+	return nil, notFound()
+}
+
+func (s *Store) UpdateUser(ctx context.Context, user *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	user.AccountUpdated = time.Now().UTC()
+	existing, ok := s.db.state.users[user.UserID]
+	if !ok {
+		return nil
+	}
+	existing.FirstName = user.FirstName
+	existing.LastName = user.LastName
+	existing.Username = user.Username
+	existing.Password = user.Password
+	existing.AccountUpdated = user.AccountUpdated
+	remember(s, s.db.state.users, user.UserID)
+	s.db.state.users[user.UserID] = existing
+	return nil
+}
+
+// checkCourseReferences reports a missing instructor or department of a course
+func (s *Store) checkCourseReferences(course models.Course) error {
+	if _, ok := s.db.state.instructors[course.InstructorID]; !ok {
+		return missingReference("courses_instructor_id_fkey")
+	}
+	if _, ok := s.db.state.departments[course.DepartmentID]; !ok {
+		return missingReference("courses_department_id_fkey")
+	}
+	return nil
+}
+
+func (s *Store) CreateCourse(ctx context.Context, course models.Course) (models.Course, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.courses[course.CourseID]; ok {
+		return models.Course{}, conflict("courses_pkey")
+	}
+	if err := s.checkCourseReferences(course); err != nil {
+		return models.Course{}, err
+	}
+	remember(s, s.db.state.courses, course.CourseID)
+	s.db.state.courses[course.CourseID] = course
+	return course, nil
+}
+
+func (s *Store) GetCourseByID(ctx context.Context, courseID string) (*models.Course, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	course, ok := s.db.state.courses[courseID]
+	if !ok {
+		return &models.Course{}, notFound()
+	}
+	return &course, nil
+}
+
+func (s *Store) GetAllCourses(ctx context.Context) ([]models.Course, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	courses := []models.Course{}
+	for _, course := range s.db.state.courses {
+		courses = append(courses, course)
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].CourseID < courses[j].CourseID })
+	return courses, nil
+}
+
+func (s *Store) UpdateCourse(ctx context.Context, course *models.Course) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	course.DateLastUpdated = time.Now().UTC()
+	existing, ok := s.db.state.courses[course.CourseID]
+	if !ok {
+		return nil
+	}
+	if err := s.checkCourseReferences(*course); err != nil {
+		return err
+	}
+	// the owner and creation date are never updated
+	course.UserID = existing.UserID
+	course.DateAdded = existing.DateAdded
+	remember(s, s.db.state.courses, course.CourseID)
+	s.db.state.courses[course.CourseID] = *course
+	return nil
+}
+
+func (s *Store) DeleteCourse(ctx context.Context, courseID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.courses[courseID]; !ok {
+		return notFound()
+	}
+	for _, trace := range s.db.state.traces {
+		if trace.CourseID == courseID {
+			return missingReference("traces_course_id_fkey")
+		}
+	}
+	remember(s, s.db.state.courses, courseID)
+	delete(s.db.state.courses, courseID)
+	return nil
+}
+
+// checkTraceReferences reports a missing course, instructor or semester term of a trace
+func (s *Store) checkTraceReferences(trace models.Trace) error {
+	if _, ok := s.db.state.courses[trace.CourseID]; !ok {
+		return missingReference("traces_course_id_fkey")
+	}
+	if _, ok := s.db.state.instructors[trace.InstructorID]; !ok {
+		return missingReference("traces_instructor_id_fkey")
+	}
+	if _, ok := s.db.state.semesterTerms[trace.SemesterTerm]; !ok {
+		return missingReference("traces_semester_term_fkey")
+	}
+	return nil
+}
+
+func (s *Store) CreateTrace(ctx context.Context, trace models.Trace) (models.Trace, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.traces[trace.TraceID]; ok {
+		return models.Trace{}, conflict("traces_pkey")
+	}
+	if err := s.checkTraceReferences(trace); err != nil {
+		return models.Trace{}, err
+	}
+	remember(s, s.db.state.traces, trace.TraceID)
+	s.db.state.traces[trace.TraceID] = trace
+	remember(s, s.db.state.traceVersions, trace.TraceID)
+	s.db.state.traceVersions[trace.TraceID] = []models.TraceVersion{{
+		TraceID:       trace.TraceID,
+		VersionNumber: 1,
+		FileName:      trace.FileName,
+		BucketPath:    trace.BucketPath,
+		UserID:        trace.UserID,
+		DateCreated:   trace.DateCreated,
+	}}
+	return trace, nil
+}
+
+func (s *Store) GetTraceByID(ctx context.Context, traceID string) (*models.Trace, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	trace, ok := s.db.state.traces[traceID]
+	if !ok {
+		return &models.Trace{}, notFound()
+	}
+	return &trace, nil
+}
+
+func (s *Store) GetAllTraces(ctx context.Context) ([]models.Trace, error) {
+	return s.filterTraces(func(models.Trace) bool { return true }), nil
+}
+
+func (s *Store) GetTraceByCourseID(ctx context.Context, courseID string) ([]models.Trace, error) {
+	return s.filterTraces(func(trace models.Trace) bool { return trace.CourseID == courseID }), nil
+}
+
+func (s *Store) GetTracesByInstructorAndTerm(ctx context.Context, instructorID, semesterTerm string) ([]models.Trace, error) {
+	traces := s.filterTraces(func(trace models.Trace) bool {
+		return trace.InstructorID == instructorID && trace.SemesterTerm == semesterTerm
+	})
+	sort.SliceStable(traces, func(i, j int) bool {
+		if traces[i].CourseID != traces[j].CourseID {
+			return traces[i].CourseID < traces[j].CourseID
+		}
+		return traces[i].Section < traces[j].Section
+	})
+	return traces, nil
+}
+
+func (s *Store) filterTraces(keep func(models.Trace) bool) []models.Trace {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	traces := []models.Trace{}
+	for _, trace := range s.db.state.traces {
+		if keep(trace) {
+			traces = append(traces, trace)
+		}
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i].TraceID < traces[j].TraceID })
+	return traces
+}
+
+func (s *Store) UpdateTrace(ctx context.Context, trace *models.Trace) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	existing, ok := s.db.state.traces[trace.TraceID]
+	if !ok {
+		return notFound()
+	}
+	if err := s.checkTraceReferences(*trace); err != nil {
+		return err
+	}
+	existing.CourseID = trace.CourseID
+	existing.InstructorID = trace.InstructorID
+	existing.SemesterTerm = trace.SemesterTerm
+	existing.Section = trace.Section
+	remember(s, s.db.state.traces, trace.TraceID)
+	s.db.state.traces[trace.TraceID] = existing
+	return nil
+}
+
+func (s *Store) DeleteTrace(ctx context.Context, traceID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.traces[traceID]; !ok {
+		return notFound()
+	}
+	remember(s, s.db.state.traces, traceID)
+	delete(s.db.state.traces, traceID)
+	remember(s, s.db.state.traceVersions, traceID)
+	delete(s.db.state.traceVersions, traceID)
+	remember(s, s.db.state.surveyResults, traceID)
+	delete(s.db.state.surveyResults, traceID)
+	return nil
+}
+
+func (s *Store) AddTraceVersion(ctx context.Context, version models.TraceVersion) (models.TraceVersion, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	trace, ok := s.db.state.traces[version.TraceID]
+	if !ok {
+		return models.TraceVersion{}, notFound()
+	}
+	versions := s.db.state.traceVersions[version.TraceID]
+	if len(versions) == 0 {
+		versions = []models.TraceVersion{{
+			TraceID:       trace.TraceID,
+			VersionNumber: 1,
+			FileName:      trace.FileName,
+			BucketPath:    trace.BucketPath,
+			UserID:        trace.UserID,
+			DateCreated:   trace.DateCreated,
+		}}
+	}
+	version.VersionNumber = versions[len(versions)-1].VersionNumber + 1
+	remember(s, s.db.state.traceVersions, version.TraceID)
+	s.db.state.traceVersions[version.TraceID] = append(versions, version)
+
+	trace.FileName = version.FileName
+	trace.BucketPath = version.BucketPath
+	remember(s, s.db.state.traces, version.TraceID)
+	s.db.state.traces[version.TraceID] = trace
+	return version, nil
+}
+
+func (s *Store) GetTraceVersions(ctx context.Context, traceID string) ([]models.TraceVersion, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	stored := s.db.state.traceVersions[traceID]
+	versions := make([]models.TraceVersion, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		versions = append(versions, stored[i])
+	}
+	return versions, nil
+}
+
+func (s *Store) GetTraceVersion(ctx context.Context, traceID string, versionNumber int) (*models.TraceVersion, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, version := range s.db.state.traceVersions[traceID] {
+		if version.VersionNumber == versionNumber {
+			return &version, nil
+		}
+	}
+	return &models.TraceVersion{}, notFound()
+}
+
+func (s *Store) DeleteTraceVersions(ctx context.Context, traceID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	remember(s, s.db.state.traceVersions, traceID)
+	delete(s.db.state.traceVersions, traceID)
+	return nil
+}
+
+func (s *Store) GetInstructorIDsByDepartmentAndTerm(ctx context.Context, departmentID int, semesterTerm string) ([]string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	instructorIDs := []string{}
+	for _, trace := range s.db.state.traces {
+		course, ok := s.db.state.courses[trace.CourseID]
+		if !ok || course.DepartmentID != departmentID || trace.SemesterTerm != semesterTerm || slices.Contains(instructorIDs, trace.InstructorID) {
+			continue
+		}
+		instructorIDs = append(instructorIDs, trace.InstructorID)
+	}
+	sort.Strings(instructorIDs)
+	return instructorIDs, nil
+}
+
+// NotifyTraceEvent keeps the event so tests can read it with TraceEvents
+func (s *Store) NotifyTraceEvent(ctx context.Context, event models.TraceStreamEvent) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.state.lastEventID++
+	event.ID = s.db.state.lastEventID
+	remember(s, s.db.state.traceEvents, event.ID)
+	s.db.state.traceEvents[event.ID] = event
+	return nil
+}
+
+func (s *Store) CreateInstructor(ctx context.Context, instructor models.Instructor) (models.Instructor, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.instructors[instructor.InstructorID]; ok {
+		return models.Instructor{}, conflict("instructors_pkey")
+	}
+	remember(s, s.db.state.instructors, instructor.InstructorID)
+	s.db.state.instructors[instructor.InstructorID] = instructor
+	return instructor, nil
+}
+
+func (s *Store) GetInstructorByID(ctx context.Context, instructorID string) (models.Instructor, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	instructor, ok := s.db.state.instructors[instructorID]
+	if !ok {
+		return models.Instructor{}, notFound()
+	}
+	return instructor, nil
+}
+
+func (s *Store) GetAllInstructors(ctx context.Context) ([]models.Instructor, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	instructors := []models.Instructor{}
+	for _, instructor := range s.db.state.instructors {
+		instructors = append(instructors, instructor)
+	}
+	sort.Slice(instructors, func(i, j int) bool { return instructors[i].InstructorID < instructors[j].InstructorID })
+	return instructors, nil
+}
+
+func (s *Store) UpdateInstructor(ctx context.Context, instructor models.Instructor) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	existing, ok := s.db.state.instructors[instructor.InstructorID]
+	if !ok {
+		return nil
+	}
+	existing.Name = instructor.Name
+	remember(s, s.db.state.instructors, instructor.InstructorID)
+	s.db.state.instructors[instructor.InstructorID] = existing
+	return nil
+}
+
+func (s *Store) DeleteInstructor(ctx context.Context, instructorID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.instructors[instructorID]; !ok {
+		return notFound()
+	}
+	for _, course := range s.db.state.courses {
+		if course.InstructorID == instructorID {
+			return missingReference("courses_instructor_id_fkey")
+		}
+	}
+	for _, trace := range s.db.state.traces {
+		if trace.InstructorID == instructorID {
+			return missingReference("traces_instructor_id_fkey")
+		}
+	}
+	remember(s, s.db.state.instructors, instructorID)
+	delete(s.db.state.instructors, instructorID)
+	return nil
+}
+
+func (s *Store) GetDepartmentByID(ctx context.Context, departmentID int) (*models.Department, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	department, ok := s.db.state.departments[departmentID]
+	if !ok {
+		return &models.Department{}, notFound()
+	}
+	return &department, nil
+}
+
+func (s *Store) GetAllDepartments(ctx context.Context) ([]models.Department, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	departments := []models.Department{}
+	for _, department := range s.db.state.departments {
+		departments = append(departments, department)
+	}
+	sort.Slice(departments, func(i, j int) bool { return departments[i].DepartmentID < departments[j].DepartmentID })
+	return departments, nil
+}
+
+func (s *Store) GetSemesterTerm(ctx context.Context, semesterTerm string) (*models.SemesterTermModel, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	term, ok := s.db.state.semesterTerms[semesterTerm]
+	if !ok {
+		return &models.SemesterTermModel{}, notFound()
+	}
+	return &term, nil
+}
+
+func (s *Store) GetAllSemesterTerms(ctx context.Context) ([]models.SemesterTermModel, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	semesterTerms := []models.SemesterTermModel{}
+	for _, term := range s.db.state.semesterTerms {
+		semesterTerms = append(semesterTerms, term)
+	}
+	sort.Slice(semesterTerms, func(i, j int) bool { return semesterTerms[i].SemesterTerm < semesterTerms[j].SemesterTerm })
+	return semesterTerms, nil
+}
+
+func (s *Store) SaveSurveyResult(ctx context.Context, traceID string, req models.SurveyResultRequest) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.traces[traceID]; !ok {
+		return missingReference("survey_results_trace_id_fkey")
+	}
+	result := models.SurveyResult{
+		TraceID:          traceID,
+		ResponseCount:    req.ResponseCount,
+		EnrolledCount:    req.EnrolledCount,
+		ProcessorVersion: req.ProcessorVersion,
+		DateProcessed:    time.Now().UTC(),
+		Questions:        []models.SurveyQuestion{},
+		Comments:         []models.SurveyComment{},
+	}
+	for _, questionReq := range req.Questions {
+		question := models.SurveyQuestion{
+			QuestionID:    uuid.New().String(),
+			Position:      questionReq.Position,
+			Category:      questionReq.Category,
+			Text:          questionReq.Text,
+			ResponseCount: questionReq.ResponseCount,
+			Ratings:       append([]models.RatingCount{}, questionReq.Ratings...),
+		}
+		sort.Slice(question.Ratings, func(i, j int) bool { return question.Ratings[i].Rating < question.Ratings[j].Rating })
+		question.Mean = repositories.RatingMean(question.Ratings)
+		result.Questions = append(result.Questions, question)
+	}
+	sort.SliceStable(result.Questions, func(i, j int) bool { return result.Questions[i].Position < result.Questions[j].Position })
+	for _, comment := range req.Comments {
+		result.Comments = append(result.Comments, models.SurveyComment{CommentID: uuid.New().String(), Question: comment.Question, Text: comment.Text})
+	}
+	remember(s, s.db.state.surveyResults, traceID)
+	s.db.state.surveyResults[traceID] = result
+	return nil
+}
+
+func (s *Store) GetSurveyResult(ctx context.Context, traceID string) (*models.SurveyResult, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	result, ok := s.db.state.surveyResults[traceID]
+	if !ok {
+		return nil, notFound()
+	}
+	return &result, nil
+}
+
+func (s *Store) GetSurveyComments(ctx context.Context, traceID string) ([]models.SurveyComment, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	return append([]models.SurveyComment{}, s.db.state.surveyResults[traceID].Comments...), nil
+}
+
+func (s *Store) GetQuestionStatsByInstructor(ctx context.Context, instructorID string) ([]models.QuestionTermStats, error) {
+	return s.filterQuestionStats(func(stats models.QuestionTermStats) bool { return stats.InstructorID == instructorID }), nil
+}
+
+func (s *Store) GetQuestionStatsByCourse(ctx context.Context, courseID string) ([]models.QuestionTermStats, error) {
+	return s.filterQuestionStats(func(stats models.QuestionTermStats) bool { return stats.CourseID == courseID }), nil
+}
+
+func (s *Store) GetDepartmentQuestionStats(ctx context.Context, departmentIDs []int, semesterTerms []string) ([]models.QuestionTermStats, error) {
+	return s.filterQuestionStats(func(stats models.QuestionTermStats) bool {
+		return slices.Contains(departmentIDs, stats.DepartmentID) && slices.Contains(semesterTerms, stats.SemesterTerm)
+	}), nil
+}
+
+func (s *Store) filterQuestionStats(keep func(models.QuestionTermStats) bool) []models.QuestionTermStats {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	rows := []models.QuestionTermStats{}
+	for _, stats := range s.db.state.questionStats {
+		if keep(stats) {
+			rows = append(rows, stats)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.CourseID != b.CourseID {
+			return a.CourseID < b.CourseID
+		}
+		if a.SemesterTerm != b.SemesterTerm {
+			return a.SemesterTerm < b.SemesterTerm
+		}
+		if a.InstructorID != b.InstructorID {
+			return a.InstructorID < b.InstructorID
+		}
+		return a.Position < b.Position
+	})
+	return rows
+}
+
+func (s *Store) CreateUploadSession(ctx context.Context, session models.UploadSession) (models.UploadSession, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.uploads[session.UploadID]; ok {
+		return models.UploadSession{}, conflict("upload_sessions_pkey")
+	}
+	if _, ok := s.db.state.courses[session.CourseID]; !ok {
+		return models.UploadSession{}, missingReference("upload_sessions_course_id_fkey")
+	}
+	remember(s, s.db.state.uploads, session.UploadID)
+	s.db.state.uploads[session.UploadID] = session
+	return session, nil
+}
+
+func (s *Store) GetUploadSession(ctx context.Context, uploadID string) (*models.UploadSession, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.state.uploads[uploadID]
+	if !ok {
+		return &models.UploadSession{}, notFound()
+	}
+	return &session, nil
+}
+
+func (s *Store) AppendUploadChunk(ctx context.Context, chunk models.UploadChunk, expiresAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.state.uploads[chunk.UploadID]
+	if !ok || session.UploadOffset != chunk.ChunkOffset || session.Status != models.UploadStatusPending {
+		return notFound()
+	}
+	session.UploadOffset = chunk.ChunkOffset + chunk.ChunkSize
+	session.ExpiresAt = expiresAt
+	if session.UploadOffset >= session.UploadLength {
+		session.Status = models.UploadStatusAssembling
+	}
+	remember(s, s.db.state.uploads, chunk.UploadID)
+	s.db.state.uploads[chunk.UploadID] = session
+	remember(s, s.db.state.uploadChunks, chunk.UploadID)
+	s.db.state.uploadChunks[chunk.UploadID] = append(append([]models.UploadChunk{}, s.db.state.uploadChunks[chunk.UploadID]...), chunk)
+	return nil
+}
+
+func (s *Store) GetUploadChunks(ctx context.Context, uploadID string) ([]models.UploadChunk, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	return append([]models.UploadChunk{}, s.db.state.uploadChunks[uploadID]...), nil
+}
+
+func (s *Store) FinishUploadSession(ctx context.Context, uploadID, status string, traceID, errMessage *string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if session, ok := s.db.state.uploads[uploadID]; ok {
+		now := time.Now().UTC()
+		session.Status = status
+		session.TraceID = traceID
+		session.Error = errMessage
+		session.DateFinished = &now
+		remember(s, s.db.state.uploads, uploadID)
+		s.db.state.uploads[uploadID] = session
+	}
+	remember(s, s.db.state.uploadChunks, uploadID)
+	delete(s.db.state.uploadChunks, uploadID)
+	return nil
+}
+
+func (s *Store) DeleteUploadSession(ctx context.Context, uploadID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.uploads[uploadID]; !ok {
+		return notFound()
+	}
+	remember(s, s.db.state.uploadChunks, uploadID)
+	delete(s.db.state.uploadChunks, uploadID)
+	remember(s, s.db.state.uploads, uploadID)
+	delete(s.db.state.uploads, uploadID)
+	return nil
+}
+
+func (s *Store) DeleteExpiredUploadSessions(ctx context.Context, now, staleBefore time.Time) (int, []models.UploadChunk, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	deleted := 0
+	chunks := []models.UploadChunk{}
+	for uploadID, session := range s.db.state.uploads {
+		if !session.ExpiresAt.Before(now) || (session.Status == models.UploadStatusAssembling && !session.ExpiresAt.Before(staleBefore)) {
+			continue
+		}
+		chunks = append(chunks, s.db.state.uploadChunks[uploadID]...)
+		remember(s, s.db.state.uploadChunks, uploadID)
+		delete(s.db.state.uploadChunks, uploadID)
+		remember(s, s.db.state.uploads, uploadID)
+		delete(s.db.state.uploads, uploadID)
+		deleted++
+	}
+	return deleted, chunks, nil
+}
+
+func (s *Store) CreateReportJob(ctx context.Context, job models.ReportJob) (models.ReportJob, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.reportJobs[job.JobID]; ok {
+		return models.ReportJob{}, conflict("report_jobs_pkey")
+	}
+	if _, ok := s.db.state.departments[job.DepartmentID]; !ok {
+		return models.ReportJob{}, missingReference("report_jobs_department_id_fkey")
+	}
+	if _, ok := s.db.state.semesterTerms[job.SemesterTerm]; !ok {
+		return models.ReportJob{}, missingReference("report_jobs_semester_term_fkey")
+	}
+	job.Total, job.Completed, job.Failed = 0, 0, 0
+	job.Files = []models.ReportFile{}
+	remember(s, s.db.state.reportJobs, job.JobID)
+	s.db.state.reportJobs[job.JobID] = job
+	return job, nil
+}
+
+func (s *Store) GetReportJob(ctx context.Context, jobID string) (*models.ReportJob, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, ok := s.db.state.reportJobs[jobID]
+	if !ok {
+		return nil, notFound()
+	}
+	job.Files = append([]models.ReportFile{}, job.Files...)
+	return &job, nil
+}
+
+func (s *Store) GetReportFile(ctx context.Context, jobID, instructorID string) (*models.ReportFile, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, file := range s.db.state.reportJobs[jobID].Files {
+		if file.InstructorID == instructorID {
+			return &file, nil
+		}
+	}
+	return &models.ReportFile{}, notFound()
+}
+
+func (s *Store) ClaimReportJob(ctx context.Context, now time.Time, lease time.Duration) (*models.ReportJob, string, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var claimed *models.ReportJob
+	for _, job := range s.db.state.reportJobs {
+		waiting := job.Status == models.ReportJobPending ||
+			(job.Status == models.ReportJobRunning && !s.db.state.reportClaims[job.JobID].leaseUntil.After(now))
+		if waiting && (claimed == nil || job.DateCreated.Before(claimed.DateCreated)) {
+			claimed = &job
+		}
+	}
+	if claimed == nil {
+		return nil, "", notFound()
+	}
+	token := uuid.New().String()
+	claimed.Status = models.ReportJobRunning
+	remember(s, s.db.state.reportJobs, claimed.JobID)
+	s.db.state.reportJobs[claimed.JobID] = *claimed
+	remember(s, s.db.state.reportClaims, claimed.JobID)
+	s.db.state.reportClaims[claimed.JobID] = reportClaim{token: token, leaseUntil: now.Add(lease)}
+	job := *claimed
+	job.Files = append([]models.ReportFile{}, job.Files...)
+	return &job, token, nil
+}
+
+// claimedReportJob returns a job while it is running under token. The caller holds db.mu.
+func (s *Store) claimedReportJob(jobID, token string) (models.ReportJob, error) {
+	job, ok := s.db.state.reportJobs[jobID]
+	if !ok || job.Status != models.ReportJobRunning || s.db.state.reportClaims[jobID].token != token {
+		return models.ReportJob{}, notFound()
+	}
+	return job, nil
+}
+
+func (s *Store) RenewReportJobLease(ctx context.Context, jobID, token string, leaseUntil time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, err := s.claimedReportJob(jobID, token); err != nil {
+		return err
+	}
+	remember(s, s.db.state.reportClaims, jobID)
+	s.db.state.reportClaims[jobID] = reportClaim{token: token, leaseUntil: leaseUntil}
+	return nil
+}
+
+func (s *Store) ReleaseReportJob(ctx context.Context, jobID, token string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, err := s.claimedReportJob(jobID, token)
+	if err != nil {
+		return err
+	}
+	job.Status = models.ReportJobPending
+	remember(s, s.db.state.reportJobs, jobID)
+	s.db.state.reportJobs[jobID] = job
+	remember(s, s.db.state.reportClaims, jobID)
+	delete(s.db.state.reportClaims, jobID)
+	return nil
+}
+
+func (s *Store) StartReportJob(ctx context.Context, jobID, token string, total int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, err := s.claimedReportJob(jobID, token)
+	if err != nil {
+		return err
+	}
+	job.Total, job.Completed, job.Failed = total, 0, 0
+	job.Error = nil
+	job.Files = []models.ReportFile{}
+	remember(s, s.db.state.reportJobs, jobID)
+	s.db.state.reportJobs[jobID] = job
+	return nil
+}
+
+func (s *Store) AddReportFile(ctx context.Context, token string, file models.ReportFile) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, err := s.claimedReportJob(file.JobID, token)
+	if err != nil {
+		return err
+	}
+	for _, existing := range job.Files {
+		if existing.InstructorID == file.InstructorID {
+			return conflict("report_files_pkey")
+		}
+	}
+	if file.Error != nil {
+		job.Failed++
+	} else {
+		job.Completed++
+	}
+	job.Files = append(append([]models.ReportFile{}, job.Files...), file)
+	sort.Slice(job.Files, func(i, j int) bool { return job.Files[i].InstructorID < job.Files[j].InstructorID })
+	remember(s, s.db.state.reportJobs, file.JobID)
+	s.db.state.reportJobs[file.JobID] = job
+	return nil
+}
+
+func (s *Store) FinishReportJob(ctx context.Context, jobID, token, status string, errMessage *string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	job, err := s.claimedReportJob(jobID, token)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	job.Status = status
+	job.Error = errMessage
+	job.DateFinished = &now
+	remember(s, s.db.state.reportJobs, jobID)
+	s.db.state.reportJobs[jobID] = job
+	remember(s, s.db.state.reportClaims, jobID)
+	delete(s.db.state.reportClaims, jobID)
+	return nil
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, webhook models.WebhookSubscription) (models.WebhookSubscription, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.webhooks[webhook.WebhookID]; ok {
+		return models.WebhookSubscription{}, conflict("webhook_subscriptions_pkey")
+	}
+	if webhook.DepartmentID != nil {
+		if _, ok := s.db.state.departments[*webhook.DepartmentID]; !ok {
+			return models.WebhookSubscription{}, missingReference("webhook_subscriptions_department_id_fkey")
+		}
+	}
+	remember(s, s.db.state.webhooks, webhook.WebhookID)
+	s.db.state.webhooks[webhook.WebhookID] = webhook
+	return webhook, nil
+}
+
+func (s *Store) GetWebhook(ctx context.Context, webhookID string) (*models.WebhookSubscription, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	webhook, ok := s.db.state.webhooks[webhookID]
+	if !ok {
+		return &models.WebhookSubscription{}, notFound()
+	}
+	return &webhook, nil
+}
+
+func (s *Store) GetWebhooksByUser(ctx context.Context, userID string) ([]models.WebhookSubscription, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	webhooks := []models.WebhookSubscription{}
+	for _, webhook := range s.db.state.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].DateCreated.Before(webhooks[j].DateCreated) })
+	return webhooks, nil
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, webhook *models.WebhookSubscription) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	existing, ok := s.db.state.webhooks[webhook.WebhookID]
+	if !ok {
+		return notFound()
+	}
+	if webhook.DepartmentID != nil {
+		if _, ok := s.db.state.departments[*webhook.DepartmentID]; !ok {
+			return missingReference("webhook_subscriptions_department_id_fkey")
+		}
+	}
+	existing.URL = webhook.URL
+	existing.EventTypes = webhook.EventTypes
+	existing.DepartmentID = webhook.DepartmentID
+	existing.Description = webhook.Description
+	existing.Active = webhook.Active
+	existing.FailureCount = webhook.FailureCount
+	existing.DisabledReason = webhook.DisabledReason
+	existing.DateUpdated = webhook.DateUpdated
+	remember(s, s.db.state.webhooks, webhook.WebhookID)
+	s.db.state.webhooks[webhook.WebhookID] = existing
+	return nil
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, webhookID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.state.webhooks[webhookID]; !ok {
+		return notFound()
+	}
+	for deliveryID, delivery := range s.db.state.deliveries {
+		if delivery.WebhookID == webhookID {
+			remember(s, s.db.state.deliveries, deliveryID)
+			delete(s.db.state.deliveries, deliveryID)
+		}
+	}
+	remember(s, s.db.state.webhooks, webhookID)
+	delete(s.db.state.webhooks, webhookID)
+	return nil
+}
+
+func (s *Store) CreateWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	// checked up front so a failing batch adds none of its deliveries, like the transaction
+	for _, delivery := range deliveries {
+		if _, ok := s.db.state.deliveries[delivery.DeliveryID]; ok {
+			return conflict("webhook_deliveries_pkey")
+		}
+		if _, ok := s.db.state.webhooks[delivery.WebhookID]; !ok {
+			return missingReference("webhook_deliveries_webhook_id_fkey")
+		}
+	}
+	for _, delivery := range deliveries {
+		remember(s, s.db.state.deliveries, delivery.DeliveryID)
+		s.db.state.deliveries[delivery.DeliveryID] = delivery
+	}
+	return nil
+}
+
+func (s *Store) GetWebhookDeliveries(ctx context.Context, webhookID, status string, limit int) ([]models.WebhookDelivery, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range s.db.state.deliveries {
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].DateCreated.After(deliveries[j].DateCreated) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (s *Store) GetWebhookDelivery(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	delivery, ok := s.db.state.deliveries[deliveryID]
+	if !ok || delivery.WebhookID != webhookID {
+		return &models.WebhookDelivery{}, notFound()
+	}
+	return &delivery, nil
+}
+
+func (s *Store) GetWebhooksForEvent(ctx context.Context, eventType string, departmentID int) ([]models.WebhookSubscription, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	webhooks := []models.WebhookSubscription{}
+	for _, webhook := range s.db.state.webhooks {
+		if webhook.Active && slices.Contains(webhook.EventTypes, eventType) && (webhook.DepartmentID == nil || *webhook.DepartmentID == departmentID) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (s *Store) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range s.db.state.deliveries {
+		due := delivery.Status == models.WebhookDeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now)
+		if due && s.db.state.webhooks[delivery.WebhookID].Active {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	leaseUntil := now.Add(lease)
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = &leaseUntil
+		remember(s, s.db.state.deliveries, deliveries[i].DeliveryID)
+		s.db.state.deliveries[deliveries[i].DeliveryID] = deliveries[i]
+	}
+	return deliveries, nil
+}
+
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	existing, ok := s.db.state.deliveries[delivery.DeliveryID]
+	if !ok {
+		return nil
+	}
+	existing.Status = delivery.Status
+	existing.Attempts = delivery.Attempts
+	existing.NextAttemptAt = delivery.NextAttemptAt
+	existing.LastStatusCode = delivery.LastStatusCode
+	existing.LastError = delivery.LastError
+	existing.DateDelivered = delivery.DateDelivered
+	remember(s, s.db.state.deliveries, delivery.DeliveryID)
+	s.db.state.deliveries[delivery.DeliveryID] = existing
+	return nil
+}
+
+func (s *Store) RecordWebhookSuccess(ctx context.Context, webhookID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	webhook, ok := s.db.state.webhooks[webhookID]
+	if !ok || webhook.FailureCount == 0 {
+		return nil
+	}
+	webhook.FailureCount = 0
+	remember(s, s.db.state.webhooks, webhookID)
+	s.db.state.webhooks[webhookID] = webhook
+	return nil
+}
+
+func (s *Store) RecordWebhookFailure(ctx context.Context, webhookID string, disableAfter int, reason string) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	webhook, ok := s.db.state.webhooks[webhookID]
+	if !ok {
+		return false, notFound()
+	}
+	webhook.FailureCount++
+	if webhook.FailureCount >= disableAfter && webhook.Active {
+		webhook.Active = false
+		webhook.DisabledReason = &reason
+		webhook.DateUpdated = time.Now().UTC()
+	}
+	remember(s, s.db.state.webhooks, webhookID)
+	s.db.state.webhooks[webhookID] = webhook
+	return !webhook.Active, nil
+}
+
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, record models.IdempotencyKey) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	id := idempotencyKeyID{record.UserID, record.Key, record.Fingerprint}
+	if existing, ok := s.db.state.idempotency[id]; ok && existing.ExpiresAt.After(record.DateCreated) {
+		return false, nil
+	}
+	record.ResponseStatus, record.ResponseHeaders, record.ResponseBody = 0, nil, nil
+	remember(s, s.db.state.idempotency, id)
+	s.db.state.idempotency[id] = record
+	return true, nil
+}
+
+func (s *Store) GetIdempotencyKey(ctx context.Context, userID, key, fingerprint string) (*models.IdempotencyKey, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	record, ok := s.db.state.idempotency[idempotencyKeyID{userID, key, fingerprint}]
+	if !ok {
+		return nil, notFound()
+	}
+	return &record, nil
+}
+
+func (s *Store) RenewIdempotencyKey(ctx context.Context, userID, key, fingerprint string, expiresAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	id := idempotencyKeyID{userID, key, fingerprint}
+	record, ok := s.db.state.idempotency[id]
+	if !ok || record.Status != models.IdempotencyStatusInProgress {
+		return nil
+	}
+	record.ExpiresAt = expiresAt
+	remember(s, s.db.state.idempotency, id)
+	s.db.state.idempotency[id] = record
+	return nil
+}
+
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	id := idempotencyKeyID{record.UserID, record.Key, record.Fingerprint}
+	existing, ok := s.db.state.idempotency[id]
+	if !ok {
+		return notFound()
+	}
+	existing.Status = models.IdempotencyStatusCompleted
+	existing.ResponseStatus = record.ResponseStatus
+	existing.ResponseHeaders = record.ResponseHeaders
+	existing.ResponseBody = record.ResponseBody
+	existing.ExpiresAt = record.ExpiresAt
+	remember(s, s.db.state.idempotency, id)
+	s.db.state.idempotency[id] = existing
+	return nil
+}
+
+func (s *Store) DeleteIdempotencyKey(ctx context.Context, userID, key, fingerprint string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	id := idempotencyKeyID{userID, key, fingerprint}
+	remember(s, s.db.state.idempotency, id)
+	delete(s.db.state.idempotency, id)
+	return nil
+}
+
+func (s *Store) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var count int64
+	for id, record := range s.db.state.idempotency {
+		if !record.ExpiresAt.After(now) {
+			remember(s, s.db.state.idempotency, id)
+			delete(s.db.state.idempotency, id)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+)
+
+var errAbort = errors.New("abort")
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store := NewStore()
+	store.AddDepartment(models.Department{DepartmentID: 1, Name: "Computer Science"})
+	if _, err := store.CreateInstructor(context.Background(), models.Instructor{InstructorID: "instructor-1", Name: "Ada"}); err != nil {
+		t.Fatalf("CreateInstructor: %v", err)
+	}
+	return store
+}
+
+func TestWithTxRollsBackItsOwnWrites(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	err := store.WithTx(ctx, func(tx repositories.Store) error {
+		if _, err := tx.CreateCourse(ctx, models.Course{CourseID: "course-1", InstructorID: "instructor-1", DepartmentID: 1}); err != nil {
+			return err
+		}
+		if err := tx.UpdateInstructor(ctx, models.Instructor{InstructorID: "instructor-1", Name: "Grace"}); err != nil {
+			return err
+		}
+		if _, err := tx.CreateInstructor(ctx, models.Instructor{InstructorID: "instructor-2"}); err != nil {
+			return err
+		}
+		if err := tx.DeleteInstructor(ctx, "instructor-2"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx returned %v, want the error of fn", err)
+	}
+
+	if _, err := store.GetCourseByID(ctx, "course-1"); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("course created in the rolled back unit of work: %v", err)
+	}
+	instructor, err := store.GetInstructorByID(ctx, "instructor-1")
+	if err != nil || instructor.Name != "Ada" {
+		t.Errorf("instructor after rollback = %+v, %v; want the name Ada", instructor, err)
+	}
+	if _, err := store.GetInstructorByID(ctx, "instructor-2"); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("instructor created and deleted in the rolled back unit of work: %v", err)
+	}
+}
+
+func TestWithTxKeepsWritesMadeOutsideIt(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	err := store.WithTx(ctx, func(tx repositories.Store) error {
+		if _, err := tx.CreateCourse(ctx, models.Course{CourseID: "course-1", InstructorID: "instructor-1", DepartmentID: 1}); err != nil {
+			return err
+		}
+		// a concurrent request writing through the store itself
+		if _, err := store.CreateInstructor(ctx, models.Instructor{InstructorID: "instructor-2"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx returned %v, want the error of fn", err)
+	}
+
+	if _, err := store.GetInstructorByID(ctx, "instructor-2"); err != nil {
+		t.Errorf("write made outside the unit of work was rolled back: %v", err)
+	}
+	if _, err := store.GetCourseByID(ctx, "course-1"); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("course created in the rolled back unit of work: %v", err)
+	}
+}
+
+func TestWithTxCommitsAndJoinsNestedUnits(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	err := store.WithTx(ctx, func(tx repositories.Store) error {
+		if _, err := tx.CreateCourse(ctx, models.Course{CourseID: "course-1", InstructorID: "instructor-1", DepartmentID: 1}); err != nil {
+			return err
+		}
+		return tx.WithTx(ctx, func(nested repositories.Store) error {
+			_, err := nested.CreateInstructor(ctx, models.Instructor{InstructorID: "instructor-2"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := store.GetCourseByID(ctx, "course-1"); err != nil {
+		t.Errorf("committed course: %v", err)
+	}
+	if _, err := store.GetInstructorByID(ctx, "instructor-2"); err != nil {
+		t.Errorf("instructor of the nested unit of work: %v", err)
+	}
+
+	// a failing nested unit of work rolls back the outer one it joined
+	err = store.WithTx(ctx, func(tx repositories.Store) error {
+		if err := tx.DeleteCourse(ctx, "course-1"); err != nil {
+			return err
+		}
+		return tx.WithTx(ctx, func(repositories.Store) error { return errAbort })
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx returned %v, want the error of the nested unit of work", err)
+	}
+	if _, err := store.GetCourseByID(ctx, "course-1"); err != nil {
+		t.Errorf("course deleted in the rolled back unit of work: %v", err)
+	}
+}
+
+func TestStoreReportsDomainErrors(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	_, err := store.CreateCourse(ctx, models.Course{CourseID: "course-1", InstructorID: "missing", DepartmentID: 1})
+	if !errors.Is(err, repositories.ErrForeignKeyViolation) {
+		t.Errorf("course with a missing instructor: %v, want ErrForeignKeyViolation", err)
+	}
+	if _, err := store.CreateInstructor(ctx, models.Instructor{InstructorID: "instructor-1"}); !errors.Is(err, repositories.ErrConflict) {
+		t.Errorf("duplicate instructor: %v, want ErrConflict", err)
+	}
+	if _, err := store.CreateCourse(ctx, models.Course{CourseID: "course-1", InstructorID: "instructor-1", DepartmentID: 1}); err != nil {
+		t.Fatalf("CreateCourse: %v", err)
+	}
+	if err := store.DeleteInstructor(ctx, "instructor-1"); !errors.Is(err, repositories.ErrForeignKeyViolation) {
+		t.Errorf("deleting an instructor with courses: %v, want ErrForeignKeyViolation", err)
+	}
+}
+
+func TestDeleteExpiredUploadSessionsSparesAssemblingSessions(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	now := time.Now().UTC()
+	for _, session := range []models.UploadSession{
+		{UploadID: "expired", UploadLength: 10, Status: models.UploadStatusPending, ExpiresAt: now.Add(-time.Hour)},
+		{UploadID: "active", UploadLength: 10, Status: models.UploadStatusPending, ExpiresAt: now.Add(time.Hour)},
+		{UploadID: "assembling", UploadLength: 10, Status: models.UploadStatusPending, ExpiresAt: now.Add(-time.Hour)},
+		{UploadID: "stale", UploadLength: 10, Status: models.UploadStatusPending, ExpiresAt: now.Add(-3 * time.Hour)},
+	} {
+		store.db.state.uploads[session.UploadID] = session
+	}
+	// the last chunks arrive, appending them moves the sessions to assembling
+	for _, uploadID := range []string{"assembling", "stale"} {
+		expiresAt := store.db.state.uploads[uploadID].ExpiresAt
+		if err := store.AppendUploadChunk(ctx, models.UploadChunk{UploadID: uploadID, ChunkSize: 10, BucketPath: "gs://bucket/" + uploadID}, expiresAt); err != nil {
+			t.Fatalf("AppendUploadChunk(%s): %v", uploadID, err)
+		}
+	}
+	store.db.state.uploadChunks["expired"] = []models.UploadChunk{{UploadID: "expired", BucketPath: "gs://bucket/expired"}}
+
+	count, chunks, err := store.DeleteExpiredUploadSessions(ctx, now, now.Add(-2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || len(chunks) != 2 {
+		t.Errorf("deleted %d sessions with %d chunks, want the expired and the stale session with one chunk each", count, len(chunks))
+	}
+	for uploadID, want := range map[string]bool{"expired": false, "active": true, "assembling": true, "stale": false} {
+		if _, ok := store.db.state.uploads[uploadID]; ok != want {
+			t.Errorf("session %s kept = %v, want %v", uploadID, ok, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPolicy holds the rules for routing reads to replicas
+type ReplicaPolicy struct {
+	// HealthInterval is how often every replica is pinged
+	HealthInterval time.Duration
+	// StickyWindow is how long the reads of a session stay on the primary after it wrote,
+	// so they see their own writes despite replication lag. Writes are only known to the
+	// process that made them, other instances may still read from a lagging replica.
+	StickyWindow time.Duration
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+type replicaRouter struct {
+	lock     sync.RWMutex
+	replicas []*replica
+	next     atomic.Uint64
+	policy   ReplicaPolicy
+
+	// lastWrites is per process, it does not see writes made through other instances
+	writesLock sync.Mutex
+	lastWrites map[string]time.Time
+}
+
+var replicas = &replicaRouter{
+	policy:     ReplicaPolicy{HealthInterval: 5 * time.Second, StickyWindow: 5 * time.Second},
+	lastWrites: map[string]time.Time{},
+}
+
+type sessionContextKey struct{}
+
+// WithSession marks the statements run with ctx as belonging to a session, usually the
+// authenticated user, so its reads follow its own writes to the primary
+func WithSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, sessionID)
+}
+
+// ReadDB returns where a read-only query may run: the transaction when db is one, the
+// primary while the session of ctx recently wrote or no replica is healthy, and otherwise
+// the next healthy replica in turn
+func ReadDB(ctx context.Context, db DBTX) DBTX {
+	if _, ok := db.(*sql.DB); !ok {
+		return db
+	}
+	if replicas.sticky(ctx) {
+		return db
+	}
+	if replica := replicas.pick(); replica != nil {
+		return replica
+	}
+	return db
+}
+
+// Start routing reads to the given replica pools once a ping reaches them, and keep
+// pinging them. The returned function stops the health checks.
+func StartReadReplicas(pools map[string]*sql.DB, policy ReplicaPolicy) func() {
+	replicas.lock.Lock()
+	if policy.HealthInterval > 0 {
+		replicas.policy.HealthInterval = policy.HealthInterval
+	}
+	if policy.StickyWindow > 0 {
+		replicas.policy.StickyWindow = policy.StickyWindow
+	}
+	replicas.replicas = nil
+	for name, pool := range pools {
+		r := &replica{name: name, db: pool}
+		replicas.replicas = append(replicas.replicas, r)
+	}
+	interval := replicas.policy.HealthInterval
+	replicas.lock.Unlock()
+	if len(pools) == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		replicas.checkHealth(interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				replicas.checkHealth(interval)
+				replicas.pruneWrites()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func (rr *replicaRouter) pick() *sql.DB {
+	rr.lock.RLock()
+	defer rr.lock.RUnlock()
+	count := len(rr.replicas)
+	for i := 0; i < count; i++ {
+		r := rr.replicas[int(rr.next.Add(1)%uint64(count))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+func (rr *replicaRouter) checkHealth(timeout time.Duration) {
+	rr.lock.RLock()
+	defer rr.lock.RUnlock()
+	for _, r := range rr.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.db.PingContext(ctx)
+		cancel()
+		if healthy := err == nil; r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				slog.Info("Read replica is healthy", "replica", r.name)
+			} else {
+				slog.Warn("Read replica is down, reading from the primary", "replica", r.name, "error", err)
+			}
+		}
+	}
+}
+
+// recordWrite starts the sticky window of the session of ctx
+func (rr *replicaRouter) recordWrite(ctx context.Context) {
+	sessionID, ok := ctx.Value(sessionContextKey{}).(string)
+	if !ok || sessionID == "" {
+		return
+	}
+	rr.lock.RLock()
+	routing := len(rr.replicas) > 0
+	rr.lock.RUnlock()
+	if !routing {
+		return
+	}
+	rr.writesLock.Lock()
+	rr.lastWrites[sessionID] = time.Now()
+	rr.writesLock.Unlock()
+}
+
+func (rr *replicaRouter) sticky(ctx context.Context) bool {
+	sessionID, ok := ctx.Value(sessionContextKey{}).(string)
+	if !ok || sessionID == "" {
+		return false
+	}
+	rr.lock.RLock()
+	window := rr.policy.StickyWindow
+	rr.lock.RUnlock()
+	rr.writesLock.Lock()
+	defer rr.writesLock.Unlock()
+	wrote, ok := rr.lastWrites[sessionID]
+	return ok && time.Since(wrote) < window
+}
+
+func (rr *replicaRouter) pruneWrites() {
+	rr.lock.RLock()
+	window := rr.policy.StickyWindow
+	rr.lock.RUnlock()
+	rr.writesLock.Lock()
+	defer rr.writesLock.Unlock()
+	for sessionID, wrote := range rr.lastWrites {
+		if time.Since(wrote) >= window {
+			delete(rr.lastWrites, sessionID)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func scanReportJob(row interface{ Scan(...any) error }, job *models.ReportJob) error {
+	return translateError(row.Scan(&job.JobID, &job.DepartmentID, &job.SemesterTerm, &job.UserID, &job.Status, &job.Total, &job.Completed, &job.Failed, &job.Error, &job.DateCreated, &job.DateFinished))
+}
+
+// CreateReportJob creates a new pending report job
+func CreateReportJob(ctx context.Context, db DBTX, job models.ReportJob) (models.ReportJob, error) {
+	_, err := execContext(ctx, db,
+		"INSERT INTO api.report_jobs (job_id, department_id, semester_term, user_id, status, total, completed, failed, date_created) VALUES ($1, $2, $3, $4, $5, 0, 0, 0, $6)",
+		job.JobID, job.DepartmentID, job.SemesterTerm, job.UserID, job.Status, job.DateCreated,
+	)
+	if err != nil {
+		return models.ReportJob{}, translateError(err)
+	}
+	job.Files = []models.ReportFile{}
+	return job, nil
+}
+
+// GetReportJob retrieves a report job along with its generated files
+func GetReportJob(ctx context.Context, db DBTX, jobID string) (*models.ReportJob, error) {
+	job := &models.ReportJob{}
+	err := scanReportJob(queryRowContext(ctx, db,
+		"SELECT job_id, department_id, semester_term, user_id, status, total, completed, failed, error, date_created, date_finished FROM api.report_jobs WHERE job_id = $1",
+		jobID,
+	), job)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	rows, err := queryContext(ctx, db,
+		"SELECT job_id, instructor_id, bucket_path, error, date_created FROM api.report_files WHERE job_id = $1 ORDER BY instructor_id",
+		jobID,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	job.Files = []models.ReportFile{}
+	for rows.Next() {
+		var file models.ReportFile
+		if err := rows.Scan(&file.JobID, &file.InstructorID, &file.BucketPath, &file.Error, &file.DateCreated); err != nil {
+			return nil, translateError(err)
+		}
+		job.Files = append(job.Files, file)
+	}
+	return job, translateError(rows.Err())
+}
+
+// ClaimReportJob marks the oldest pending job, or a running job whose lease ran out, as
+// running until now+lease under a new claim token, and returns it with the token. It
+// returns ErrNotFound when no job is waiting.
+func ClaimReportJob(ctx context.Context, db DBTX, now time.Time, lease time.Duration) (*models.ReportJob, string, error) {
+	job := &models.ReportJob{}
+	token := uuid.New().String()
+	err := scanReportJob(queryRowContext(ctx, db,
+		`UPDATE api.report_jobs SET status = $1, lease_until = $2, claim_token = $5
+		WHERE job_id = (
+			SELECT job_id FROM api.report_jobs
+			WHERE status = $3 OR (status = $1 AND (lease_until IS NULL OR lease_until <= $4))
+			ORDER BY date_created
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job_id, department_id, semester_term, user_id, status, total, completed, failed, error, date_created, date_finished`,
+		models.ReportJobRunning, now.Add(lease), models.ReportJobPending, now, token,
+	), job)
+	if err != nil {
+		return nil, "", err
+	}
+	return job, token, nil
+}
+
+// The statements below only change a job while it is running under the given claim
+// token, and return ErrNotFound once another worker took the job over.
+
+// RenewReportJobLease extends the lease of a running job
+func RenewReportJobLease(ctx context.Context, db DBTX, jobID, token string, leaseUntil time.Time) error {
+	result, err := execContext(ctx, db,
+		"UPDATE api.report_jobs SET lease_until = $1 WHERE job_id = $2 AND status = $3 AND claim_token = $4",
+		leaseUntil, jobID, models.ReportJobRunning, token,
+	)
+	return translateError(requireRow(result, err))
+}
+
+// ReleaseReportJob puts a running job back to pending so any worker can claim it at once
+func ReleaseReportJob(ctx context.Context, db DBTX, jobID, token string) error {
+	result, err := execContext(ctx, db,
+		"UPDATE api.report_jobs SET status = $1, lease_until = NULL, claim_token = NULL WHERE job_id = $2 AND status = $3 AND claim_token = $4",
+		models.ReportJobPending, jobID, models.ReportJobRunning, token,
+	)
+	return translateError(requireRow(result, err))
+}
+
+// StartReportJob sets the total of a claimed job and clears the files of any earlier attempt
+func StartReportJob(ctx context.Context, db DBTX, jobID, token string, total int) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		result, err := execContext(ctx, tx,
+			"UPDATE api.report_jobs SET total = $1, completed = 0, failed = 0, error = NULL WHERE job_id = $2 AND status = $3 AND claim_token = $4",
+			total, jobID, models.ReportJobRunning, token,
+		)
+		if err := requireRow(result, err); err != nil {
+			return err
+		}
+		_, err = execContext(ctx, tx, "DELETE FROM api.report_files WHERE job_id = $1", jobID)
+		return err
+	}))
+}
+
+// AddReportFile records the outcome for one instructor and updates the job counters
+func AddReportFile(ctx context.Context, db DBTX, token string, file models.ReportFile) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		counter := "completed"
+		if file.Error != nil {
+			counter = "failed"
+		}
+		result, err := execContext(ctx, tx,
+			"UPDATE api.report_jobs SET "+counter+" = "+counter+" + 1 WHERE job_id = $1 AND status = $2 AND claim_token = $3",
+			file.JobID, models.ReportJobRunning, token,
+		)
+		if err := requireRow(result, err); err != nil {
+			return err
+		}
+		_, err = execContext(ctx, tx,
+			"INSERT INTO api.report_files (job_id, instructor_id, bucket_path, error, date_created) VALUES ($1, $2, $3, $4, $5)",
+			file.JobID, file.InstructorID, file.BucketPath, file.Error, file.DateCreated,
+		)
+		return err
+	}))
+}
+
+// FinishReportJob sets the final status of a report job
+func FinishReportJob(ctx context.Context, db DBTX, jobID, token, status string, errMessage *string) error {
+	result, err := execContext(ctx, db,
+		"UPDATE api.report_jobs SET status = $1, error = $2, date_finished = $3, lease_until = NULL, claim_token = NULL WHERE job_id = $4 AND status = $5 AND claim_token = $6",
+		status, errMessage, time.Now().UTC(), jobID, models.ReportJobRunning, token,
+	)
+	return translateError(requireRow(result, err))
+}
+
+// requireRow turns a statement that changed no row into sql.ErrNoRows
+func requireRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetReportFile retrieves the generated report of an instructor in a job
+func GetReportFile(ctx context.Context, db DBTX, jobID, instructorID string) (*models.ReportFile, error) {
+	file := &models.ReportFile{}
+	err := queryRowContext(ctx, db,
+		"SELECT job_id, instructor_id, bucket_path, error, date_created FROM api.report_files WHERE job_id = $1 AND instructor_id = $2",
+		jobID, instructorID,
+	).Scan(&file.JobID, &file.InstructorID, &file.BucketPath, &file.Error, &file.DateCreated)
+	return file, translateError(err)
+}
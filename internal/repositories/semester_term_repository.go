@@ -2,27 +2,27 @@
 package repositories
 
 import (
-	"database/sql"
+	"context"
 
 	"api-server/internal/models"
 )
 
-func GetSemesterTerm(db *sql.DB, semesterTerm string) (*models.SemesterTermModel, error) {
+func GetSemesterTerm(ctx context.Context, db DBTX, semesterTerm string) (*models.SemesterTermModel, error) {
 	semester := &models.SemesterTermModel{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT semester_term, name FROM api.semester_terms WHERE semester_term = $1",
 		semesterTerm,
 	).Scan(&semester.SemesterTerm, &semester.Name)
-	return semester, err
+	return semester, translateError(err)
 }
 
 // GetAllSemesterTerms retrieves all semester terms
-func GetAllSemesterTerms(db *sql.DB) ([]models.SemesterTermModel, error) {
-	rows, err := db.Query(
+func GetAllSemesterTerms(ctx context.Context, db DBTX) ([]models.SemesterTermModel, error) {
+	rows, err := queryContext(ctx, db,
 		"SELECT semester_term, name FROM api.semester_terms",
 	)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -30,7 +30,7 @@ func GetAllSemesterTerms(db *sql.DB) ([]models.SemesterTermModel, error) {
 	for rows.Next() {
 		var semesterTerm models.SemesterTermModel
 		if err := rows.Scan(&semesterTerm.SemesterTerm, &semesterTerm.Name); err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		semesterTerms = append(semesterTerms, semesterTerm)
 	}
@@ -0,0 +1,506 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-server/internal/models"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so the repository functions
+// can run on their own or as part of a larger transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// inTx runs fn in a new transaction, or in the caller's transaction when db already is one
+func inTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UserStore stores user accounts
+type UserStore interface {
+	CreateUser(ctx context.Context, userReq models.UserRequest) (*models.User, error)
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+	// GetUserByUsername returns nil without an error when no user has the username
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	// GetUserWithPasswordByUsername returns the user with its password hash, for authentication
+	GetUserWithPasswordByUsername(ctx context.Context, username string) (*UserWithPassword, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+}
+
+// CourseStore stores courses
+type CourseStore interface {
+	CreateCourse(ctx context.Context, course models.Course) (models.Course, error)
+	GetCourseByID(ctx context.Context, courseID string) (*models.Course, error)
+	GetAllCourses(ctx context.Context) ([]models.Course, error)
+	UpdateCourse(ctx context.Context, course *models.Course) error
+	DeleteCourse(ctx context.Context, courseID string) error
+}
+
+// TraceStore stores traces and their file versions
+type TraceStore interface {
+	CreateTrace(ctx context.Context, trace models.Trace) (models.Trace, error)
+	GetTraceByID(ctx context.Context, traceID string) (*models.Trace, error)
+	GetAllTraces(ctx context.Context) ([]models.Trace, error)
+	GetTraceByCourseID(ctx context.Context, courseID string) ([]models.Trace, error)
+	GetTracesByInstructorAndTerm(ctx context.Context, instructorID, semesterTerm string) ([]models.Trace, error)
+	UpdateTrace(ctx context.Context, trace *models.Trace) error
+	DeleteTrace(ctx context.Context, traceID string) error
+	AddTraceVersion(ctx context.Context, version models.TraceVersion) (models.TraceVersion, error)
+	GetTraceVersions(ctx context.Context, traceID string) ([]models.TraceVersion, error)
+	GetTraceVersion(ctx context.Context, traceID string, versionNumber int) (*models.TraceVersion, error)
+	DeleteTraceVersions(ctx context.Context, traceID string) error
+	GetInstructorIDsByDepartmentAndTerm(ctx context.Context, departmentID int, semesterTerm string) ([]string, error)
+}
+
+// TraceEventStore sends trace stream events to every api-server
+type TraceEventStore interface {
+	// NotifyTraceEvent numbers the event; it is delivered when the unit of work commits
+	NotifyTraceEvent(ctx context.Context, event models.TraceStreamEvent) error
+}
+
+// InstructorStore stores instructors
+type InstructorStore interface {
+	CreateInstructor(ctx context.Context, instructor models.Instructor) (models.Instructor, error)
+	GetInstructorByID(ctx context.Context, instructorID string) (models.Instructor, error)
+	GetAllInstructors(ctx context.Context) ([]models.Instructor, error)
+	UpdateInstructor(ctx context.Context, instructor models.Instructor) error
+	DeleteInstructor(ctx context.Context, instructorID string) error
+}
+
+// ReferenceStore reads the departments and semester terms other records refer to
+type ReferenceStore interface {
+	GetDepartmentByID(ctx context.Context, departmentID int) (*models.Department, error)
+	GetAllDepartments(ctx context.Context) ([]models.Department, error)
+	GetSemesterTerm(ctx context.Context, semesterTerm string) (*models.SemesterTermModel, error)
+	GetAllSemesterTerms(ctx context.Context) ([]models.SemesterTermModel, error)
+}
+
+// SurveyResultStore stores the survey results delivered for traces
+type SurveyResultStore interface {
+	SaveSurveyResult(ctx context.Context, traceID string, req models.SurveyResultRequest) error
+	GetSurveyResult(ctx context.Context, traceID string) (*models.SurveyResult, error)
+	GetSurveyComments(ctx context.Context, traceID string) ([]models.SurveyComment, error)
+}
+
+// AnalyticsStore reads the question statistics aggregated from survey results
+type AnalyticsStore interface {
+	GetQuestionStatsByInstructor(ctx context.Context, instructorID string) ([]models.QuestionTermStats, error)
+	GetQuestionStatsByCourse(ctx context.Context, courseID string) ([]models.QuestionTermStats, error)
+	GetDepartmentQuestionStats(ctx context.Context, departmentIDs []int, semesterTerms []string) ([]models.QuestionTermStats, error)
+}
+
+// UploadStore stores resumable upload sessions and their chunks
+type UploadStore interface {
+	CreateUploadSession(ctx context.Context, session models.UploadSession) (models.UploadSession, error)
+	GetUploadSession(ctx context.Context, uploadID string) (*models.UploadSession, error)
+	// AppendUploadChunk returns ErrNotFound when the session offset moved since the chunk was read
+	AppendUploadChunk(ctx context.Context, chunk models.UploadChunk, expiresAt time.Time) error
+	GetUploadChunks(ctx context.Context, uploadID string) ([]models.UploadChunk, error)
+	FinishUploadSession(ctx context.Context, uploadID, status string, traceID, errMessage *string) error
+	DeleteUploadSession(ctx context.Context, uploadID string) error
+	// DeleteExpiredUploadSessions returns how many sessions it deleted and the chunks they held
+	DeleteExpiredUploadSessions(ctx context.Context, now, staleBefore time.Time) (int, []models.UploadChunk, error)
+}
+
+// ReportStore stores department report jobs and the reports they generated
+type ReportStore interface {
+	CreateReportJob(ctx context.Context, job models.ReportJob) (models.ReportJob, error)
+	GetReportJob(ctx context.Context, jobID string) (*models.ReportJob, error)
+	GetReportFile(ctx context.Context, jobID, instructorID string) (*models.ReportFile, error)
+	// ClaimReportJob returns the claimed job with its claim token, or ErrNotFound when no job is waiting
+	ClaimReportJob(ctx context.Context, now time.Time, lease time.Duration) (*models.ReportJob, string, error)
+	// The methods below return ErrNotFound once another worker took the job over
+	RenewReportJobLease(ctx context.Context, jobID, token string, leaseUntil time.Time) error
+	ReleaseReportJob(ctx context.Context, jobID, token string) error
+	StartReportJob(ctx context.Context, jobID, token string, total int) error
+	AddReportFile(ctx context.Context, token string, file models.ReportFile) error
+	FinishReportJob(ctx context.Context, jobID, token, status string, errMessage *string) error
+}
+
+// WebhookStore stores webhook subscriptions and their deliveries
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook models.WebhookSubscription) (models.WebhookSubscription, error)
+	GetWebhook(ctx context.Context, webhookID string) (*models.WebhookSubscription, error)
+	GetWebhooksByUser(ctx context.Context, userID string) ([]models.WebhookSubscription, error)
+	UpdateWebhook(ctx context.Context, webhook *models.WebhookSubscription) error
+	DeleteWebhook(ctx context.Context, webhookID string) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	GetWebhookDeliveries(ctx context.Context, webhookID, status string, limit int) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error)
+	GetWebhooksForEvent(ctx context.Context, eventType string, departmentID int) ([]models.WebhookSubscription, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	RecordWebhookSuccess(ctx context.Context, webhookID string) error
+	// RecordWebhookFailure returns true when the failure disabled the subscription
+	RecordWebhookFailure(ctx context.Context, webhookID string, disableAfter int, reason string) (bool, error)
+}
+
+// IdempotencyStore stores the responses of requests sent with an Idempotency-Key
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey returns false when an unexpired record already exists
+	ReserveIdempotencyKey(ctx context.Context, record models.IdempotencyKey) (bool, error)
+	GetIdempotencyKey(ctx context.Context, userID, key, fingerprint string) (*models.IdempotencyKey, error)
+	RenewIdempotencyKey(ctx context.Context, userID, key, fingerprint string, expiresAt time.Time) error
+	CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, userID, key, fingerprint string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Store combines the stores with a unit of work spanning them
+type Store interface {
+	UserStore
+	CourseStore
+	TraceStore
+	TraceEventStore
+	InstructorStore
+	ReferenceStore
+	SurveyResultStore
+	AnalyticsStore
+	UploadStore
+	ReportStore
+	WebhookStore
+	IdempotencyStore
+
+	// WithTx runs fn with a Store whose reads and writes happen in one transaction,
+	// committed when fn returns nil and rolled back otherwise
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// PostgresStore implements Store with the repository functions of this package. List
+// reads go to a read replica when one is routed to, see ReadDB.
+type PostgresStore struct {
+	db DBTX
+}
+
+// NewPostgresStore returns a Store backed by the given database
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	conn, ok := s.db.(*sql.DB)
+	if !ok {
+		// already in a transaction, nested units of work join it
+		return fn(s)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&PostgresStore{db: tx}); err != nil {
+		return err
+	}
+	return translateError(tx.Commit())
+}
+
+func (s *PostgresStore) CreateUser(ctx context.Context, userReq models.UserRequest) (*models.User, error) {
+	return CreateUser(ctx, s.db, userReq)
+}
+
+func (s *PostgresStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	return GetUserByID(ctx, s.db, userID)
+}
+
+func (s *PostgresStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return GetUserByUsername(ctx, s.db, username)
+}
+
+func (s *PostgresStore) GetUserWithPasswordByUsername(ctx context.Context, username string) (*UserWithPassword, error) {
+	return GetUserWithPasswordByUsername(ctx, s.db, username)
+}
+
+func (s *PostgresStore) UpdateUser(ctx context.Context, user *models.User) error {
+	return UpdateUser(ctx, s.db, user)
+}
+
+func (s *PostgresStore) CreateCourse(ctx context.Context, course models.Course) (models.Course, error) {
+	return CreateCourse(ctx, s.db, course)
+}
+
+func (s *PostgresStore) GetCourseByID(ctx context.Context, courseID string) (*models.Course, error) {
+	return GetCourseByID(ctx, s.db, courseID)
+}
+
+func (s *PostgresStore) GetAllCourses(ctx context.Context) ([]models.Course, error) {
+	return GetAllCourses(ctx, ReadDB(ctx, s.db))
+}
+
+func (s *PostgresStore) UpdateCourse(ctx context.Context, course *models.Course) error {
+	return UpdateCourse(ctx, s.db, course)
+}
+
+func (s *PostgresStore) DeleteCourse(ctx context.Context, courseID string) error {
+	return DeleteCourse(ctx, s.db, courseID)
+}
+
+func (s *PostgresStore) CreateTrace(ctx context.Context, trace models.Trace) (models.Trace, error) {
+	return CreateTrace(ctx, s.db, trace)
+}
+
+func (s *PostgresStore) GetTraceByID(ctx context.Context, traceID string) (*models.Trace, error) {
+	return GetTraceByID(ctx, s.db, traceID)
+}
+
+func (s *PostgresStore) GetAllTraces(ctx context.Context) ([]models.Trace, error) {
+	return GetAllTraces(ctx, ReadDB(ctx, s.db))
+}
+
+func (s *PostgresStore) GetTraceByCourseID(ctx context.Context, courseID string) ([]models.Trace, error) {
+	return GetTraceByCourseID(ctx, ReadDB(ctx, s.db), courseID)
+}
+
+func (s *PostgresStore) GetTracesByInstructorAndTerm(ctx context.Context, instructorID, semesterTerm string) ([]models.Trace, error) {
+	return GetTracesByInstructorAndTerm(ctx, ReadDB(ctx, s.db), instructorID, semesterTerm)
+}
+
+func (s *PostgresStore) UpdateTrace(ctx context.Context, trace *models.Trace) error {
+	return UpdateTrace(ctx, s.db, trace)
+}
+
+func (s *PostgresStore) DeleteTrace(ctx context.Context, traceID string) error {
+	return DeleteTrace(ctx, s.db, traceID)
+}
+
+func (s *PostgresStore) AddTraceVersion(ctx context.Context, version models.TraceVersion) (models.TraceVersion, error) {
+	return AddTraceVersion(ctx, s.db, version)
+}
+
+func (s *PostgresStore) GetTraceVersions(ctx context.Context, traceID string) ([]models.TraceVersion, error) {
+	return GetTraceVersions(ctx, ReadDB(ctx, s.db), traceID)
+}
+
+func (s *PostgresStore) GetTraceVersion(ctx context.Context, traceID string, versionNumber int) (*models.TraceVersion, error) {
+	return GetTraceVersion(ctx, s.db, traceID, versionNumber)
+}
+
+func (s *PostgresStore) DeleteTraceVersions(ctx context.Context, traceID string) error {
+	return DeleteTraceVersions(ctx, s.db, traceID)
+}
+
+func (s *PostgresStore) GetInstructorIDsByDepartmentAndTerm(ctx context.Context, departmentID int, semesterTerm string) ([]string, error) {
+	return GetInstructorIDsByDepartmentAndTerm(ctx, s.db, departmentID, semesterTerm)
+}
+
+func (s *PostgresStore) NotifyTraceEvent(ctx context.Context, event models.TraceStreamEvent) error {
+	return NotifyTraceEvent(ctx, s.db, event)
+}
+
+func (s *PostgresStore) CreateInstructor(ctx context.Context, instructor models.Instructor) (models.Instructor, error) {
+	return CreateInstructor(ctx, s.db, instructor)
+}
+
+func (s *PostgresStore) GetInstructorByID(ctx context.Context, instructorID string) (models.Instructor, error) {
+	return GetInstructorByID(ctx, s.db, instructorID)
+}
+
+func (s *PostgresStore) GetAllInstructors(ctx context.Context) ([]models.Instructor, error) {
+	return GetAllInstructors(ctx, ReadDB(ctx, s.db))
+}
+
+func (s *PostgresStore) UpdateInstructor(ctx context.Context, instructor models.Instructor) error {
+	return UpdateInstructor(ctx, s.db, instructor)
+}
+
+func (s *PostgresStore) DeleteInstructor(ctx context.Context, instructorID string) error {
+	return DeleteInstructor(ctx, s.db, instructorID)
+}
+
+func (s *PostgresStore) GetDepartmentByID(ctx context.Context, departmentID int) (*models.Department, error) {
+	return GetDepartmentByID(ctx, s.db, departmentID)
+}
+
+func (s *PostgresStore) GetAllDepartments(ctx context.Context) ([]models.Department, error) {
+	return GetAllDepartments(ctx, ReadDB(ctx, s.db))
+}
+
+func (s *PostgresStore) GetSemesterTerm(ctx context.Context, semesterTerm string) (*models.SemesterTermModel, error) {
+	return GetSemesterTerm(ctx, s.db, semesterTerm)
+}
+
+func (s *PostgresStore) GetAllSemesterTerms(ctx context.Context) ([]models.SemesterTermModel, error) {
+	return GetAllSemesterTerms(ctx, ReadDB(ctx, s.db))
+}
+
+func (s *PostgresStore) SaveSurveyResult(ctx context.Context, traceID string, req models.SurveyResultRequest) error {
+	return SaveSurveyResult(ctx, s.db, traceID, req)
+}
+
+func (s *PostgresStore) GetSurveyResult(ctx context.Context, traceID string) (*models.SurveyResult, error) {
+	return GetSurveyResult(ctx, s.db, traceID)
+}
+
+func (s *PostgresStore) GetSurveyComments(ctx context.Context, traceID string) ([]models.SurveyComment, error) {
+	return GetSurveyComments(ctx, s.db, traceID)
+}
+
+func (s *PostgresStore) GetQuestionStatsByInstructor(ctx context.Context, instructorID string) ([]models.QuestionTermStats, error) {
+	return GetQuestionStatsByInstructor(ctx, ReadDB(ctx, s.db), instructorID)
+}
+
+func (s *PostgresStore) GetQuestionStatsByCourse(ctx context.Context, courseID string) ([]models.QuestionTermStats, error) {
+	return GetQuestionStatsByCourse(ctx, ReadDB(ctx, s.db), courseID)
+}
+
+func (s *PostgresStore) GetDepartmentQuestionStats(ctx context.Context, departmentIDs []int, semesterTerms []string) ([]models.QuestionTermStats, error) {
+	return GetDepartmentQuestionStats(ctx, ReadDB(ctx, s.db), departmentIDs, semesterTerms)
+}
+
+func (s *PostgresStore) CreateUploadSession(ctx context.Context, session models.UploadSession) (models.UploadSession, error) {
+	return CreateUploadSession(ctx, s.db, session)
+}
+
+func (s *PostgresStore) GetUploadSession(ctx context.Context, uploadID string) (*models.UploadSession, error) {
+	return GetUploadSession(ctx, s.db, uploadID)
+}
+
+func (s *PostgresStore) AppendUploadChunk(ctx context.Context, chunk models.UploadChunk, expiresAt time.Time) error {
+	return AppendUploadChunk(ctx, s.db, chunk, expiresAt)
+}
+
+func (s *PostgresStore) GetUploadChunks(ctx context.Context, uploadID string) ([]models.UploadChunk, error) {
+	return GetUploadChunks(ctx, s.db, uploadID)
+}
+
+func (s *PostgresStore) FinishUploadSession(ctx context.Context, uploadID, status string, traceID, errMessage *string) error {
+	return FinishUploadSession(ctx, s.db, uploadID, status, traceID, errMessage)
+}
+
+func (s *PostgresStore) DeleteUploadSession(ctx context.Context, uploadID string) error {
+	return DeleteUploadSession(ctx, s.db, uploadID)
+}
+
+func (s *PostgresStore) DeleteExpiredUploadSessions(ctx context.Context, now, staleBefore time.Time) (int, []models.UploadChunk, error) {
+	return DeleteExpiredUploadSessions(ctx, s.db, now, staleBefore)
+}
+
+func (s *PostgresStore) CreateReportJob(ctx context.Context, job models.ReportJob) (models.ReportJob, error) {
+	return CreateReportJob(ctx, s.db, job)
+}
+
+func (s *PostgresStore) GetReportJob(ctx context.Context, jobID string) (*models.ReportJob, error) {
+	return GetReportJob(ctx, s.db, jobID)
+}
+
+func (s *PostgresStore) GetReportFile(ctx context.Context, jobID, instructorID string) (*models.ReportFile, error) {
+	return GetReportFile(ctx, s.db, jobID, instructorID)
+}
+
+func (s *PostgresStore) ClaimReportJob(ctx context.Context, now time.Time, lease time.Duration) (*models.ReportJob, string, error) {
+	return ClaimReportJob(ctx, s.db, now, lease)
+}
+
+func (s *PostgresStore) RenewReportJobLease(ctx context.Context, jobID, token string, leaseUntil time.Time) error {
+	return RenewReportJobLease(ctx, s.db, jobID, token, leaseUntil)
+}
+
+func (s *PostgresStore) ReleaseReportJob(ctx context.Context, jobID, token string) error {
+	return ReleaseReportJob(ctx, s.db, jobID, token)
+}
+
+func (s *PostgresStore) StartReportJob(ctx context.Context, jobID, token string, total int) error {
+	return StartReportJob(ctx, s.db, jobID, token, total)
+}
+
+func (s *PostgresStore) AddReportFile(ctx context.Context, token string, file models.ReportFile) error {
+	return AddReportFile(ctx, s.db, token, file)
+}
+
+func (s *PostgresStore) FinishReportJob(ctx context.Context, jobID, token, status string, errMessage *string) error {
+	return FinishReportJob(ctx, s.db, jobID, token, status, errMessage)
+}
+
+func (s *PostgresStore) CreateWebhook(ctx context.Context, webhook models.WebhookSubscription) (models.WebhookSubscription, error) {
+	return CreateWebhook(ctx, s.db, webhook)
+}
+
+func (s *PostgresStore) GetWebhook(ctx context.Context, webhookID string) (*models.WebhookSubscription, error) {
+	return GetWebhook(ctx, s.db, webhookID)
+}
+
+func (s *PostgresStore) GetWebhooksByUser(ctx context.Context, userID string) ([]models.WebhookSubscription, error) {
+	return GetWebhooksByUser(ctx, s.db, userID)
+}
+
+func (s *PostgresStore) UpdateWebhook(ctx context.Context, webhook *models.WebhookSubscription) error {
+	return UpdateWebhook(ctx, s.db, webhook)
+}
+
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, webhookID string) error {
+	return DeleteWebhook(ctx, s.db, webhookID)
+}
+
+func (s *PostgresStore) CreateWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	return CreateWebhookDeliveries(ctx, s.db, deliveries)
+}
+
+func (s *PostgresStore) GetWebhookDeliveries(ctx context.Context, webhookID, status string, limit int) ([]models.WebhookDelivery, error) {
+	return GetWebhookDeliveries(ctx, s.db, webhookID, status, limit)
+}
+
+func (s *PostgresStore) GetWebhookDelivery(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	return GetWebhookDelivery(ctx, s.db, webhookID, deliveryID)
+}
+
+func (s *PostgresStore) GetWebhooksForEvent(ctx context.Context, eventType string, departmentID int) ([]models.WebhookSubscription, error) {
+	return GetWebhooksForEvent(ctx, s.db, eventType, departmentID)
+}
+
+func (s *PostgresStore) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	return ClaimDueWebhookDeliveries(ctx, s.db, now, lease, limit)
+}
+
+func (s *PostgresStore) UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	return UpdateWebhookDelivery(ctx, s.db, delivery)
+}
+
+func (s *PostgresStore) RecordWebhookSuccess(ctx context.Context, webhookID string) error {
+	return RecordWebhookSuccess(ctx, s.db, webhookID)
+}
+
+func (s *PostgresStore) RecordWebhookFailure(ctx context.Context, webhookID string, disableAfter int, reason string) (bool, error) {
+	return RecordWebhookFailure(ctx, s.db, webhookID, disableAfter, reason)
+}
+
+func (s *PostgresStore) ReserveIdempotencyKey(ctx context.Context, record models.IdempotencyKey) (bool, error) {
+	return ReserveIdempotencyKey(ctx, s.db, record)
+}
+
+func (s *PostgresStore) GetIdempotencyKey(ctx context.Context, userID, key, fingerprint string) (*models.IdempotencyKey, error) {
+	return GetIdempotencyKey(ctx, s.db, userID, key, fingerprint)
+}
+
+func (s *PostgresStore) RenewIdempotencyKey(ctx context.Context, userID, key, fingerprint string, expiresAt time.Time) error {
+	return RenewIdempotencyKey(ctx, s.db, userID, key, fingerprint, expiresAt)
+}
+
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyKey) error {
+	return CompleteIdempotencyKey(ctx, s.db, record)
+}
+
+func (s *PostgresStore) DeleteIdempotencyKey(ctx context.Context, userID, key, fingerprint string) error {
+	return DeleteIdempotencyKey(ctx, s.db, userID, key, fingerprint)
+}
+
+func (s *PostgresStore) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	return DeleteExpiredIdempotencyKeys(ctx, s.db, now)
+}
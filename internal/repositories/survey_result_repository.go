@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SaveSurveyResult stores the results of a trace, replacing any earlier delivery for it
+func SaveSurveyResult(ctx context.Context, db DBTX, traceID string, req models.SurveyResultRequest) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		// questions, ratings and comments hang off survey_results and cascade with it
+		if _, err := execContext(ctx, tx, "DELETE FROM api.survey_results WHERE trace_id = $1", traceID); err != nil {
+			return err
+		}
+
+		_, err := execContext(ctx, tx,
+			"INSERT INTO api.survey_results (trace_id, response_count, enrolled_count, processor_version, date_processed) VALUES ($1, $2, $3, $4, $5)",
+			traceID, req.ResponseCount, req.EnrolledCount, req.ProcessorVersion, time.Now().UTC(),
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, question := range req.Questions {
+			questionID := uuid.New().String()
+			_, err := execContext(ctx, tx,
+				"INSERT INTO api.survey_questions (question_id, trace_id, position, category, text, response_count) VALUES ($1, $2, $3, $4, $5, $6)",
+				questionID, traceID, question.Position, question.Category, question.Text, question.ResponseCount,
+			)
+			if err != nil {
+				return err
+			}
+			for _, rating := range question.Ratings {
+				_, err := execContext(ctx, tx,
+					"INSERT INTO api.survey_rating_counts (question_id, rating, count) VALUES ($1, $2, $3)",
+					questionID, rating.Rating, rating.Count,
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, comment := range req.Comments {
+			_, err := execContext(ctx, tx,
+				"INSERT INTO api.survey_comments (comment_id, trace_id, question, text) VALUES ($1, $2, $3, $4)",
+				uuid.New().String(), traceID, comment.Question, comment.Text,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// GetSurveyResult retrieves the results of a trace with its questions and comments
+func GetSurveyResult(ctx context.Context, db DBTX, traceID string) (*models.SurveyResult, error) {
+	result := &models.SurveyResult{}
+	err := queryRowContext(ctx, db,
+		"SELECT trace_id, response_count, enrolled_count, processor_version, date_processed FROM api.survey_results WHERE trace_id = $1",
+		traceID,
+	).Scan(&result.TraceID, &result.ResponseCount, &result.EnrolledCount, &result.ProcessorVersion, &result.DateProcessed)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	questions, err := getSurveyQuestions(ctx, db, traceID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	result.Questions = questions
+
+	comments, err := GetSurveyComments(ctx, db, traceID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	result.Comments = comments
+
+	return result, nil
+}
+
+func getSurveyQuestions(ctx context.Context, db DBTX, traceID string) ([]models.SurveyQuestion, error) {
+	rows, err := queryContext(ctx, db,
+		`SELECT q.question_id, q.position, q.category, q.text, q.response_count, r.rating, r.count
+		FROM api.survey_questions q
+		LEFT JOIN api.survey_rating_counts r ON r.question_id = q.question_id
+		WHERE q.trace_id = $1
+		ORDER BY q.position, r.rating`,
+		traceID,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	questions := []models.SurveyQuestion{}
+	for rows.Next() {
+		var question models.SurveyQuestion
+		var rating, count sql.NullInt64
+		if err := rows.Scan(&question.QuestionID, &question.Position, &question.Category, &question.Text, &question.ResponseCount, &rating, &count); err != nil {
+			return nil, translateError(err)
+		}
+		if n := len(questions); n == 0 || questions[n-1].QuestionID != question.QuestionID {
+			question.Ratings = []models.RatingCount{}
+			questions = append(questions, question)
+		}
+		if rating.Valid {
+			last := &questions[len(questions)-1]
+			last.Ratings = append(last.Ratings, models.RatingCount{Rating: int(rating.Int64), Count: int(count.Int64)})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	for i := range questions {
+		questions[i].Mean = RatingMean(questions[i].Ratings)
+	}
+	return questions, nil
+}
+
+// GetSurveyComments retrieves the free-text comments of a trace
+func GetSurveyComments(ctx context.Context, db DBTX, traceID string) ([]models.SurveyComment, error) {
+	rows, err := queryContext(ctx, db,
+		"SELECT comment_id, question, text FROM api.survey_comments WHERE trace_id = $1",
+		traceID,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	comments := []models.SurveyComment{}
+	for rows.Next() {
+		var comment models.SurveyComment
+		if err := rows.Scan(&comment.CommentID, &comment.Question, &comment.Text); err != nil {
+			return nil, translateError(err)
+		}
+		comments = append(comments, comment)
+	}
+	return comments, translateError(rows.Err())
+}
+
+// RatingMean computes the mean rating of a distribution, 0 when there are no ratings
+func RatingMean(ratings []models.RatingCount) float64 {
+	total, weighted := 0, 0
+	for _, r := range ratings {
+		total += r.Count
+		weighted += r.Rating * r.Count
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(weighted) / float64(total)
+}
@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"api-server/internal/models"
+)
+
+// TraceEventChannel is the PostgreSQL NOTIFY channel carrying trace stream events
+const TraceEventChannel = "trace_events"
+
+// NotifyTraceEvent numbers a trace stream event from api.trace_event_id_seq and sends it to
+// every listening api-server. The notification is delivered when the statement commits.
+func NotifyTraceEvent(ctx context.Context, db DBTX, event models.TraceStreamEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = execContext(ctx, db,
+		"SELECT pg_notify($1, (jsonb_build_object('id', nextval('api.trace_event_id_seq')) || ($2::jsonb - 'id'))::text)",
+		TraceEventChannel, string(payload),
+	)
+	return translateError(err)
+}
@@ -1,40 +1,51 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 
 	"api-server/internal/models"
 )
 
-// CreateTrace creates a new trace in the database
-func CreateTrace(db *sql.DB, trace models.Trace) (models.Trace, error) {
-	_, err := db.Exec(
-		"INSERT INTO api.traces (trace_id, user_id, file_name, date_created, bucket_path, course_id, instructor_id, semester_term, section) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
-		trace.TraceID, trace.UserID, trace.FileName, trace.DateCreated, trace.BucketPath, trace.CourseID, trace.InstructorID, trace.SemesterTerm, trace.Section,
-	)
+// CreateTrace creates a new trace in the database along with its first version
+func CreateTrace(ctx context.Context, db DBTX, trace models.Trace) (models.Trace, error) {
+	err := inTx(ctx, db, func(tx DBTX) error {
+		_, err := execContext(ctx, tx,
+			"INSERT INTO api.traces (trace_id, user_id, file_name, date_created, bucket_path, course_id, instructor_id, semester_term, section) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+			trace.TraceID, trace.UserID, trace.FileName, trace.DateCreated, trace.BucketPath, trace.CourseID, trace.InstructorID, trace.SemesterTerm, trace.Section,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = execContext(ctx, tx,
+			"INSERT INTO api.trace_versions (trace_id, version_number, file_name, bucket_path, user_id, date_created) VALUES ($1, 1, $2, $3, $4, $5)",
+			trace.TraceID, trace.FileName, trace.BucketPath, trace.UserID, trace.DateCreated,
+		)
+		return err
+	})
 	if err != nil {
-		return models.Trace{}, err
+		return models.Trace{}, translateError(err)
 	}
-	return trace, err
+	return trace, nil
 }
 
 // GetTraceByID retrieves a trace by its ID
-func GetTraceByID(db *sql.DB, traceID string) (*models.Trace, error) {
+func GetTraceByID(ctx context.Context, db DBTX, traceID string) (*models.Trace, error) {
 	trace := &models.Trace{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT trace_id, user_id, file_name, date_created, bucket_path, course_id, instructor_id, semester_term, section FROM api.traces WHERE trace_id = $1",
 		traceID,
 	).Scan(&trace.TraceID, &trace.UserID, &trace.FileName, &trace.DateCreated, &trace.BucketPath, &trace.CourseID, &trace.InstructorID, &trace.SemesterTerm, &trace.Section)
-	return trace, err
+	return trace, translateError(err)
 }
 
 // GetAllTraces retrieves all traces
-func GetAllTraces(db *sql.DB) ([]models.Trace, error) {
-	rows, err := db.Query(
+func GetAllTraces(ctx context.Context, db DBTX) ([]models.Trace, error) {
+	rows, err := queryContext(ctx, db,
 		"SELECT trace_id, user_id, file_name, date_created, bucket_path, course_id, instructor_id, semester_term, section FROM api.traces",
 	)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -42,7 +53,7 @@ func GetAllTraces(db *sql.DB) ([]models.Trace, error) {
 	for rows.Next() {
 		var trace models.Trace
 		if err := rows.Scan(&trace.TraceID, &trace.UserID, &trace.FileName, &trace.DateCreated, &trace.BucketPath, &trace.CourseID, &trace.InstructorID, &trace.SemesterTerm, &trace.Section); err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		traces = append(traces, trace)
 	}
@@ -50,13 +61,13 @@ func GetAllTraces(db *sql.DB) ([]models.Trace, error) {
 }
 
 // get all trace by courseID
-func GetTraceByCourseID(db *sql.DB, courseID string) ([]models.Trace, error) {
-	rows, err := db.Query(
+func GetTraceByCourseID(ctx context.Context, db DBTX, courseID string) ([]models.Trace, error) {
+	rows, err := queryContext(ctx, db,
 		"SELECT trace_id, user_id, file_name, date_created, bucket_path, course_id, instructor_id, semester_term, section FROM api.traces WHERE course_id = $1",
 		courseID,
 	)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -64,40 +75,105 @@ func GetTraceByCourseID(db *sql.DB, courseID string) ([]models.Trace, error) {
 	for rows.Next() {
 		var trace models.Trace
 		if err := rows.Scan(&trace.TraceID, &trace.UserID, &trace.FileName, &trace.DateCreated, &trace.BucketPath, &trace.CourseID, &trace.InstructorID, &trace.SemesterTerm, &trace.Section); err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		traces = append(traces, trace)
 	}
 	return traces, nil
 }
 
+// GetTracesByInstructorAndTerm retrieves the traces of an instructor in a semester term
+func GetTracesByInstructorAndTerm(ctx context.Context, db DBTX, instructorID, semesterTerm string) ([]models.Trace, error) {
+	rows, err := queryContext(ctx, db,
+		"SELECT trace_id, user_id, file_name, date_created, bucket_path, course_id, instructor_id, semester_term, section FROM api.traces WHERE instructor_id = $1 AND semester_term = $2 ORDER BY course_id, section",
+		instructorID, semesterTerm,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	traces := []models.Trace{}
+	for rows.Next() {
+		var trace models.Trace
+		if err := rows.Scan(&trace.TraceID, &trace.UserID, &trace.FileName, &trace.DateCreated, &trace.BucketPath, &trace.CourseID, &trace.InstructorID, &trace.SemesterTerm, &trace.Section); err != nil {
+			return nil, translateError(err)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, translateError(rows.Err())
+}
+
+// GetInstructorIDsByDepartmentAndTerm retrieves the instructors with traces for courses of a department in a semester term
+func GetInstructorIDsByDepartmentAndTerm(ctx context.Context, db DBTX, departmentID int, semesterTerm string) ([]string, error) {
+	rows, err := queryContext(ctx, db,
+		"SELECT DISTINCT t.instructor_id FROM api.traces t JOIN api.courses c ON c.course_id = t.course_id WHERE c.department_id = $1 AND t.semester_term = $2 ORDER BY t.instructor_id",
+		departmentID, semesterTerm,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	instructorIDs := []string{}
+	for rows.Next() {
+		var instructorID string
+		if err := rows.Scan(&instructorID); err != nil {
+			return nil, translateError(err)
+		}
+		instructorIDs = append(instructorIDs, instructorID)
+	}
+	return instructorIDs, translateError(rows.Err())
+}
+
+// UpdateTrace updates the metadata of a trace
+func UpdateTrace(ctx context.Context, db DBTX, trace *models.Trace) error {
+	result, err := execContext(ctx, db,
+		"UPDATE api.traces SET course_id=$1, instructor_id=$2, semester_term=$3, section=$4 WHERE trace_id=$5",
+		trace.CourseID, trace.InstructorID, trace.SemesterTerm, trace.Section, trace.TraceID,
+	)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return translateError(sql.ErrNoRows)
+	}
+
+	return nil
+}
+
 // delete trace by ID
-func DeleteTrace(db *sql.DB, traceID string) error {
-	result, err := db.Exec(
+func DeleteTrace(ctx context.Context, db DBTX, traceID string) error {
+	result, err := execContext(ctx, db,
 		"DELETE FROM api.traces WHERE trace_id = $1",
 		traceID,
 	)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return translateError(sql.ErrNoRows)
 	}
 
 	return nil
 }
 
 // get filepath from trace id
-func GetFilePath(db *sql.DB, traceID string) (string, error) {
+func GetFilePath(ctx context.Context, db DBTX, traceID string) (string, error) {
 	var filePath string
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT bucket_path FROM api.traces WHERE trace_id = $1",
 		traceID,
 	).Scan(&filePath)
-	return filePath, err
+	return filePath, translateError(err)
 }
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+
+	"api-server/internal/models"
+)
+
+// AddTraceVersion makes the given file the current version of a trace. Traces created before
+// versioning existed get their original file recorded as version 1 first.
+func AddTraceVersion(ctx context.Context, db DBTX, version models.TraceVersion) (models.TraceVersion, error) {
+	err := inTx(ctx, db, func(tx DBTX) error {
+		// lock the trace so concurrent replacements get distinct version numbers
+		var current models.Trace
+		err := queryRowContext(ctx, tx,
+			"SELECT trace_id, user_id, file_name, date_created, bucket_path FROM api.traces WHERE trace_id = $1 FOR UPDATE",
+			version.TraceID,
+		).Scan(&current.TraceID, &current.UserID, &current.FileName, &current.DateCreated, &current.BucketPath)
+		if err != nil {
+			return err
+		}
+
+		var latest int
+		err = queryRowContext(ctx, tx,
+			"SELECT COALESCE(MAX(version_number), 0) FROM api.trace_versions WHERE trace_id = $1",
+			version.TraceID,
+		).Scan(&latest)
+		if err != nil {
+			return err
+		}
+		if latest == 0 {
+			_, err = execContext(ctx, tx,
+				"INSERT INTO api.trace_versions (trace_id, version_number, file_name, bucket_path, user_id, date_created) VALUES ($1, 1, $2, $3, $4, $5)",
+				current.TraceID, current.FileName, current.BucketPath, current.UserID, current.DateCreated,
+			)
+			if err != nil {
+				return err
+			}
+			latest = 1
+		}
+
+		version.VersionNumber = latest + 1
+		_, err = execContext(ctx, tx,
+			"INSERT INTO api.trace_versions (trace_id, version_number, file_name, bucket_path, user_id, date_created) VALUES ($1, $2, $3, $4, $5, $6)",
+			version.TraceID, version.VersionNumber, version.FileName, version.BucketPath, version.UserID, version.DateCreated,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = execContext(ctx, tx,
+			"UPDATE api.traces SET file_name = $1, bucket_path = $2 WHERE trace_id = $3",
+			version.FileName, version.BucketPath, version.TraceID,
+		)
+		return err
+	})
+	if err != nil {
+		return models.TraceVersion{}, translateError(err)
+	}
+	return version, nil
+}
+
+// GetTraceVersions retrieves all versions of a trace, newest first
+func GetTraceVersions(ctx context.Context, db DBTX, traceID string) ([]models.TraceVersion, error) {
+	rows, err := queryContext(ctx, db,
+		"SELECT trace_id, version_number, file_name, bucket_path, user_id, date_created FROM api.trace_versions WHERE trace_id = $1 ORDER BY version_number DESC",
+		traceID,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	versions := []models.TraceVersion{}
+	for rows.Next() {
+		var version models.TraceVersion
+		if err := rows.Scan(&version.TraceID, &version.VersionNumber, &version.FileName, &version.BucketPath, &version.UserID, &version.DateCreated); err != nil {
+			return nil, translateError(err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, translateError(rows.Err())
+}
+
+// GetTraceVersion retrieves a single version of a trace
+func GetTraceVersion(ctx context.Context, db DBTX, traceID string, versionNumber int) (*models.TraceVersion, error) {
+	version := &models.TraceVersion{}
+	err := queryRowContext(ctx, db,
+		"SELECT trace_id, version_number, file_name, bucket_path, user_id, date_created FROM api.trace_versions WHERE trace_id = $1 AND version_number = $2",
+		traceID, versionNumber,
+	).Scan(&version.TraceID, &version.VersionNumber, &version.FileName, &version.BucketPath, &version.UserID, &version.DateCreated)
+	return version, translateError(err)
+}
+
+// DeleteTraceVersions deletes the version history of a trace
+func DeleteTraceVersions(ctx context.Context, db DBTX, traceID string) error {
+	_, err := execContext(ctx, db,
+		"DELETE FROM api.trace_versions WHERE trace_id = $1",
+		traceID,
+	)
+	return translateError(err)
+}
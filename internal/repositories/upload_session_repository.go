@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-server/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const uploadSessionColumns = "upload_id, user_id, course_id, instructor_id, semester_term, section, file_name, upload_length, upload_offset, status, trace_id, error, date_created, expires_at, date_finished"
+
+func scanUploadSession(row interface{ Scan(...any) error }, session *models.UploadSession) error {
+	return translateError(row.Scan(&session.UploadID, &session.UserID, &session.CourseID, &session.InstructorID, &session.SemesterTerm, &session.Section, &session.FileName, &session.UploadLength, &session.UploadOffset, &session.Status, &session.TraceID, &session.Error, &session.DateCreated, &session.ExpiresAt, &session.DateFinished))
+}
+
+// CreateUploadSession creates a new resumable upload session
+func CreateUploadSession(ctx context.Context, db DBTX, session models.UploadSession) (models.UploadSession, error) {
+	_, err := execContext(ctx, db,
+		"INSERT INTO api.upload_sessions (upload_id, user_id, course_id, instructor_id, semester_term, section, file_name, upload_length, upload_offset, status, date_created, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		session.UploadID, session.UserID, session.CourseID, session.InstructorID, session.SemesterTerm, session.Section, session.FileName, session.UploadLength, session.UploadOffset, session.Status, session.DateCreated, session.ExpiresAt,
+	)
+	if err != nil {
+		return models.UploadSession{}, translateError(err)
+	}
+	return session, nil
+}
+
+// GetUploadSession retrieves an upload session by its ID
+func GetUploadSession(ctx context.Context, db DBTX, uploadID string) (*models.UploadSession, error) {
+	session := &models.UploadSession{}
+	err := scanUploadSession(queryRowContext(ctx, db,
+		"SELECT "+uploadSessionColumns+" FROM api.upload_sessions WHERE upload_id = $1",
+		uploadID,
+	), session)
+	return session, translateError(err)
+}
+
+// AppendUploadChunk records a chunk and advances the session offset. The update only applies
+// if the offset has not moved since the chunk was read, otherwise sql.ErrNoRows is returned.
+// The last chunk moves the session to assembling, so the janitor leaves it alone.
+func AppendUploadChunk(ctx context.Context, db DBTX, chunk models.UploadChunk, expiresAt time.Time) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		result, err := execContext(ctx, tx,
+			"UPDATE api.upload_sessions SET upload_offset = $1, expires_at = $2, status = CASE WHEN $1 >= upload_length THEN $6 ELSE status END WHERE upload_id = $3 AND upload_offset = $4 AND status = $5",
+			chunk.ChunkOffset+chunk.ChunkSize, expiresAt, chunk.UploadID, chunk.ChunkOffset, models.UploadStatusPending, models.UploadStatusAssembling,
+		)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = execContext(ctx, tx,
+			"INSERT INTO api.upload_chunks (upload_id, chunk_offset, chunk_size, bucket_path, checksum) VALUES ($1, $2, $3, $4, $5)",
+			chunk.UploadID, chunk.ChunkOffset, chunk.ChunkSize, chunk.BucketPath, chunk.Checksum,
+		)
+		return err
+	}))
+}
+
+// GetUploadChunks retrieves the chunks of an upload in offset order
+func GetUploadChunks(ctx context.Context, db DBTX, uploadID string) ([]models.UploadChunk, error) {
+	rows, err := queryContext(ctx, db,
+		"SELECT upload_id, chunk_offset, chunk_size, bucket_path, checksum FROM api.upload_chunks WHERE upload_id = $1 ORDER BY chunk_offset",
+		uploadID,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	chunks := []models.UploadChunk{}
+	for rows.Next() {
+		var chunk models.UploadChunk
+		if err := rows.Scan(&chunk.UploadID, &chunk.ChunkOffset, &chunk.ChunkSize, &chunk.BucketPath, &chunk.Checksum); err != nil {
+			return nil, translateError(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, translateError(rows.Err())
+}
+
+// FinishUploadSession marks a session completed or failed and drops its chunk records
+func FinishUploadSession(ctx context.Context, db DBTX, uploadID, status string, traceID, errMessage *string) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		_, err := execContext(ctx, tx,
+			"UPDATE api.upload_sessions SET status = $1, trace_id = $2, error = $3, date_finished = $4 WHERE upload_id = $5",
+			status, traceID, errMessage, time.Now().UTC(), uploadID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = execContext(ctx, tx, "DELETE FROM api.upload_chunks WHERE upload_id = $1", uploadID)
+		return err
+	}))
+}
+
+// DeleteUploadSession deletes a session and its chunk records
+func DeleteUploadSession(ctx context.Context, db DBTX, uploadID string) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		if _, err := execContext(ctx, tx, "DELETE FROM api.upload_chunks WHERE upload_id = $1", uploadID); err != nil {
+			return err
+		}
+		result, err := execContext(ctx, tx, "DELETE FROM api.upload_sessions WHERE upload_id = $1", uploadID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	}))
+}
+
+// DeleteExpiredUploadSessions deletes the sessions past their expiry and returns the
+// chunks they held, so their staged objects can be removed afterwards. Sessions a request
+// is appending to are skipped, and assembling sessions are only deleted once their expiry
+// lies before staleBefore, as their assembly then cannot be running anymore.
+func DeleteExpiredUploadSessions(ctx context.Context, db DBTX, now, staleBefore time.Time) (int, []models.UploadChunk, error) {
+	var uploadIDs []string
+	chunks := []models.UploadChunk{}
+	err := inTx(ctx, db, func(tx DBTX) error {
+		rows, err := queryContext(ctx, tx,
+			"SELECT upload_id FROM api.upload_sessions WHERE expires_at < $1 AND (status <> $2 OR expires_at < $3) FOR UPDATE SKIP LOCKED",
+			now, models.UploadStatusAssembling, staleBefore,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var uploadID string
+			if err := rows.Scan(&uploadID); err != nil {
+				return err
+			}
+			uploadIDs = append(uploadIDs, uploadID)
+		}
+		if err := rows.Err(); err != nil || len(uploadIDs) == 0 {
+			return err
+		}
+		rows.Close()
+
+		chunkRows, err := queryContext(ctx, tx,
+			"DELETE FROM api.upload_chunks WHERE upload_id = ANY($1::uuid[]) RETURNING upload_id, chunk_offset, chunk_size, bucket_path, checksum",
+			pq.Array(uploadIDs),
+		)
+		if err != nil {
+			return err
+		}
+		defer chunkRows.Close()
+		for chunkRows.Next() {
+			var chunk models.UploadChunk
+			if err := chunkRows.Scan(&chunk.UploadID, &chunk.ChunkOffset, &chunk.ChunkSize, &chunk.BucketPath, &chunk.Checksum); err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+		}
+		if err := chunkRows.Err(); err != nil {
+			return err
+		}
+		chunkRows.Close()
+
+		_, err = execContext(ctx, tx, "DELETE FROM api.upload_sessions WHERE upload_id = ANY($1::uuid[])", pq.Array(uploadIDs))
+		return err
+	})
+	if err != nil {
+		return 0, nil, translateError(err)
+	}
+	return len(uploadIDs), chunks, nil
+}
@@ -1,14 +1,17 @@
 package repositories
 
 import (
+	"context"
+
 	"api-server/internal/models"
 	"database/sql"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func CreateUser(db *sql.DB, userReq models.UserRequest) (*models.User, error) {
+func CreateUser(ctx context.Context, db DBTX, userReq models.UserRequest) (*models.User, error) {
 	userID := uuid.New().String()
 	now := time.Now().UTC()
 
@@ -22,34 +25,34 @@ func CreateUser(db *sql.DB, userReq models.UserRequest) (*models.User, error) {
 		AccountUpdated: now,
 	}
 
-	_, err := db.Exec(
+	_, err := execContext(ctx, db,
 		"INSERT INTO api.users (user_id, first_name, last_name, username, password, account_created, account_updated) VALUES ($1, $2, $3, $4, $5, $6, $7)",
 		user.UserID, user.FirstName, user.LastName, user.Username, user.Password, user.AccountCreated, user.AccountUpdated,
 	)
-	return &user, err
+	return &user, translateError(err)
 }
 
-func GetUserByID(db *sql.DB, userID string) (*models.User, error) {
+func GetUserByID(ctx context.Context, db DBTX, userID string) (*models.User, error) {
 	user := &models.User{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT user_id, first_name, last_name, username, password, account_created, account_updated FROM api.users WHERE user_id = $1",
 		userID,
 	).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Username, &user.Password, &user.AccountCreated, &user.AccountUpdated)
-	return user, err
+	return user, translateError(err)
 }
 
-func UpdateUser(db *sql.DB, user *models.User) error {
+func UpdateUser(ctx context.Context, db DBTX, user *models.User) error {
 	user.AccountUpdated = time.Now().UTC()
-	_, err := db.Exec(
+	_, err := execContext(ctx, db,
 		"UPDATE api.users SET first_name=$1, last_name=$2, username=$3, password=$4, account_updated=$5 WHERE user_id=$6",
 		user.FirstName, user.LastName, user.Username, user.Password, user.AccountUpdated, user.UserID,
 	)
-	return err
+	return translateError(err)
 }
 
-func GetUserByUsername(db *sql.DB, username string) (*models.User, error) {
+func GetUserByUsername(ctx context.Context, db DBTX, username string) (*models.User, error) {
 	user := &models.User{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT user_id, first_name, last_name, username FROM api.users WHERE username = $1",
 		username,
 	).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Username)
@@ -58,7 +61,7 @@ func GetUserByUsername(db *sql.DB, username string) (*models.User, error) {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, err
+		return nil, translateError(err)
 	}
 	return user, nil
 }
@@ -69,16 +72,21 @@ type UserWithPassword struct {
 	Password string
 }
 
+// LogValue keeps the password hash out of logs when a user is logged whole
+func (u *UserWithPassword) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("user_id", u.UserID), slog.String("username", u.Username))
+}
+
 // GetUserWithPasswordByUsername retrieves a user with password by username
-func GetUserWithPasswordByUsername(db *sql.DB, username string) (*UserWithPassword, error) {
+func GetUserWithPasswordByUsername(ctx context.Context, db DBTX, username string) (*UserWithPassword, error) {
 	user := &UserWithPassword{}
-	err := db.QueryRow(
+	err := queryRowContext(ctx, db,
 		"SELECT user_id, first_name, last_name, username, password, account_created, account_updated FROM api.users WHERE username = $1",
 		username,
 	).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Username, &user.Password, &user.AccountCreated, &user.AccountUpdated)
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return user, nil
 }
@@ -0,0 +1,238 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-server/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const webhookColumns = "webhook_id, user_id, url, secret, event_types, department_id, description, active, failure_count, disabled_reason, date_created, date_updated"
+
+const webhookDeliveryColumns = "delivery_id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, date_created, date_delivered"
+
+func scanWebhook(row interface{ Scan(...any) error }, webhook *models.WebhookSubscription) error {
+	var departmentID sql.NullInt64
+	err := row.Scan(&webhook.WebhookID, &webhook.UserID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &departmentID, &webhook.Description, &webhook.Active, &webhook.FailureCount, &webhook.DisabledReason, &webhook.DateCreated, &webhook.DateUpdated)
+	if departmentID.Valid {
+		id := int(departmentID.Int64)
+		webhook.DepartmentID = &id
+	}
+	return translateError(err)
+}
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }, delivery *models.WebhookDelivery) error {
+	var payload []byte
+	var statusCode sql.NullInt64
+	err := row.Scan(&delivery.DeliveryID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &statusCode, &delivery.LastError, &delivery.DateCreated, &delivery.DateDelivered)
+	delivery.Payload = payload
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	return translateError(err)
+}
+
+// CreateWebhook creates a new webhook subscription
+func CreateWebhook(ctx context.Context, db DBTX, webhook models.WebhookSubscription) (models.WebhookSubscription, error) {
+	_, err := execContext(ctx, db,
+		"INSERT INTO api.webhook_subscriptions ("+webhookColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		webhook.WebhookID, webhook.UserID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes), webhook.DepartmentID, webhook.Description, webhook.Active, webhook.FailureCount, webhook.DisabledReason, webhook.DateCreated, webhook.DateUpdated,
+	)
+	if err != nil {
+		return models.WebhookSubscription{}, translateError(err)
+	}
+	return webhook, nil
+}
+
+// GetWebhook retrieves a webhook subscription by its ID
+func GetWebhook(ctx context.Context, db DBTX, webhookID string) (*models.WebhookSubscription, error) {
+	webhook := &models.WebhookSubscription{}
+	err := scanWebhook(queryRowContext(ctx, db,
+		"SELECT "+webhookColumns+" FROM api.webhook_subscriptions WHERE webhook_id = $1",
+		webhookID,
+	), webhook)
+	return webhook, translateError(err)
+}
+
+// GetWebhooksByUser retrieves the webhook subscriptions created by a user
+func GetWebhooksByUser(ctx context.Context, db DBTX, userID string) ([]models.WebhookSubscription, error) {
+	return queryWebhooks(ctx, db, "SELECT "+webhookColumns+" FROM api.webhook_subscriptions WHERE user_id = $1 ORDER BY date_created", userID)
+}
+
+// GetWebhooksForEvent retrieves the active subscriptions of an event type, either
+// scoped to the department of the event or to every department
+func GetWebhooksForEvent(ctx context.Context, db DBTX, eventType string, departmentID int) ([]models.WebhookSubscription, error) {
+	return queryWebhooks(ctx, db,
+		"SELECT "+webhookColumns+" FROM api.webhook_subscriptions WHERE active AND $1 = ANY(event_types) AND (department_id IS NULL OR department_id = $2)",
+		eventType, departmentID,
+	)
+}
+
+func queryWebhooks(ctx context.Context, db DBTX, query string, args ...any) ([]models.WebhookSubscription, error) {
+	rows, err := queryContext(ctx, db, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.WebhookSubscription{}
+	for rows.Next() {
+		var webhook models.WebhookSubscription
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, translateError(err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, translateError(rows.Err())
+}
+
+// UpdateWebhook updates the settings of a webhook subscription
+func UpdateWebhook(ctx context.Context, db DBTX, webhook *models.WebhookSubscription) error {
+	result, err := execContext(ctx, db,
+		"UPDATE api.webhook_subscriptions SET url = $1, event_types = $2, department_id = $3, description = $4, active = $5, failure_count = $6, disabled_reason = $7, date_updated = $8 WHERE webhook_id = $9",
+		webhook.URL, pq.Array(webhook.EventTypes), webhook.DepartmentID, webhook.Description, webhook.Active, webhook.FailureCount, webhook.DisabledReason, webhook.DateUpdated, webhook.WebhookID,
+	)
+	if err != nil {
+		return translateError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return translateError(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// DeleteWebhook deletes a webhook subscription, its deliveries are removed by cascade
+func DeleteWebhook(ctx context.Context, db DBTX, webhookID string) error {
+	result, err := execContext(ctx, db, "DELETE FROM api.webhook_subscriptions WHERE webhook_id = $1", webhookID)
+	if err != nil {
+		return translateError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return translateError(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// RecordWebhookSuccess resets the consecutive failure count of a subscription
+func RecordWebhookSuccess(ctx context.Context, db DBTX, webhookID string) error {
+	_, err := execContext(ctx, db, "UPDATE api.webhook_subscriptions SET failure_count = 0 WHERE webhook_id = $1 AND failure_count > 0", webhookID)
+	return translateError(err)
+}
+
+// RecordWebhookFailure counts a failed delivery attempt and disables the subscription once
+// disableAfter consecutive attempts failed. It returns true when the subscription was disabled.
+func RecordWebhookFailure(ctx context.Context, db DBTX, webhookID string, disableAfter int, reason string) (bool, error) {
+	var disabled bool
+	err := queryRowContext(ctx, db,
+		`UPDATE api.webhook_subscriptions
+		SET failure_count = failure_count + 1,
+			active = CASE WHEN failure_count + 1 >= $1 THEN false ELSE active END,
+			disabled_reason = CASE WHEN failure_count + 1 >= $1 AND active THEN $2 ELSE disabled_reason END,
+			date_updated = CASE WHEN failure_count + 1 >= $1 AND active THEN $3 ELSE date_updated END
+		WHERE webhook_id = $4
+		RETURNING NOT active`,
+		disableAfter, reason, time.Now().UTC(), webhookID,
+	).Scan(&disabled)
+	return disabled, translateError(err)
+}
+
+// CreateWebhookDeliveries queues deliveries in a single transaction
+func CreateWebhookDeliveries(ctx context.Context, db DBTX, deliveries []models.WebhookDelivery) error {
+	return translateError(inTx(ctx, db, func(tx DBTX) error {
+		for _, delivery := range deliveries {
+			_, err := execContext(ctx, tx,
+				"INSERT INTO api.webhook_deliveries ("+webhookDeliveryColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+				delivery.DeliveryID, delivery.WebhookID, delivery.EventID, delivery.EventType, []byte(delivery.Payload), delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.DateCreated, delivery.DateDelivered,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// ClaimDueWebhookDeliveries returns up to limit pending deliveries of active subscriptions that
+// are due at now, and pushes their next attempt back by lease so other replicas skip them
+func ClaimDueWebhookDeliveries(ctx context.Context, db DBTX, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := queryContext(ctx, db,
+		`UPDATE api.webhook_deliveries SET next_attempt_at = $1
+		WHERE delivery_id IN (
+			SELECT d.delivery_id FROM api.webhook_deliveries d
+			JOIN api.webhook_subscriptions s ON s.webhook_id = d.webhook_id
+			WHERE d.status = $2 AND d.next_attempt_at <= $3 AND s.active
+			ORDER BY d.next_attempt_at
+			LIMIT $4
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns,
+		now.Add(lease), models.WebhookDeliveryPending, now, limit,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			return nil, translateError(err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, translateError(rows.Err())
+}
+
+// UpdateWebhookDelivery stores the outcome of a delivery attempt
+func UpdateWebhookDelivery(ctx context.Context, db DBTX, delivery models.WebhookDelivery) error {
+	_, err := execContext(ctx, db,
+		"UPDATE api.webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, date_delivered = $6 WHERE delivery_id = $7",
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.DateDelivered, delivery.DeliveryID,
+	)
+	return translateError(err)
+}
+
+// GetWebhookDeliveries retrieves the latest deliveries of a subscription, optionally filtered by status
+func GetWebhookDeliveries(ctx context.Context, db DBTX, webhookID, status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := queryContext(ctx, db,
+		"SELECT "+webhookDeliveryColumns+" FROM api.webhook_deliveries WHERE webhook_id = $1 AND ($2 = '' OR status = $2) ORDER BY date_created DESC LIMIT $3",
+		webhookID, status, limit,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			return nil, translateError(err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, translateError(rows.Err())
+}
+
+// GetWebhookDelivery retrieves a delivery of a subscription
+func GetWebhookDelivery(ctx context.Context, db DBTX, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	err := scanWebhookDelivery(queryRowContext(ctx, db,
+		"SELECT "+webhookDeliveryColumns+" FROM api.webhook_deliveries WHERE webhook_id = $1 AND delivery_id = $2",
+		webhookID, deliveryID,
+	), delivery)
+	return delivery, translateError(err)
+}
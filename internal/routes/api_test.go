@@ -0,0 +1,404 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-server/internal/handlers"
+	"api-server/internal/middleware"
+	"api-server/internal/models"
+	"api-server/internal/repositories/memory"
+	"api-server/internal/scanner"
+	"api-server/internal/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct-horse"
+
+// testAPI is the router over a memory store seeded with a department, a semester term,
+// an instructor and a course
+type testAPI struct {
+	t            *testing.T
+	router       *mux.Router
+	store        *memory.Store
+	instructorID string
+	courseID     string
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	store.AddDepartment(models.Department{DepartmentID: 1, Name: "Computer Science"})
+	store.AddSemesterTerm(models.SemesterTermModel{SemesterTerm: "2026FA", Name: "Fall 2026"})
+	instructor, err := store.CreateInstructor(ctx, models.Instructor{InstructorID: uuid.New().String(), Name: "Ada Lovelace"})
+	if err != nil {
+		t.Fatalf("CreateInstructor: %v", err)
+	}
+	course, err := store.CreateCourse(ctx, models.Course{CourseID: uuid.New().String(), InstructorID: instructor.InstructorID, DepartmentID: 1})
+	if err != nil {
+		t.Fatalf("CreateCourse: %v", err)
+	}
+
+	middleware.SetUserLookup(store)
+	middleware.SetIdempotencyStore(store)
+	t.Cleanup(func() {
+		middleware.SetUserLookup(nil)
+		middleware.SetIdempotencyStore(nil)
+	})
+	return &testAPI{
+		t:            t,
+		router:       RegisterRoutes(handlers.NewHandler(store)),
+		store:        store,
+		instructorID: instructor.InstructorID,
+		courseID:     course.CourseID,
+	}
+}
+
+// addUser creates a user whose password is testPassword and returns its ID
+func (api *testAPI) addUser(username string) string {
+	api.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		api.t.Fatalf("hashing password: %v", err)
+	}
+	user, err := api.store.CreateUser(context.Background(), models.UserRequest{FirstName: "Test", LastName: "User", Username: username, Password: string(hash)})
+	if err != nil {
+		api.t.Fatalf("CreateUser: %v", err)
+	}
+	return user.UserID
+}
+
+// do sends a request as username, or unauthenticated when username is empty
+func (api *testAPI) do(method, path, username string, body []byte, contentType string) *httptest.ResponseRecorder {
+	api.t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, testPassword)
+	}
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (api *testAPI) doJSON(method, path, username string, body any) *httptest.ResponseRecorder {
+	api.t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		api.t.Fatalf("encoding request body: %v", err)
+	}
+	return api.do(method, path, username, data, "application/json")
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.NewDecoder(rec.Body).Decode(&value); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+	}
+	return value
+}
+
+func TestAuthLooksUpUsersInTheStore(t *testing.T) {
+	api := newTestAPI(t)
+	api.addUser("ada@example.com")
+
+	if rec := api.do(http.MethodGet, "/v1/instructors", "", nil, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without credentials: status %d, want 401", rec.Code)
+	}
+	if rec := api.do(http.MethodGet, "/v1/instructors", "nobody@example.com", nil, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown user: status %d, want 401", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/instructors", nil)
+	req.SetBasicAuth("ada@example.com", "wrong-password")
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d, want 401", rec.Code)
+	}
+
+	rec = api.do(http.MethodGet, "/v1/instructors", "ada@example.com", nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid credentials: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if instructors := decode[[]models.Instructor](t, rec); len(instructors) != 1 || instructors[0].InstructorID != api.instructorID {
+		t.Errorf("instructors = %+v, want the seeded instructor", instructors)
+	}
+}
+
+func TestWebhookSubscriptionsAndReplay(t *testing.T) {
+	api := newTestAPI(t)
+	api.addUser("ada@example.com")
+	api.addUser("grace@example.com")
+
+	rec := api.doJSON(http.MethodPost, "/v1/webhooks", "ada@example.com", models.WebhookRequest{
+		URL:        "http://127.0.0.1/hook",
+		EventTypes: []string{"trace.uploaded"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("webhook to a loopback address: status %d, want 400", rec.Code)
+	}
+
+	rec = api.doJSON(http.MethodPost, "/v1/webhooks", "ada@example.com", models.WebhookRequest{
+		URL:        "https://93.184.216.34/hook",
+		EventTypes: []string{"trace.uploaded"},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating webhook: status %d, want 201: %s", rec.Code, rec.Body)
+	}
+	created := decode[models.WebhookSubscription](t, rec)
+	if created.Secret == "" {
+		t.Error("the signing secret is not returned on creation")
+	}
+
+	rec = api.do(http.MethodGet, "/v1/webhooks", "ada@example.com", nil, "")
+	listed := decode[[]models.WebhookSubscription](t, rec)
+	if len(listed) != 1 || listed[0].WebhookID != created.WebhookID || listed[0].Secret != "" {
+		t.Errorf("listed webhooks = %+v, want the created one without its secret", listed)
+	}
+	if rec := api.do(http.MethodGet, "/v1/webhooks/"+created.WebhookID, "grace@example.com", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("webhook of another user: status %d, want 404", rec.Code)
+	}
+
+	now := time.Now().UTC()
+	original := models.WebhookDelivery{
+		DeliveryID:  uuid.New().String(),
+		WebhookID:   created.WebhookID,
+		EventID:     uuid.New().String(),
+		EventType:   "trace.uploaded",
+		Payload:     json.RawMessage(`{"trace_id":"t"}`),
+		Status:      models.WebhookDeliveryFailed,
+		Attempts:    8,
+		DateCreated: now.Add(-time.Hour),
+	}
+	if err := api.store.CreateWebhookDeliveries(context.Background(), []models.WebhookDelivery{original}); err != nil {
+		t.Fatalf("CreateWebhookDeliveries: %v", err)
+	}
+
+	rec = api.do(http.MethodPost, "/v1/webhooks/"+created.WebhookID+"/deliveries/"+original.DeliveryID+"/replay", "ada@example.com", nil, "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("replaying delivery: status %d, want 202: %s", rec.Code, rec.Body)
+	}
+	replayed := decode[models.WebhookDelivery](t, rec)
+	if replayed.EventID != original.EventID || replayed.Status != models.WebhookDeliveryPending {
+		t.Errorf("replayed delivery = %+v, want a pending delivery of the same event", replayed)
+	}
+
+	rec = api.do(http.MethodGet, "/v1/webhooks/"+created.WebhookID+"/deliveries?status=pending", "ada@example.com", nil, "")
+	if pending := decode[[]models.WebhookDelivery](t, rec); len(pending) != 1 || pending[0].DeliveryID != replayed.DeliveryID {
+		t.Errorf("pending deliveries = %+v, want the replayed one", pending)
+	}
+
+	if rec := api.do(http.MethodDelete, "/v1/webhooks/"+created.WebhookID, "ada@example.com", nil, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("deleting webhook: status %d, want 204", rec.Code)
+	}
+	if _, err := api.store.GetWebhookDelivery(context.Background(), created.WebhookID, replayed.DeliveryID); err == nil {
+		t.Error("deliveries of a deleted webhook are kept")
+	}
+}
+
+func TestSurveyResultsAndAnalytics(t *testing.T) {
+	api := newTestAPI(t)
+	userID := api.addUser("ada@example.com")
+	middleware.SetInternalAPIToken("internal-token")
+	t.Cleanup(func() { middleware.SetInternalAPIToken("") })
+
+	trace, err := api.store.CreateTrace(context.Background(), models.Trace{
+		TraceID:      uuid.New().String(),
+		UserID:       userID,
+		FileName:     "trace.pdf",
+		DateCreated:  time.Now().UTC(),
+		BucketPath:   "gs://bucket/trace.pdf",
+		CourseID:     api.courseID,
+		InstructorID: api.instructorID,
+		SemesterTerm: "2026FA",
+		Section:      "01",
+	})
+	if err != nil {
+		t.Fatalf("CreateTrace: %v", err)
+	}
+	webhook, err := api.store.CreateWebhook(context.Background(), models.WebhookSubscription{
+		WebhookID:   uuid.New().String(),
+		UserID:      userID,
+		URL:         "https://93.184.216.34/hook",
+		EventTypes:  []string{"trace.results_processed"},
+		Active:      true,
+		DateCreated: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	resultsPath := "/v1/course/" + api.courseID + "/trace/" + trace.TraceID + "/results"
+
+	if rec := api.do(http.MethodGet, resultsPath, "ada@example.com", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("results before ingestion: status %d, want 404", rec.Code)
+	}
+
+	body, _ := json.Marshal(models.SurveyResultRequest{
+		ResponseCount: 20,
+		EnrolledCount: 25,
+		Questions: []models.SurveyQuestionRequest{{
+			Position:      1,
+			Category:      "Course",
+			Text:          "The course was well organized",
+			ResponseCount: 20,
+			Ratings:       []models.RatingCount{{Rating: 5, Count: 10}, {Rating: 4, Count: 10}},
+		}},
+		Comments: []models.SurveyCommentRequest{{Question: "Comments", Text: "Great course"}},
+	})
+	req := httptest.NewRequest(http.MethodPut, "/internal/v1/trace/"+trace.TraceID+"/results", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer internal-token")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("ingesting results: status %d, want 204: %s", rec.Code, rec.Body)
+	}
+	if events := api.store.TraceEvents(); len(events) != 1 || events[0].TraceID != trace.TraceID || events[0].Status != models.TraceStatusProcessed {
+		t.Errorf("trace stream events = %+v, want the trace marked processed", events)
+	}
+	if deliveries, _ := api.store.GetWebhookDeliveries(context.Background(), webhook.WebhookID, models.WebhookDeliveryPending, 10); len(deliveries) != 1 {
+		t.Errorf("pending deliveries = %+v, want one for the processed results", deliveries)
+	}
+
+	rec = api.do(http.MethodGet, resultsPath, "ada@example.com", nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("results: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	result := decode[models.SurveyResult](t, rec)
+	if len(result.Questions) != 1 || result.Questions[0].Mean != 4.5 || len(result.Comments) != 1 {
+		t.Errorf("results = %+v, want one question with mean 4.5 and one comment", result)
+	}
+
+	api.store.AddQuestionStats(models.QuestionTermStats{
+		CourseID:      api.courseID,
+		DepartmentID:  1,
+		InstructorID:  api.instructorID,
+		SemesterTerm:  "2026FA",
+		Position:      1,
+		Category:      "Course",
+		Text:          "The course was well organized",
+		SectionCount:  1,
+		ResponseCount: 20,
+		EnrolledCount: 25,
+		Ratings:       [5]int{0, 0, 0, 10, 10},
+	})
+	rec = api.do(http.MethodGet, "/v1/course/"+api.courseID+"/analytics", "ada@example.com", nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("course analytics: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "2026FA") {
+		t.Errorf("course analytics do not cover the seeded term: %s", rec.Body)
+	}
+}
+
+func TestTraceUploadRejectedWhenTheScannerFails(t *testing.T) {
+	api := newTestAPI(t)
+	api.addUser("ada@example.com")
+	t.Setenv("BUCKET_NAME", "test-bucket")
+	services.SetScanner(&scanner.FakeScanner{Err: errors.New("clamd is unavailable")})
+	t.Cleanup(func() { services.SetScanner(nil) })
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("instructor_id", api.instructorID)
+	form.WriteField("semester_term", "2026FA")
+	form.WriteField("section", "01")
+	file, _ := form.CreateFormFile("file", "trace.pdf")
+	file.Write([]byte("%PDF-1.4\n1 0 obj << /Type /Page >> endobj\nstartxref\n0\n%%EOF\n"))
+	form.Close()
+
+	rec := api.do(http.MethodPost, "/v1/course/"+api.courseID+"/trace", "ada@example.com", body.Bytes(), form.FormDataContentType())
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("upload with a failing scanner: status %d, want 503: %s", rec.Code, rec.Body)
+	}
+	traces, err := api.store.GetTraceByCourseID(context.Background(), api.courseID)
+	if err != nil || len(traces) != 0 {
+		t.Errorf("traces after the rejected upload = %+v, %v; want none", traces, err)
+	}
+}
+
+func TestCreateCourseRejectsUndecodableBody(t *testing.T) {
+	// The request validation middleware rejects such bodies first, the handler must not
+	// rely on it
+	h := handlers.NewHandler(memory.NewStore())
+	req := httptest.NewRequest(http.MethodPost, "/v1/course", strings.NewReader(`{"code": "CS101",`))
+	rec := httptest.NewRecorder()
+	h.CreateCourseHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("truncated body: status %d, want 400: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "invalid request body") {
+		t.Errorf("body = %s, want invalid request body", rec.Body)
+	}
+}
+
+func TestTraceRoutesReportAMissingCourseAsNotFound(t *testing.T) {
+	api := newTestAPI(t)
+	api.addUser("ada@example.com")
+	missing := "/v1/course/" + uuid.New().String()
+
+	for _, path := range []string{missing, missing + "/trace/" + uuid.New().String(), missing + "/trace/" + uuid.New().String() + "/pdf"} {
+		if rec := api.do(http.MethodGet, path, "ada@example.com", nil, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404: %s", path, rec.Code, rec.Body)
+		}
+	}
+	if rec := api.do(http.MethodDelete, missing+"/trace/"+uuid.New().String(), "ada@example.com", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE trace of a missing course: status %d, want 404: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTraceEventsAreLimitedToTheCallersCourses(t *testing.T) {
+	api := newTestAPI(t)
+	adaID := api.addUser("ada@example.com")
+	api.addUser("grace@example.com")
+	course, err := api.store.CreateCourse(context.Background(), models.Course{CourseID: uuid.New().String(), UserID: adaID, InstructorID: api.instructorID, DepartmentID: 1})
+	if err != nil {
+		t.Fatalf("CreateCourse: %v", err)
+	}
+	path := "/v1/events?course_id=" + course.CourseID
+
+	if rec := api.do(http.MethodGet, path, "grace@example.com", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("events of another user's course: status %d, want 404: %s", rec.Code, rec.Body)
+	}
+
+	// the stream ends at once since the request is already cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+	req.SetBasicAuth("ada@example.com", testPassword)
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("events of an own course: status %d, content type %q; want a 200 event stream: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+}
+
+func TestAuthenticationIsCheckedBeforeTheRequestIsValidated(t *testing.T) {
+	api := newTestAPI(t)
+	api.addUser("ada@example.com")
+
+	if rec := api.do(http.MethodPost, "/v1/course", "", []byte(`{"code": 1}`), "application/json"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid body without credentials: status %d, want 401: %s", rec.Code, rec.Body)
+	}
+	if rec := api.do(http.MethodPost, "/v1/course", "", []byte(`code=1`), "text/plain"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsupported media type without credentials: status %d, want 401: %s", rec.Code, rec.Body)
+	}
+	if rec := api.do(http.MethodPost, "/v1/course", "ada@example.com", []byte(`{"code": 1}`), "application/json"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid body with credentials: status %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
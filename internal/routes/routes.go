@@ -1,41 +1,97 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
 
 	"api-server/internal/handlers"
 	"api-server/internal/middleware"
 )
 
-// Register all the application routes
-func RegisterRoutes() *mux.Router {
+// Register all the application routes, served by h where they need the stores
+func RegisterRoutes(h *handlers.Handler) *mux.Router {
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(handlers.NotFoundHandler)
+	r.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowedHandler)
+	r.Use(middleware.RouteMetricsMiddleware)
+
+	// Requests are checked against the OpenAPI document inside the auth middlewares, so
+	// callers without valid credentials get 401 whatever they sent
+	validated := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.OpenAPIValidationMiddleware(next).ServeHTTP
+	}
+	auth := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(validated(next))
+	}
+	internalAuth := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.InternalAuthMiddleware(validated(next))
+	}
+
+	// Every POST route accepts an Idempotency-Key header, wrapped inside the auth
+	// middleware so stored responses are scoped to the authenticated user
 
 	// Public routes
-	r.HandleFunc("/healthz", handlers.HealthCheckHandler).Methods("GET")
-	r.HandleFunc("/v1/user", handlers.CreateUserHandler).Methods("POST")
-	r.HandleFunc("/v1/instructor/{instructor_id}", handlers.InstructorHandler).Methods("GET")
-	r.HandleFunc("/v1/course/{course_id}", handlers.GetCourseHandler).Methods("GET")
+	r.HandleFunc("/livez", validated(handlers.LivenessHandler)).Methods("GET")
+	r.HandleFunc("/readyz", validated(handlers.ReadinessHandler)).Methods("GET")
+	r.HandleFunc("/healthz", validated(handlers.HealthCheckHandler)).Methods("GET")
+	r.HandleFunc("/metrics", validated(handlers.MetricsHandler)).Methods("GET")
+	r.HandleFunc("/openapi.json", validated(handlers.OpenAPISpecHandler)).Methods("GET")
+	r.HandleFunc("/docs", validated(handlers.APIDocsRedirectHandler)).Methods("GET")
+	r.HandleFunc("/docs/", validated(handlers.APIDocsHandler)).Methods("GET")
+	r.HandleFunc("/docs/{asset}", validated(handlers.APIDocsAssetHandler)).Methods("GET")
+	r.HandleFunc("/v1/user", validated(middleware.IdempotencyMiddleware(h.CreateUserHandler))).Methods("POST")
+	r.HandleFunc("/v1/instructor/{instructor_id}", validated(h.InstructorHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}", validated(h.GetCourseHandler)).Methods("GET")
 
 	// Private routes
 	//user
-	r.HandleFunc("/v1/user/{user_id}", middleware.AuthMiddleware(handlers.UserHandler)).Methods("GET", "PUT")
+	r.HandleFunc("/v1/user/{user_id}", auth(h.UserHandler)).Methods("GET", "PUT")
 	//instructor
-	r.HandleFunc("/v1/instructor", middleware.AuthMiddleware(handlers.CreateInstructorHandler)).Methods("POST")
-	r.HandleFunc("/v1/instructor/{instructor_id}", middleware.AuthMiddleware(handlers.InstructorHandler)).Methods("PUT", "PATCH", "DELETE")
-	r.HandleFunc("/v1/instructors", middleware.AuthMiddleware(handlers.GetAllInstructorsHandler)).Methods("GET")
+	r.HandleFunc("/v1/instructor", auth(middleware.IdempotencyMiddleware(h.CreateInstructorHandler))).Methods("POST")
+	r.HandleFunc("/v1/instructor/{instructor_id}", auth(h.InstructorHandler)).Methods("PUT", "PATCH", "DELETE")
+	r.HandleFunc("/v1/instructors", auth(h.GetAllInstructorsHandler)).Methods("GET")
+	r.HandleFunc("/v1/instructor/{instructor_id}/analytics", auth(h.InstructorAnalyticsHandler)).Methods("GET")
+	r.HandleFunc("/v1/instructor/{instructor_id}/report", auth(h.InstructorReportHandler)).Methods("GET")
 	//course
-	r.HandleFunc("/v1/course", middleware.AuthMiddleware(handlers.CreateCourseHandler)).Methods("POST")
-	r.HandleFunc("/v1/course/{course_id}", middleware.AuthMiddleware(handlers.CourseHandler)).Methods("PUT", "PATCH", "DELETE")
-	r.HandleFunc("/v1/courses", middleware.AuthMiddleware(handlers.GetAllCoursesHandler)).Methods("GET")
+	r.HandleFunc("/v1/course", auth(middleware.IdempotencyMiddleware(h.CreateCourseHandler))).Methods("POST")
+	r.HandleFunc("/v1/course/{course_id}", auth(h.CourseHandler)).Methods("PUT", "PATCH", "DELETE")
+	r.HandleFunc("/v1/courses", auth(h.GetAllCoursesHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/analytics", auth(h.CourseAnalyticsHandler)).Methods("GET")
 	//trace
-	r.HandleFunc("/v1/course/{course_id}/trace", middleware.AuthMiddleware(handlers.TraceHandler)).Methods("POST", "GET")
-	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}", middleware.AuthMiddleware(handlers.TraceEntityHandler)).Methods("GET", "DELETE")
-	r.HandleFunc("/v1/traces", middleware.AuthMiddleware(handlers.GetAllTracesHandler)).Methods("GET")
-	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/pdf", middleware.AuthMiddleware(handlers.DownloadTraceHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace", auth(middleware.IdempotencyMiddleware(h.TraceHandler))).Methods("POST", "GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/uploads", validated(handlers.ResumableUploadOptionsHandler)).Methods("OPTIONS")
+	r.HandleFunc("/v1/course/{course_id}/trace/uploads", auth(middleware.IdempotencyMiddleware(h.CreateResumableUploadHandler))).Methods("POST")
+	r.HandleFunc("/v1/course/{course_id}/trace/uploads/{upload_id}", auth(h.ResumableUploadHandler)).Methods("HEAD", "PATCH", "DELETE")
+	r.HandleFunc("/v1/course/{course_id}/trace/batch", auth(middleware.IdempotencyMiddleware(h.BatchTraceHandler))).Methods("POST")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}", auth(h.TraceEntityHandler)).Methods("GET", "PATCH", "DELETE")
+	r.HandleFunc("/v1/traces", auth(h.GetAllTracesHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/pdf", auth(h.DownloadTraceHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/results", auth(h.GetSurveyResultHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/comments/summary", auth(h.GetCommentSummaryHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/file", auth(h.ReplaceTraceFileHandler)).Methods("PUT")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/versions", auth(h.GetTraceVersionsHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/versions/{version}", auth(h.GetTraceVersionHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/versions/{version}/pdf", auth(h.DownloadTraceVersionHandler)).Methods("GET")
+	r.HandleFunc("/v1/course/{course_id}/trace/{trace_id}/versions/{version}/rollback", auth(middleware.IdempotencyMiddleware(h.RollbackTraceVersionHandler))).Methods("POST")
+	r.HandleFunc("/v1/events", auth(h.TraceEventsHandler)).Methods("GET")
 	// department and semester
-	r.HandleFunc("/v1/departments", middleware.AuthMiddleware(handlers.GetAllDepartmentsHandler)).Methods("GET")
-	r.HandleFunc("/v1/semesters", middleware.AuthMiddleware(handlers.GetAllSemesterTermsHandler)).Methods("GET")
+	r.HandleFunc("/v1/departments", auth(h.GetAllDepartmentsHandler)).Methods("GET")
+	r.HandleFunc("/v1/semesters", auth(h.GetAllSemesterTermsHandler)).Methods("GET")
+	// reports
+	r.HandleFunc("/v1/department/{department_id}/reports", auth(middleware.IdempotencyMiddleware(h.CreateDepartmentReportJobHandler))).Methods("POST")
+	r.HandleFunc("/v1/reports/{job_id}", auth(h.GetReportJobHandler)).Methods("GET")
+	r.HandleFunc("/v1/reports/{job_id}/instructor/{instructor_id}/pdf", auth(h.DownloadReportFileHandler)).Methods("GET")
+	// webhooks
+	r.HandleFunc("/v1/webhooks", auth(middleware.IdempotencyMiddleware(h.CreateWebhookHandler))).Methods("POST")
+	r.HandleFunc("/v1/webhooks", auth(h.ListWebhooksHandler)).Methods("GET")
+	r.HandleFunc("/v1/webhooks/{webhook_id}", auth(h.WebhookHandler)).Methods("GET", "PATCH", "DELETE")
+	r.HandleFunc("/v1/webhooks/{webhook_id}/deliveries", auth(h.ListWebhookDeliveriesHandler)).Methods("GET")
+	r.HandleFunc("/v1/webhooks/{webhook_id}/deliveries/{delivery_id}/replay", auth(middleware.IdempotencyMiddleware(h.ReplayWebhookDeliveryHandler))).Methods("POST")
+
+	// Internal routes, called by other services
+	r.HandleFunc("/internal/v1/trace/{trace_id}/results", internalAuth(h.IngestSurveyResultHandler)).Methods("PUT")
+	r.HandleFunc("/internal/v1/log-level", internalAuth(handlers.LogLevelHandler)).Methods("GET", "PUT")
 
 	return r
 }
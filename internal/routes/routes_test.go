@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-server/internal/handlers"
+	"api-server/internal/openapi"
+	"api-server/internal/repositories/memory"
+)
+
+func TestEveryRouteIsInTheOpenAPIDocument(t *testing.T) {
+	r := RegisterRoutes(handlers.NewHandler(memory.NewStore()))
+	for _, route := range openapi.Spec().MissingRoutes(r) {
+		t.Errorf("%s is not described in internal/openapi/openapi.json", route)
+	}
+}
+
+func TestAPIDocsServeVendoredSwaggerUI(t *testing.T) {
+	r := RegisterRoutes(handlers.NewHandler(memory.NewStore()))
+	cases := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{"/docs", http.StatusMovedPermanently, ""},
+		{"/docs/", http.StatusOK, "text/html"},
+		{"/docs/swagger-ui-bundle.js", http.StatusOK, "text/javascript"},
+		{"/docs/swagger-ui.css", http.StatusOK, "text/css"},
+		{"/docs/favicon-32x32.png", http.StatusOK, "image/png"},
+		{"/docs/README.md", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != c.status {
+			t.Errorf("GET %s: status %d, want %d", c.path, rec.Code, c.status)
+		}
+		if c.contentType != "" && !strings.HasPrefix(rec.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("GET %s: Content-Type %q, want %s", c.path, rec.Header().Get("Content-Type"), c.contentType)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// EICAR test string recognised by every antivirus engine
+const EICARSignature = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// FakeScanner flags content containing the EICAR test string, or Err when it is set.
+// It is meant for tests and local development without a clamd daemon.
+type FakeScanner struct {
+	Err error
+}
+
+// Scan reports content as infected if it contains the EICAR test string
+func (f *FakeScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if f.Err != nil {
+		return Result{}, f.Err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	if bytes.Contains(data, []byte(EICARSignature)) {
+		return Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return Result{}, nil
+}
@@ -0,0 +1,17 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Result describes the outcome of scanning a single upload
+type Result struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Scanner inspects uploaded content for malware before it is stored
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
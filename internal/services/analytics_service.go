@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"api-server/internal/database"
+	"api-server/internal/repositories"
+)
+
+var (
+	analyticsMinResponses = 5
+	analyticsRefresh      = make(chan struct{}, 1)
+	analyticsLock         sync.RWMutex
+)
+
+// Set the response count below which analytics are suppressed
+func SetAnalyticsMinResponses(minResponses int) {
+	analyticsLock.Lock()
+	defer analyticsLock.Unlock()
+	if minResponses > 0 {
+		analyticsMinResponses = minResponses
+	}
+}
+
+// Return the response count below which analytics are suppressed
+func GetAnalyticsMinResponses() int {
+	analyticsLock.RLock()
+	defer analyticsLock.RUnlock()
+	return analyticsMinResponses
+}
+
+// Ask for the analytics view to be refreshed. Requests made while a refresh is
+// pending are coalesced into one.
+func RequestAnalyticsRefresh() {
+	select {
+	case analyticsRefresh <- struct{}{}:
+	default:
+	}
+}
+
+// Start the background loop that refreshes the analytics view on request.
+// The returned function stops the loop and waits for it to exit.
+func StartAnalyticsRefresher() func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-analyticsRefresh:
+				db := database.GetDB()
+				if db == nil {
+					continue
+				}
+				start := time.Now()
+				if err := repositories.RefreshSurveyQuestionStats(context.Background(), db); err != nil {
+					slog.Error("Error refreshing analytics view", "error", err)
+					continue
+				}
+				slog.Debug("Refreshed analytics view", "duration_ms", time.Since(start).Milliseconds())
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"api-server/internal/kafka"
+	"api-server/internal/logging"
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+)
+
+// PublishTraceEvent sends a trace event to the event streams and to the webhooks subscribed
+// to it through store, then to Kafka if a producer is available. Failures are logged and
+// never fail the request.
+func PublishTraceEvent(ctx context.Context, store repositories.Store, message kafka.TraceUploadMessage) {
+	if err := RecordTraceEvent(ctx, store, message); err != nil {
+		logging.FromContext(ctx).Error("Error recording trace event", "event_type", message.EventType, "trace_id", message.TraceID, "error", err)
+	}
+	WakeWebhookDispatcher()
+	SendTraceEventToKafka(ctx, message)
+}
+
+// RecordTraceEvent sends a trace event to the event streams and queues it for the webhooks
+// subscribed to it. Run in a unit of work, neither happens unless the unit of work commits.
+func RecordTraceEvent(ctx context.Context, store repositories.Store, message kafka.TraceUploadMessage) error {
+	if event, ok := traceStreamEvent(message); ok {
+		if err := store.NotifyTraceEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	// webhooks are scoped by the department of the trace's course
+	course, err := store.GetCourseByID(ctx, message.CourseID)
+	if err != nil {
+		return err
+	}
+	return EnqueueWebhookEvent(ctx, store, message.EventType, course.DepartmentID, message)
+}
+
+// SendTraceEventToKafka sends a trace event to Kafka if a producer is available. Failures are logged.
+func SendTraceEventToKafka(ctx context.Context, message kafka.TraceUploadMessage) {
+	producer := GetKafkaProducer()
+	if producer == nil {
+		return
+	}
+	if err := producer.PublishTraceUpload(ctx, message); err != nil {
+		logging.FromContext(ctx).Error("Error publishing to Kafka", "error", err)
+		return
+	}
+	logging.FromContext(ctx).Info("Published trace event to Kafka", "event_type", message.EventType, "trace_id", message.TraceID)
+}
+
+// traceStreamEvent returns the stream event of the creation, deletion and processing state
+// changes of traces, and false for the other trace events
+func traceStreamEvent(message kafka.TraceUploadMessage) (models.TraceStreamEvent, bool) {
+	event := models.TraceStreamEvent{
+		TraceID:    message.TraceID,
+		CourseID:   message.CourseID,
+		Version:    message.Version,
+		OccurredAt: time.Now().UTC(),
+	}
+	switch message.EventType {
+	case kafka.EventTraceUploaded:
+		event.Type = models.TraceStreamCreated
+		event.Status = models.TraceStatusUploaded
+	case kafka.EventTraceFileReplaced:
+		// the new file is processed again
+		event.Type = models.TraceStreamStatusChanged
+		event.Status = models.TraceStatusUploaded
+	case kafka.EventTraceResultsProcessed:
+		event.Type = models.TraceStreamStatusChanged
+		event.Status = models.TraceStatusProcessed
+	case kafka.EventTraceDeleted:
+		event.Type = models.TraceStreamDeleted
+	default:
+		return models.TraceStreamEvent{}, false
+	}
+	return event, true
+}
+
+// PublishCourseEvent sends a course event to Kafka, if a producer is available, and queues
+// it for the webhooks subscribed to it in store. Failures are logged and never fail the request.
+func PublishCourseEvent(ctx context.Context, store repositories.WebhookStore, message kafka.CourseMessage) {
+	if producer := GetKafkaProducer(); producer != nil {
+		if err := producer.PublishCourseEvent(ctx, message); err != nil {
+			logging.FromContext(ctx).Error("Error publishing to Kafka", "error", err)
+		}
+	}
+	if err := EnqueueWebhookEvent(ctx, store, message.EventType, message.DepartmentID, message); err != nil {
+		logging.FromContext(ctx).Error("Error queueing webhooks", "event_type", message.EventType, "course_id", message.CourseID, "error", err)
+		return
+	}
+	WakeWebhookDispatcher()
+}
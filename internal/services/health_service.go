@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"api-server/internal/database"
+	"api-server/internal/lifecycle"
+	"api-server/internal/models"
+	"api-server/internal/utils"
+)
+
+// HealthPolicy holds how readiness checks are run
+type HealthPolicy struct {
+	// CacheTTL is how long a report is reused, so frequent probes do not hammer dependencies
+	CacheTTL time.Duration
+	// CheckTimeout bounds every single check
+	CheckTimeout time.Duration
+}
+
+var (
+	healthPolicy = HealthPolicy{CacheTTL: 5 * time.Second, CheckTimeout: 2 * time.Second}
+	healthReport *models.HealthReport
+	healthLock   sync.Mutex
+)
+
+// Set how readiness checks are run
+func SetHealthPolicy(policy HealthPolicy) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	if policy.CacheTTL >= 0 {
+		healthPolicy.CacheTTL = policy.CacheTTL
+	}
+	if policy.CheckTimeout > 0 {
+		healthPolicy.CheckTimeout = policy.CheckTimeout
+	}
+	healthReport = nil
+}
+
+type healthCheck struct {
+	name     string
+	critical bool
+	// check returns errHealthSkipped when the dependency is not configured
+	check func(ctx context.Context) error
+}
+
+var errHealthSkipped = errors.New("not configured")
+
+var healthChecks = []healthCheck{
+	{name: "database", critical: true, check: checkDatabase},
+	{name: "migrations", critical: true, check: checkMigrations},
+	{name: "kafka", check: checkKafka},
+	{name: "storage", check: checkStorage},
+}
+
+// CheckReadiness reports whether the server can serve requests. The database and its
+// schema are critical; an unreachable Kafka or bucket only degrades the server, as
+// events and uploads fail on their own without affecting other routes.
+func CheckReadiness(ctx context.Context) models.HealthReport {
+	if !lifecycle.Ready() {
+		message := "server is starting or shutting down"
+		return models.HealthReport{
+			Status:    models.HealthUnavailable,
+			CheckedAt: time.Now().UTC(),
+			Checks: map[string]models.HealthCheck{
+				"lifecycle": {Status: models.HealthUnavailable, Critical: true, Error: &message},
+			},
+		}
+	}
+
+	// probes arriving during a check wait for it instead of starting their own
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	if healthReport != nil && time.Since(healthReport.CheckedAt) < healthPolicy.CacheTTL {
+		return *healthReport
+	}
+
+	report := models.HealthReport{
+		Status:    models.HealthOK,
+		CheckedAt: time.Now().UTC(),
+		Checks:    make(map[string]models.HealthCheck, len(healthChecks)),
+	}
+	results := make([]models.HealthCheck, len(healthChecks))
+	var wg sync.WaitGroup
+	for i, check := range healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, check, healthPolicy.CheckTimeout)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range healthChecks {
+		result := results[i]
+		report.Checks[check.name] = result
+		if result.Status != models.HealthUnavailable {
+			continue
+		}
+		if check.critical {
+			report.Status = models.HealthUnavailable
+		} else if report.Status == models.HealthOK {
+			report.Status = models.HealthDegraded
+		}
+	}
+	healthReport = &report
+	return report
+}
+
+func runHealthCheck(ctx context.Context, check healthCheck, timeout time.Duration) models.HealthCheck {
+	// the check is cached for other probes, so it must not end with this probe's request
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.check(ctx)
+	result := models.HealthCheck{
+		Status:     models.HealthOK,
+		Critical:   check.critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	switch {
+	case errors.Is(err, errHealthSkipped):
+		result.Status = models.HealthSkipped
+	case err != nil:
+		message := err.Error()
+		result.Status = models.HealthUnavailable
+		result.Error = &message
+	}
+	return result
+}
+
+func checkDatabase(ctx context.Context) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("database connection unavailable")
+	}
+	return db.PingContext(ctx)
+}
+
+// checkMigrations fails while migrations embedded in the binary are not applied or an
+// applied one was changed. Versions unknown to the binary are expected during a rollout.
+// The status is read without the migration lock, so probes do not queue behind a migration.
+func checkMigrations(ctx context.Context) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("database connection unavailable")
+	}
+	states, err := database.ReadMigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		switch {
+		case state.Modified:
+			return fmt.Errorf("migration %04d_%s was changed after it was applied", state.Version, state.Name)
+		case state.AppliedAt == nil && !state.Unknown:
+			return fmt.Errorf("migration %04d_%s is pending", state.Version, state.Name)
+		}
+	}
+	return nil
+}
+
+func checkKafka(ctx context.Context) error {
+	producer := GetKafkaProducer()
+	if producer == nil {
+		return errHealthSkipped
+	}
+	return producer.Ping(ctx)
+}
+
+func checkStorage(ctx context.Context) error {
+	bucketName := os.Getenv("BUCKET_NAME")
+	if bucketName == "" {
+		return errHealthSkipped
+	}
+	return utils.CheckBucketAccess(ctx, bucketName)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/repositories"
+)
+
+// Start a background loop that deletes expired idempotency keys from store.
+// The returned function stops the loop and waits for it to exit.
+func StartIdempotencyKeyJanitor(store repositories.IdempotencyStore, interval time.Duration) func() {
+	if interval <= 0 {
+		slog.Info("Idempotency key cleanup disabled")
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				CleanupExpiredIdempotencyKeys(context.Background(), store)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// Delete idempotency keys whose responses are no longer replayed
+func CleanupExpiredIdempotencyKeys(ctx context.Context, store repositories.IdempotencyStore) {
+	count, err := store.DeleteExpiredIdempotencyKeys(ctx, time.Now().UTC())
+	if err != nil {
+		logging.FromContext(ctx).Error("Error deleting expired idempotency keys", "error", err)
+		return
+	}
+	if count > 0 {
+		logging.FromContext(ctx).Info("Removed expired idempotency keys", "count", count)
+	}
+}
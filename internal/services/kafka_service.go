@@ -2,7 +2,7 @@ package services
 
 import (
 	"api-server/internal/kafka"
-	"log"
+	"log/slog"
 	"sync"
 )
 
@@ -17,18 +17,18 @@ func InitKafkaProducer(brokers []string, topic, username, password string, enabl
 	defer kafkaProducerLock.Unlock()
 
 	if len(brokers) == 0 {
-		log.Println("No Kafka brokers configured, skipping producer initialization")
+		slog.Info("No Kafka brokers configured, skipping producer initialization")
 		return
 	}
 
 	var err error
 	kafkaProducer, err = kafka.NewProducer(brokers, topic, username, password, enableAuth)
 	if err != nil {
-		log.Printf("Failed to initialize Kafka producer: %v", err)
+		slog.Error("Failed to initialize Kafka producer", "error", err)
 		return
 	}
 
-	log.Printf("Kafka producer initialized successfully for topic: %s", topic)
+	slog.Info("Kafka producer initialized", "topic", topic)
 }
 
 // Return the initialized Kafka producer
@@ -46,9 +46,9 @@ func CloseKafkaProducer() {
 	if kafkaProducer != nil {
 		err := kafkaProducer.Close()
 		if err != nil {
-			log.Printf("Error closing Kafka producer: %v", err)
+			slog.Error("Error closing Kafka producer", "error", err)
 		} else {
-			log.Println("Kafka producer closed successfully")
+			slog.Info("Kafka producer closed")
 		}
 		kafkaProducer = nil
 	}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"api-server/internal/models"
+	"api-server/internal/reports"
+	"api-server/internal/repositories"
+	"api-server/internal/utils"
+)
+
+// ReportPolicy holds how report workers find and hold jobs
+type ReportPolicy struct {
+	// PollInterval is how often workers look for jobs created on other replicas
+	PollInterval time.Duration
+	// Lease is how long a job stays claimed without progress before another worker takes it over
+	Lease time.Duration
+}
+
+var (
+	reportPolicy = ReportPolicy{
+		PollInterval: 5 * time.Second,
+		Lease:        5 * time.Minute,
+	}
+	reportLock sync.RWMutex
+	// reportWake lets a job created on this replica start without waiting for the next poll
+	reportWake = make(chan struct{}, 1)
+)
+
+// WakeReportWorker makes the worker of this replica look for new jobs at once
+func WakeReportWorker() {
+	select {
+	case reportWake <- struct{}{}:
+	default:
+	}
+}
+
+// Start the background worker that generates department reports. Jobs are claimed from
+// the database, so every replica can run one and each job runs on one replica at a time;
+// a job whose worker died is taken over once its lease runs out.
+// The returned function stops the worker and waits for it to exit.
+func StartReportWorker(store repositories.Store, policy ReportPolicy) func() {
+	reportLock.Lock()
+	if policy.PollInterval > 0 {
+		reportPolicy.PollInterval = policy.PollInterval
+	}
+	if policy.Lease > 0 {
+		reportPolicy.Lease = policy.Lease
+	}
+	policy = reportPolicy
+	reportLock.Unlock()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(policy.PollInterval)
+		defer ticker.Stop()
+		for {
+			// run claimed jobs back to back until none is waiting
+			for runNextReportJob(store, policy, done) {
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			case <-reportWake:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// runNextReportJob claims and runs one job, returning false when there was none to run
+// or the worker is stopping
+func runNextReportJob(store repositories.Store, policy ReportPolicy, done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return false
+	default:
+	}
+	job, token, err := store.ClaimReportJob(context.Background(), time.Now().UTC(), policy.Lease)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			slog.Error("Error claiming report job", "error", err)
+		}
+		return false
+	}
+	runReportJob(store, job, token, policy.Lease, done)
+	return true
+}
+
+// runReportJob renders and stores the PDF report of every instructor of a claimed job's
+// department, renewing the claim after each one. A stopped job is released for another
+// worker, and the job is abandoned as soon as another worker holds its claim.
+func runReportJob(store repositories.Store, job *models.ReportJob, token string, lease time.Duration, done <-chan struct{}) {
+	ctx := context.Background()
+	jobID := job.JobID
+	logger := slog.With("job_id", jobID)
+
+	fail := func(err error) {
+		if errors.Is(err, repositories.ErrNotFound) {
+			logger.Warn("Report job was taken over by another worker")
+			return
+		}
+		logger.Error("Report job failed", "error", err)
+		message := err.Error()
+		if err := store.FinishReportJob(ctx, jobID, token, models.ReportJobFailed, &message); err != nil {
+			logger.Error("Error updating report job", "error", err)
+		}
+	}
+
+	instructorIDs, err := store.GetInstructorIDsByDepartmentAndTerm(ctx, job.DepartmentID, job.SemesterTerm)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := store.StartReportJob(ctx, jobID, token, len(instructorIDs)); err != nil {
+		fail(err)
+		return
+	}
+
+	start := time.Now()
+	bucketName := os.Getenv("BUCKET_NAME")
+	failed := 0
+	for _, instructorID := range instructorIDs {
+		select {
+		case <-done:
+			if err := store.ReleaseReportJob(ctx, jobID, token); err != nil {
+				logger.Error("Error releasing report job", "error", err)
+			}
+			logger.Info("Report job interrupted, another worker will start it again")
+			return
+		default:
+		}
+		// without a renewed lease another worker may take the job over at any moment
+		if err := store.RenewReportJobLease(ctx, jobID, token, time.Now().UTC().Add(lease)); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				logger.Warn("Report job was taken over by another worker")
+			} else {
+				logger.Error("Error renewing report job lease, stopping the job", "error", err)
+			}
+			return
+		}
+
+		file := models.ReportFile{JobID: jobID, InstructorID: instructorID}
+		bucketPath, err := generateInstructorReport(ctx, store, instructorID, job.SemesterTerm, jobID, bucketName)
+		if err != nil {
+			logger.Error("Error generating instructor report", "instructor_id", instructorID, "error", err)
+			message := err.Error()
+			file.Error = &message
+			failed++
+		}
+		file.BucketPath = bucketPath
+		file.DateCreated = time.Now().UTC()
+		if err := store.AddReportFile(ctx, token, file); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	status := models.ReportJobCompleted
+	if len(instructorIDs) > 0 && failed == len(instructorIDs) {
+		status = models.ReportJobFailed
+	}
+	if err := store.FinishReportJob(ctx, jobID, token, status, nil); err != nil {
+		logger.Error("Error updating report job", "error", err)
+		return
+	}
+	logger.Info("Report job finished", "reports", len(instructorIDs), "failed", failed, "duration_ms", time.Since(start).Milliseconds())
+}
+
+func generateInstructorReport(ctx context.Context, store repositories.Store, instructorID, semesterTerm, jobID, bucketName string) (string, error) {
+	report, err := reports.LoadInstructorReport(ctx, store, instructorID, semesterTerm, GetAnalyticsMinResponses())
+	if err != nil {
+		return "", err
+	}
+	objectName := fmt.Sprintf("%s/%s.pdf", jobID, instructorID)
+	return utils.UploadReportToGCS(ctx, bytes.NewReader(reports.RenderPDF(report)), objectName, bucketName, "application/pdf")
+}
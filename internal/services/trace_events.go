@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+
+	"github.com/lib/pq"
+)
+
+// TraceEventPolicy holds the limits of the trace event stream
+type TraceEventPolicy struct {
+	// ReplayBufferSize is the number of recent events kept to resume streams from Last-Event-ID
+	ReplayBufferSize int
+	// HeartbeatInterval is how often idle streams get a comment so proxies keep them open
+	HeartbeatInterval time.Duration
+	// ClientBufferSize is the number of events queued per client; slower clients are
+	// disconnected and resume from the replay buffer when they reconnect
+	ClientBufferSize int
+}
+
+// TraceEventSubscription receives the trace events of one stream
+type TraceEventSubscription struct {
+	events   chan models.TraceStreamEvent
+	courseID string
+	closed   bool
+}
+
+// Events returns the events of the subscription. The channel is closed when the
+// client falls behind or the server shuts down.
+func (s *TraceEventSubscription) Events() <-chan models.TraceStreamEvent {
+	return s.events
+}
+
+// traceEventBroker fans the events received from PostgreSQL out to the streams of this replica
+type traceEventBroker struct {
+	lock          sync.Mutex
+	policy        TraceEventPolicy
+	buffer        []models.TraceStreamEvent
+	subscriptions map[*TraceEventSubscription]struct{}
+	// gapID is the last event received before the listener lost its connection
+	gapID  int64
+	lastID int64
+}
+
+var traceEvents = &traceEventBroker{
+	policy: TraceEventPolicy{
+		ReplayBufferSize:  1000,
+		HeartbeatInterval: 15 * time.Second,
+		ClientBufferSize:  64,
+	},
+	subscriptions: map[*TraceEventSubscription]struct{}{},
+}
+
+// GetTraceEventHeartbeat returns how often idle trace event streams get a heartbeat
+func GetTraceEventHeartbeat() time.Duration {
+	traceEvents.lock.Lock()
+	defer traceEvents.lock.Unlock()
+	return traceEvents.policy.HeartbeatInterval
+}
+
+// SubscribeTraceEvents starts a subscription to the trace events of a course, or of every
+// course when courseID is empty. When resume is set, the buffered events after lastEventID
+// are returned to be sent first, and missed reports whether older events are gone.
+func SubscribeTraceEvents(courseID string, lastEventID int64, resume bool) (*TraceEventSubscription, []models.TraceStreamEvent, bool) {
+	b := traceEvents
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	subscription := &TraceEventSubscription{
+		events:   make(chan models.TraceStreamEvent, b.policy.ClientBufferSize),
+		courseID: courseID,
+	}
+	b.subscriptions[subscription] = struct{}{}
+	if !resume {
+		return subscription, nil, false
+	}
+
+	missed := (b.gapID > 0 && lastEventID <= b.gapID) ||
+		(len(b.buffer) > 0 && b.buffer[0].ID > lastEventID+1)
+	var replay []models.TraceStreamEvent
+	for _, event := range b.buffer {
+		if event.ID > lastEventID && subscription.matches(event) {
+			replay = append(replay, event)
+		}
+	}
+	return subscription, replay, missed
+}
+
+// UnsubscribeTraceEvents ends a subscription
+func UnsubscribeTraceEvents(subscription *TraceEventSubscription) {
+	traceEvents.lock.Lock()
+	defer traceEvents.lock.Unlock()
+	traceEvents.close(subscription)
+}
+
+func (s *TraceEventSubscription) matches(event models.TraceStreamEvent) bool {
+	return s.courseID == "" || event.CourseID == "" || s.courseID == event.CourseID
+}
+
+// close must be called with the lock held
+func (b *traceEventBroker) close(subscription *TraceEventSubscription) {
+	if subscription.closed {
+		return
+	}
+	subscription.closed = true
+	close(subscription.events)
+	delete(b.subscriptions, subscription)
+}
+
+func (b *traceEventBroker) broadcast(event models.TraceStreamEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if event.ID > 0 {
+		b.buffer = append(b.buffer, event)
+		if len(b.buffer) > b.policy.ReplayBufferSize {
+			b.buffer = b.buffer[len(b.buffer)-b.policy.ReplayBufferSize:]
+		}
+		if event.ID > b.lastID {
+			b.lastID = event.ID
+		}
+	}
+	for subscription := range b.subscriptions {
+		if !subscription.matches(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			// the client reconnects with Last-Event-ID and catches up from the buffer
+			b.close(subscription)
+		}
+	}
+}
+
+// markGap records that notifications may have been missed while the listener reconnected
+// and tells the connected clients to fetch the traces again
+func (b *traceEventBroker) markGap() {
+	b.lock.Lock()
+	b.gapID = b.lastID
+	b.lock.Unlock()
+	b.broadcast(models.TraceStreamEvent{Type: models.TraceStreamReset, OccurredAt: time.Now().UTC()})
+}
+
+func (b *traceEventBroker) closeAll() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for subscription := range b.subscriptions {
+		b.close(subscription)
+	}
+}
+
+// CloseTraceEventStreams ends every open stream so their requests complete, clients
+// reconnect to another replica with Last-Event-ID
+func CloseTraceEventStreams() {
+	traceEvents.closeAll()
+}
+
+// traceEventReopenDelay is how long the listener waits before it fetches the password
+// again after the database rejected the previous one
+const traceEventReopenDelay = 5 * time.Second
+
+// Start listening for trace events on PostgreSQL so streams on every replica receive them.
+// connStr returns the connection string with the current database password. The listener
+// reconnects with the same string after a dropped connection, and is replaced with one
+// using a fresh string when the database rejects its password.
+// The returned function stops listening, ends every open stream and waits for the loop to exit.
+func StartTraceEventListener(connStr func(ctx context.Context) (string, error), policy TraceEventPolicy) func() {
+	traceEvents.lock.Lock()
+	if policy.ReplayBufferSize > 0 {
+		traceEvents.policy.ReplayBufferSize = policy.ReplayBufferSize
+	}
+	if policy.HeartbeatInterval > 0 {
+		traceEvents.policy.HeartbeatInterval = policy.HeartbeatInterval
+	}
+	if policy.ClientBufferSize > 0 {
+		traceEvents.policy.ClientBufferSize = policy.ClientBufferSize
+	}
+	traceEvents.lock.Unlock()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		listener := openTraceEventListener(connStr)
+		defer func() { listener.close() }()
+		// pings detect connections that were dropped without an error
+		ticker := time.NewTicker(90 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-listener.reopen:
+				listener.close()
+				select {
+				case <-done:
+					return
+				case <-time.After(traceEventReopenDelay):
+				}
+				listener = openTraceEventListener(connStr)
+				traceEvents.markGap()
+			case notification := <-listener.notify():
+				if notification == nil {
+					// the listener reconnected
+					traceEvents.markGap()
+					continue
+				}
+				var event models.TraceStreamEvent
+				if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+					slog.Error("Error decoding trace event", "error", err)
+					continue
+				}
+				traceEvents.broadcast(event)
+			case <-ticker.C:
+				if listener.Listener != nil {
+					go listener.Ping()
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			traceEvents.closeAll()
+		})
+	}
+}
+
+// traceEventListener is a pq.Listener with the channel that asks for it to be replaced
+type traceEventListener struct {
+	*pq.Listener
+	reopen chan struct{}
+	closed atomic.Bool
+}
+
+// openTraceEventListener starts a listener on a connection string fetched from connStr.
+// When the string cannot be fetched, the Listener is nil and reopen fires at once.
+func openTraceEventListener(connStr func(ctx context.Context) (string, error)) *traceEventListener {
+	l := &traceEventListener{reopen: make(chan struct{}, 1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	name, err := connStr(ctx)
+	cancel()
+	if err != nil {
+		slog.Error("Error fetching the database password of the trace event listener", "error", err)
+		l.reopen <- struct{}{}
+		return l
+	}
+
+	l.Listener = pq.NewListener(name, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err == nil {
+			return
+		}
+		slog.Warn("Trace event listener", "error", err)
+		// retrying the same password is pointless once the database rejected it
+		var pqErr *pq.Error
+		if event == pq.ListenerEventConnectionAttemptFailed && errors.As(err, &pqErr) && pqErr.Code.Class() == "28" {
+			select {
+			case l.reopen <- struct{}{}:
+			default:
+			}
+		}
+	})
+	// Listen waits for the first connection, reconnections listen again by themselves
+	go func() {
+		if err := l.Listen(repositories.TraceEventChannel); err != nil && !l.closed.Load() {
+			slog.Error("Error listening for trace events", "error", err)
+		}
+	}()
+	return l
+}
+
+// notify returns the notifications of the listener, nil while there is none
+func (l *traceEventListener) notify() <-chan *pq.Notification {
+	if l.Listener == nil {
+		return nil
+	}
+	return l.Notify
+}
+
+func (l *traceEventListener) close() {
+	if l.Listener != nil && l.closed.CompareAndSwap(false, true) {
+		l.Listener.Close()
+	}
+}
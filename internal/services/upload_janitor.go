@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"api-server/internal/logging"
+	"api-server/internal/repositories"
+	"api-server/internal/utils"
+)
+
+// Start a background loop that deletes expired upload sessions and their staged chunks.
+// The returned function stops the loop and waits for it to exit.
+func StartUploadSessionJanitor(store repositories.UploadStore, interval time.Duration) func() {
+	if interval <= 0 {
+		slog.Info("Upload session cleanup disabled")
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				CleanupExpiredUploadSessions(context.Background(), store)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// Delete expired upload sessions, then the chunks they still had staged in GCS. An
+// assembling session is given another upload TTL to finish before it counts as expired.
+func CleanupExpiredUploadSessions(ctx context.Context, store repositories.UploadStore) {
+	now := time.Now().UTC()
+	count, chunks, err := store.DeleteExpiredUploadSessions(ctx, now, now.Add(-GetUploadPolicy().ResumableTTL))
+	if err != nil {
+		logging.FromContext(ctx).Error("Error deleting expired upload sessions", "error", err)
+		return
+	}
+	if count == 0 {
+		return
+	}
+
+	bucketName := os.Getenv("BUCKET_NAME")
+	for _, chunk := range chunks {
+		if err := utils.DeleteFileFromGCS(ctx, chunk.BucketPath, bucketName); err != nil {
+			logging.FromContext(ctx).Error("Error deleting chunk", "upload_id", chunk.UploadID, "bucket_path", chunk.BucketPath, "error", err)
+		}
+	}
+	logging.FromContext(ctx).Info("Removed expired upload sessions", "count", count)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"api-server/internal/scanner"
+)
+
+// UploadPolicy holds the rules applied to every uploaded trace file
+type UploadPolicy struct {
+	AllowedTypes     []string
+	MaxPDFPages      int
+	QuarantinePrefix string
+
+	// Limits for batch uploads
+	BatchConcurrency int
+	BatchMaxFiles    int
+	BatchMaxBytes    int64
+
+	// Limits for resumable uploads
+	ResumableMaxBytes      int64
+	ResumableChunkMaxBytes int64
+	ResumableTTL           time.Duration
+}
+
+var (
+	uploadPolicy = UploadPolicy{
+		AllowedTypes:     []string{"application/pdf"},
+		MaxPDFPages:      50,
+		QuarantinePrefix: "quarantine",
+		BatchConcurrency: 4,
+		BatchMaxFiles:    100,
+		BatchMaxBytes:    200 << 20,
+
+		ResumableMaxBytes:      500 << 20,
+		ResumableChunkMaxBytes: 16 << 20,
+		ResumableTTL:           24 * time.Hour,
+	}
+	uploadScanner scanner.Scanner
+	uploadLock    sync.RWMutex
+)
+
+// Initialize the upload policy and, if an address is configured, the clamd scanner
+func InitUploadValidation(policy UploadPolicy, clamavAddress string) {
+	uploadLock.Lock()
+	defer uploadLock.Unlock()
+
+	types := []string{}
+	for _, t := range policy.AllowedTypes {
+		if t = strings.TrimSpace(strings.ToLower(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	if len(types) > 0 {
+		uploadPolicy.AllowedTypes = types
+	}
+	if policy.MaxPDFPages > 0 {
+		uploadPolicy.MaxPDFPages = policy.MaxPDFPages
+	}
+	if policy.QuarantinePrefix != "" {
+		uploadPolicy.QuarantinePrefix = strings.Trim(policy.QuarantinePrefix, "/")
+	}
+	if policy.BatchConcurrency > 0 {
+		uploadPolicy.BatchConcurrency = policy.BatchConcurrency
+	}
+	if policy.BatchMaxFiles > 0 {
+		uploadPolicy.BatchMaxFiles = policy.BatchMaxFiles
+	}
+	if policy.BatchMaxBytes > 0 {
+		uploadPolicy.BatchMaxBytes = policy.BatchMaxBytes
+	}
+	if policy.ResumableMaxBytes > 0 {
+		uploadPolicy.ResumableMaxBytes = policy.ResumableMaxBytes
+	}
+	if policy.ResumableChunkMaxBytes > 0 {
+		uploadPolicy.ResumableChunkMaxBytes = policy.ResumableChunkMaxBytes
+	}
+	if policy.ResumableTTL > 0 {
+		uploadPolicy.ResumableTTL = policy.ResumableTTL
+	}
+
+	if clamavAddress == "" {
+		slog.Info("No ClamAV address configured, skipping malware scanning")
+		return
+	}
+	uploadScanner = scanner.NewClamdScanner(clamavAddress, 30*time.Second)
+	slog.Info("Malware scanning enabled", "clamd_address", clamavAddress)
+}
+
+// Return the current upload policy
+func GetUploadPolicy() UploadPolicy {
+	uploadLock.RLock()
+	defer uploadLock.RUnlock()
+	return uploadPolicy
+}
+
+// Return the configured malware scanner, nil when scanning is disabled
+func GetScanner() scanner.Scanner {
+	uploadLock.RLock()
+	defer uploadLock.RUnlock()
+	return uploadScanner
+}
+
+// Replace the malware scanner, used to plug in scanner.FakeScanner in tests
+func SetScanner(s scanner.Scanner) {
+	uploadLock.Lock()
+	defer uploadLock.Unlock()
+	uploadScanner = s
+}
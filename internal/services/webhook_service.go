@@ -0,0 +1,324 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"api-server/internal/models"
+	"api-server/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookPolicy holds the retry and timeout rules of webhook deliveries
+type WebhookPolicy struct {
+	Timeout      time.Duration
+	PollInterval time.Duration
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// DisableAfter is the number of consecutive failed attempts after which a subscription is disabled
+	DisableAfter int
+	// AllowInsecure lets webhooks use http:// and private, loopback and link-local
+	// addresses, for local development
+	AllowInsecure bool
+}
+
+// webhookBatchSize is the number of due deliveries claimed per poll
+const webhookBatchSize = 50
+
+var (
+	webhookPolicy = WebhookPolicy{
+		Timeout:      10 * time.Second,
+		PollInterval: 10 * time.Second,
+		MaxAttempts:  8,
+		BackoffBase:  30 * time.Second,
+		BackoffMax:   time.Hour,
+		DisableAfter: 20,
+	}
+	webhookLock sync.RWMutex
+	// webhookWake lets new events be delivered without waiting for the next poll
+	webhookWake = make(chan struct{}, 1)
+)
+
+// GenerateWebhookSecret returns a new random signing secret
+func GenerateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// SignWebhookPayload returns the signature header of a payload sent at timestamp: the hex
+// HMAC-SHA256 of "<timestamp>.<payload>" keyed with the subscription secret
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// EnqueueWebhookEvent queues a delivery of an event in store to every active subscription
+// of its type whose department scope matches. Call WakeWebhookDispatcher once the deliveries
+// are committed to send them without waiting for the next poll.
+func EnqueueWebhookEvent(ctx context.Context, store repositories.WebhookStore, eventType string, departmentID int, data interface{}) error {
+	webhooks, err := store.GetWebhooksForEvent(ctx, eventType, departmentID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	eventID := uuid.New().String()
+	payload, err := json.Marshal(models.WebhookEvent{
+		EventID:      eventID,
+		EventType:    eventType,
+		DepartmentID: departmentID,
+		OccurredAt:   now,
+		Data:         encoded,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(webhooks))
+	for i, webhook := range webhooks {
+		deliveries[i] = newWebhookDelivery(webhook.WebhookID, eventID, eventType, payload, now)
+	}
+	return store.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// ReplayWebhookDelivery queues the event of an earlier delivery again as a new delivery in store
+func ReplayWebhookDelivery(ctx context.Context, store repositories.WebhookStore, original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	delivery := newWebhookDelivery(original.WebhookID, original.EventID, original.EventType, original.Payload, time.Now().UTC())
+	if err := store.CreateWebhookDeliveries(ctx, []models.WebhookDelivery{delivery}); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	WakeWebhookDispatcher()
+	return delivery, nil
+}
+
+func newWebhookDelivery(webhookID, eventID, eventType string, payload []byte, now time.Time) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		DeliveryID:    uuid.New().String(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+		DateCreated:   now,
+	}
+}
+
+// WakeWebhookDispatcher makes the dispatcher of this replica send due deliveries at once
+func WakeWebhookDispatcher() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// Start the background loop that sends the due webhook deliveries in store, retrying failed
+// ones with exponential backoff. The returned function stops the loop and waits for it to exit.
+func StartWebhookDispatcher(store repositories.WebhookStore, policy WebhookPolicy) func() {
+	webhookLock.Lock()
+	if policy.Timeout > 0 {
+		webhookPolicy.Timeout = policy.Timeout
+	}
+	if policy.PollInterval > 0 {
+		webhookPolicy.PollInterval = policy.PollInterval
+	}
+	if policy.MaxAttempts > 0 {
+		webhookPolicy.MaxAttempts = policy.MaxAttempts
+	}
+	if policy.BackoffBase > 0 {
+		webhookPolicy.BackoffBase = policy.BackoffBase
+	}
+	if policy.BackoffMax > 0 {
+		webhookPolicy.BackoffMax = policy.BackoffMax
+	}
+	if policy.DisableAfter > 0 {
+		webhookPolicy.DisableAfter = policy.DisableAfter
+	}
+	webhookPolicy.AllowInsecure = policy.AllowInsecure
+	interval := webhookPolicy.PollInterval
+	webhookLock.Unlock()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			case <-webhookWake:
+			}
+			dispatchDueWebhooks(store, done)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// dispatchDueWebhooks sends the deliveries that are due, stopping early when done is closed
+func dispatchDueWebhooks(store repositories.WebhookStore, done <-chan struct{}) {
+	ctx := context.Background()
+	webhookLock.RLock()
+	policy := webhookPolicy
+	webhookLock.RUnlock()
+
+	// the lease covers the request timeout so a slow endpoint is not sent the same delivery twice
+	deliveries, err := store.ClaimDueWebhookDeliveries(ctx, time.Now().UTC(), 2*policy.Timeout+time.Minute, webhookBatchSize)
+	if err != nil {
+		slog.Error("Error claiming webhook deliveries", "error", err)
+		return
+	}
+
+	client := newWebhookClient(policy)
+	defer client.CloseIdleConnections()
+	webhooks := map[string]*models.WebhookSubscription{}
+	for _, delivery := range deliveries {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = store.GetWebhook(ctx, delivery.WebhookID)
+			if err != nil {
+				slog.Error("Error fetching webhook", "webhook_id", delivery.WebhookID, "error", err)
+				continue
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+		if !webhook.Active {
+			// disabled while this batch was being sent, the delivery resumes once re-enabled
+			continue
+		}
+		deliverWebhook(ctx, store, client, policy, webhook, delivery)
+	}
+}
+
+// deliverWebhook makes one attempt at a delivery and records the outcome
+func deliverWebhook(ctx context.Context, store repositories.WebhookStore, client *http.Client, policy WebhookPolicy, webhook *models.WebhookSubscription, delivery models.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+
+	statusCode, err := postWebhook(ctx, client, policy, webhook, delivery, now)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+	if err == nil {
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.LastError = nil
+		delivery.DateDelivered = &now
+		if err := store.RecordWebhookSuccess(ctx, webhook.WebhookID); err != nil {
+			slog.Error("Error resetting webhook failures", "webhook_id", webhook.WebhookID, "error", err)
+		}
+	} else {
+		message := err.Error()
+		delivery.LastError = &message
+		if delivery.Attempts >= policy.MaxAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			next := now.Add(webhookBackoff(delivery.Attempts, policy.BackoffBase, policy.BackoffMax))
+			delivery.NextAttemptAt = &next
+		}
+		reason := fmt.Sprintf("disabled after %d consecutive failed deliveries", policy.DisableAfter)
+		disabled, err := store.RecordWebhookFailure(ctx, webhook.WebhookID, policy.DisableAfter, reason)
+		if err != nil {
+			slog.Error("Error recording webhook failure", "webhook_id", webhook.WebhookID, "error", err)
+		}
+		if disabled && webhook.Active {
+			webhook.Active = false
+			slog.Warn("Disabled webhook after consecutive failed deliveries", "webhook_id", webhook.WebhookID, "failures", policy.DisableAfter)
+		}
+	}
+
+	if err := store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		slog.Error("Error updating webhook delivery", "webhook_id", webhook.WebhookID, "delivery_id", delivery.DeliveryID, "error", err)
+	}
+}
+
+// postWebhook sends a signed delivery and returns the response status, with an error unless it is 2xx
+func postWebhook(ctx context.Context, client *http.Client, policy WebhookPolicy, webhook *models.WebhookSubscription, delivery models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	// subscriptions created while insecure URLs were allowed are not sent over plain http
+	if req.URL.Scheme != "https" && !policy.AllowInsecure {
+		return 0, fmt.Errorf("%w: url must use https", ErrWebhookTargetNotAllowed)
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "askTRACE-Webhooks/1.0")
+	req.Header.Set(WebhookIDHeader, delivery.DeliveryID)
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff doubles the wait after every failed attempt, up to max
+func webhookBackoff(attempt int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= max {
+			return max
+		}
+	}
+	return wait
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookTargetNotAllowed is returned for webhook URLs that point into the private network
+var ErrWebhookTargetNotAllowed = errors.New("webhook target is not allowed")
+
+// nonPublicPrefixes are ranges that are not reachable on the public internet besides the
+// private, loopback, link-local, multicast and unspecified ranges that net/netip knows about
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddr reports whether addr may be sent webhook deliveries
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookURL rejects webhook URLs that are not https or whose host resolves to a
+// private, loopback or link-local address. Both are allowed when the dispatcher runs with
+// AllowInsecure, for local development.
+func CheckWebhookURL(ctx context.Context, rawURL string) error {
+	webhookLock.RLock()
+	allowInsecure := webhookPolicy.AllowInsecure
+	webhookLock.RUnlock()
+	if allowInsecure {
+		return nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("%w: url must use https", ErrWebhookTargetNotAllowed)
+	}
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isPublicAddr(addr) {
+			return fmt.Errorf("%w: url must not point to a private, loopback or link-local address", ErrWebhookTargetNotAllowed)
+		}
+		return nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(lookupCtx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: host %s could not be resolved", ErrWebhookTargetNotAllowed, host)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return fmt.Errorf("%w: host %s resolves to a private, loopback or link-local address", ErrWebhookTargetNotAllowed, host)
+		}
+	}
+	return nil
+}
+
+// webhookDialControl runs after the host has been resolved and before connecting, so a
+// host that resolved to a public address when the webhook was created cannot be pointed
+// at the private network later
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s is a private, loopback or link-local address", ErrWebhookTargetNotAllowed, addrPort.Addr())
+	}
+	return nil
+}
+
+// newWebhookClient returns the client deliveries are sent with. Redirects are reported as
+// failures instead of being followed to another host, and unless AllowInsecure is set
+// connections to non-public addresses are refused.
+func newWebhookClient(policy WebhookPolicy) *http.Client {
+	dialer := &net.Dialer{Timeout: policy.Timeout}
+	if !policy.AllowInsecure {
+		dialer.Control = webhookDialControl
+	}
+	return &http.Client{
+		Timeout: policy.Timeout,
+		Transport: &http.Transport{
+			// no proxy, the dialer has to see the address of the endpoint itself
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   policy.Timeout,
+			ResponseHeaderTimeout: policy.Timeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
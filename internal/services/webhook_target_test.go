@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:10.0.0.1":  false,
+	}
+	for address, want := range cases {
+		if got := isPublicAddr(netip.MustParseAddr(address)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	ctx := context.Background()
+	rejected := []string{
+		"http://93.184.216.34/hook",
+		"https://127.0.0.1/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]:8443/hook",
+		"https://localhost/hook",
+	}
+	for _, url := range rejected {
+		if err := CheckWebhookURL(ctx, url); !errors.Is(err, ErrWebhookTargetNotAllowed) {
+			t.Errorf("CheckWebhookURL(%s) = %v, want ErrWebhookTargetNotAllowed", url, err)
+		}
+	}
+	if err := CheckWebhookURL(ctx, "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("CheckWebhookURL of a public address: %v", err)
+	}
+
+	webhookLock.Lock()
+	webhookPolicy.AllowInsecure = true
+	webhookLock.Unlock()
+	defer func() {
+		webhookLock.Lock()
+		webhookPolicy.AllowInsecure = false
+		webhookLock.Unlock()
+	}()
+	if err := CheckWebhookURL(ctx, "http://localhost:8080/hook"); err != nil {
+		t.Errorf("CheckWebhookURL with AllowInsecure: %v", err)
+	}
+}
+
+func TestWebhookDialControl(t *testing.T) {
+	if err := webhookDialControl("tcp4", "10.0.0.5:443", nil); !errors.Is(err, ErrWebhookTargetNotAllowed) {
+		t.Errorf("dialing a private address: %v", err)
+	}
+	if err := webhookDialControl("tcp6", "[::1]:443", nil); !errors.Is(err, ErrWebhookTargetNotAllowed) {
+		t.Errorf("dialing loopback: %v", err)
+	}
+	if err := webhookDialControl("tcp4", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("dialing a public address: %v", err)
+	}
+}
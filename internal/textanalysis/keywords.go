@@ -0,0 +1,110 @@
+package textanalysis
+
+import (
+	"sort"
+	"strings"
+
+	"api-server/internal/models"
+)
+
+// contentTokens drops stopwords, numbers, placeholders and very short words
+func contentTokens(text string) []string {
+	tokens := []string{}
+	for _, token := range Tokenize(text) {
+		if len(token) < 3 || stopwords[token] || isPlaceholder(token) || isNumeric(token) {
+			continue
+		}
+		tokens = append(tokens, stem(token))
+	}
+	return tokens
+}
+
+// ExtractKeywords counts the comments each keyword appears in and returns the top n
+func ExtractKeywords(comments []string, n int) []models.TermCount {
+	counts := map[string]int{}
+	for _, comment := range comments {
+		for token := range uniqueTokens(contentTokens(comment)) {
+			counts[token]++
+		}
+	}
+	return topTerms(counts, n, 1)
+}
+
+// ExtractPhrases counts the comments each two word phrase appears in and returns the
+// top n phrases that occur in at least two comments
+func ExtractPhrases(comments []string, n int) []models.TermCount {
+	counts := map[string]int{}
+	for _, comment := range comments {
+		seen := map[string]bool{}
+		// phrases should not span sentences
+		for _, sentence := range splitSentences(comment) {
+			tokens := contentTokens(sentence)
+			for i := 0; i+1 < len(tokens); i++ {
+				if tokens[i] == tokens[i+1] {
+					continue
+				}
+				phrase := tokens[i] + " " + tokens[i+1]
+				if !seen[phrase] {
+					seen[phrase] = true
+					counts[phrase]++
+				}
+			}
+		}
+	}
+	return topTerms(counts, n, 2)
+}
+
+func topTerms(counts map[string]int, n, minCount int) []models.TermCount {
+	terms := []models.TermCount{}
+	for term, count := range counts {
+		if count >= minCount {
+			terms = append(terms, models.TermCount{Term: term, Count: count})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if n > 0 && len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+func uniqueTokens(tokens []string) map[string]bool {
+	unique := map[string]bool{}
+	for _, token := range tokens {
+		unique[token] = true
+	}
+	return unique
+}
+
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?' || r == ';' || r == '\n'
+	})
+}
+
+func isNumeric(token string) bool {
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// stem folds simple plurals so "assignments" and "assignment" count together
+func stem(token string) string {
+	switch {
+	case strings.HasSuffix(token, "ies") && len(token) > 4:
+		return token[:len(token)-3] + "y"
+	case strings.HasSuffix(token, "sses"):
+		return token[:len(token)-2]
+	case strings.HasSuffix(token, "s") && !strings.HasSuffix(token, "ss") && !strings.HasSuffix(token, "us") && !strings.HasSuffix(token, "is") && len(token) > 3:
+		return token[:len(token)-1]
+	}
+	return token
+}
@@ -0,0 +1,60 @@
+package textanalysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenizeKeepsPlaceholdersApartFromWords(t *testing.T) {
+	got := Tokenize(Redact("Email me at ada@example.com, my name is on the [sic] list", nil))
+	want := []string{"email", "me", "at", "[email]", "my", "name", "is", "on", "the", "sic", "list"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestKeywordsDropPlaceholdersButNotTheWords(t *testing.T) {
+	comments := []string{
+		"The professor answered every email quickly, reach her at prof@example.edu",
+		"Grading by email was slow but fair, call 555-123-4567",
+		"Put your name and student id 12345678 on every phone photo of the homework",
+	}
+	for i, comment := range comments {
+		comments[i] = Redact(comment, nil)
+	}
+	keywords := ExtractKeywords(comments, 0)
+	terms := map[string]int{}
+	for _, keyword := range keywords {
+		terms[keyword.Term] = keyword.Count
+	}
+	if terms["email"] != 2 {
+		t.Errorf("email counted in %d comments, want 2: %+v", terms["email"], keywords)
+	}
+	for _, word := range []string{"name", "phone"} {
+		if terms[word] != 1 {
+			t.Errorf("%s counted in %d comments, want 1: %+v", word, terms[word], keywords)
+		}
+	}
+	for term := range terms {
+		if isPlaceholder(term) || term[0] == '[' {
+			t.Errorf("placeholder %q among the keywords", term)
+		}
+	}
+}
+
+func TestRedactNamesAfterCuesOnly(t *testing.T) {
+	tests := []struct {
+		comment string
+		known   []string
+		want    string
+	}{
+		{"My partner John Smith did most of the work", nil, "My partner [NAME] did most of the work"},
+		{"Worked with Python and Java all term", nil, "Worked with Python and Java all term"},
+		{"Office hours with Priya helped", []string{"priya"}, "Office hours with [NAME] helped"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.comment, tt.known); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.comment, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package textanalysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Placeholders substituted for personal information
+const (
+	RedactedEmail = "[EMAIL]"
+	RedactedPhone = "[PHONE]"
+	RedactedID    = "[ID]"
+	RedactedName  = "[NAME]"
+)
+
+var (
+	emailPattern     = regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`)
+	phonePattern     = regexp.MustCompile(`(?:\+?1[\s.-]?)?\(?\b\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`)
+	studentIDPattern = regexp.MustCompile(`\b\d{7,10}\b`)
+	mentionPattern   = regexp.MustCompile(`@[A-Za-z][\w.]*`)
+	// a capitalised name following a word that usually introduces a person,
+	// e.g. "my partner John Smith" or "thanks to Priya"
+	namePattern = regexp.MustCompile(`(?i:\b(?:classmate|classmates|partner|teammate|teammates|student|students|friend|roommate|thanks to|thank you|name is|named|called|ta|tas)\s+)((?:[A-Z][a-z'’-]+)(?:\s+[A-Z][a-z'’-]+)?)`)
+)
+
+// words that follow name cues but are not names
+var notNames = map[string]bool{
+	"the": true, "i": true, "my": true, "professor": true, "prof": true, "dr": true,
+	"a": true, "an": true, "this": true, "that": true, "it": true, "office": true,
+	"course": true, "class": true, "lab": true, "labs": true, "lecture": true,
+}
+
+// Redact replaces email addresses, phone numbers, student IDs, @mentions and likely
+// student names with placeholders. knownNames are always redacted, case-insensitively.
+func Redact(text string, knownNames []string) string {
+	text = emailPattern.ReplaceAllString(text, RedactedEmail)
+	text = phonePattern.ReplaceAllString(text, RedactedPhone)
+	text = studentIDPattern.ReplaceAllString(text, RedactedID)
+	text = mentionPattern.ReplaceAllString(text, RedactedName)
+
+	text = namePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := namePattern.FindStringSubmatchIndex(match)
+		if groups == nil {
+			return match
+		}
+		name := match[groups[2]:groups[3]]
+		if notNames[strings.ToLower(strings.Fields(name)[0])] {
+			return match
+		}
+		return match[:groups[2]] + RedactedName + match[groups[3]:]
+	})
+
+	for _, name := range knownNames {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		pattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+		text = pattern.ReplaceAllString(text, RedactedName)
+	}
+	return text
+}
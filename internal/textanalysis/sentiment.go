@@ -0,0 +1,112 @@
+package textanalysis
+
+import (
+	"math"
+	"strings"
+)
+
+// Sentiment labels
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+)
+
+// scores within this distance of zero are labelled neutral
+const neutralThreshold = 0.05
+
+// normalisation constant from VADER, maps raw sums onto (-1, 1)
+const normalizationAlpha = 15.0
+
+// lexicon of word valences on a -4..4 scale, tuned for course feedback
+var lexicon = map[string]float64{}
+
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "nothing": true, "neither": true, "nor": true, "without": true,
+	"isn't": true, "wasn't": true, "aren't": true, "weren't": true, "don't": true, "doesn't": true, "didn't": true,
+	"can't": true, "cannot": true, "couldn't": true, "won't": true, "wouldn't": true, "shouldn't": true, "hardly": true,
+}
+
+var intensifiers = map[string]float64{
+	"very": 1.3, "really": 1.3, "extremely": 1.5, "incredibly": 1.5, "super": 1.3, "so": 1.2, "truly": 1.3,
+	"highly": 1.3, "absolutely": 1.5, "totally": 1.3, "quite": 1.1, "somewhat": 0.7, "slightly": 0.6,
+	"kind": 0.8, "bit": 0.7, "too": 1.2,
+}
+
+func init() {
+	groups := map[float64]string{
+		4: `amazing awesome excellent exceptional fantastic outstanding phenomenal superb wonderful brilliant best`,
+		3: `great love loved enjoyable enjoyed engaging inspiring passionate fun favorite recommend recommended knowledgeable`,
+		2: `good helpful clear organized interesting fair useful patient approachable supportive responsive kind
+			friendly valuable insightful effective caring enthusiastic informative thorough prepared respectful learned
+			easy understandable accessible motivating encouraging appreciated appreciate thanks thank`,
+		1:  `nice fine okay ok decent reasonable adequate manageable relevant available structured like liked`,
+		-1: `long fast slow hard difficult dry repetitive heavy late unclear vague strict picky tough`,
+		-2: `bad boring confusing confused disorganized unfair unhelpful rude stressful frustrating frustrated poorly
+			poor useless overwhelming unprepared unresponsive lacking inconsistent dull tedious annoying waste`,
+		-3: `terrible awful horrible hate hated worst disrespectful condescending dismissive miserable impossible`,
+	}
+	for score, words := range groups {
+		for _, word := range strings.Fields(words) {
+			lexicon[word] = score
+		}
+	}
+}
+
+// SentimentScore scores text from -1 (negative) to 1 (positive) using the lexicon.
+// Negations within three words flip and dampen a valence, intensifiers scale it.
+func SentimentScore(text string) float64 {
+	tokens := Tokenize(text)
+	sum := 0.0
+	for i, token := range tokens {
+		valence, ok := lexicon[token]
+		if !ok {
+			continue
+		}
+		for back := 1; back <= 3 && i-back >= 0; back++ {
+			previous := tokens[i-back]
+			if factor, ok := intensifiers[previous]; ok && back == 1 {
+				valence *= factor
+			}
+			if negations[previous] {
+				valence *= -0.74
+				break
+			}
+		}
+		sum += valence
+	}
+	// "but" shifts the weight to the clause that follows it
+	if i := indexOf(tokens, "but"); i >= 0 {
+		before := SentimentScore(strings.Join(tokens[:i], " "))
+		after := SentimentScore(strings.Join(tokens[i+1:], " "))
+		if before != 0 || after != 0 {
+			return clamp(0.3*before + 0.7*after)
+		}
+	}
+	return sum / math.Sqrt(sum*sum+normalizationAlpha)
+}
+
+// SentimentLabel maps a score onto positive, neutral or negative
+func SentimentLabel(score float64) string {
+	switch {
+	case score >= neutralThreshold:
+		return SentimentPositive
+	case score <= -neutralThreshold:
+		return SentimentNegative
+	default:
+		return SentimentNeutral
+	}
+}
+
+func indexOf(tokens []string, word string) int {
+	for i, token := range tokens {
+		if token == word {
+			return i
+		}
+	}
+	return -1
+}
+
+func clamp(score float64) float64 {
+	return math.Max(-1, math.Min(1, score))
+}
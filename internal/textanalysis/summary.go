@@ -0,0 +1,118 @@
+package textanalysis
+
+import (
+	"math"
+	"sort"
+
+	"api-server/internal/models"
+)
+
+// Options tune Summarize
+type Options struct {
+	// names that must always be redacted, e.g. a class roster
+	KnownNames  []string
+	MaxKeywords int
+	MaxPhrases  int
+	MaxThemes   int
+}
+
+// DefaultOptions are used by the comment summary endpoint
+var DefaultOptions = Options{
+	MaxKeywords: 15,
+	MaxPhrases:  10,
+	MaxThemes:   5,
+}
+
+// Summarize redacts the comments and then scores their sentiment, extracts keywords and
+// phrases, and clusters them into themes. Nothing in the summary contains unredacted text.
+func Summarize(comments []string, opts Options) models.CommentSummary {
+	redacted := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		redacted = append(redacted, Redact(comment, opts.KnownNames))
+	}
+
+	scores := make([]float64, len(redacted))
+	summary := models.CommentSummary{
+		CommentCount: len(redacted),
+		Keywords:     ExtractKeywords(redacted, opts.MaxKeywords),
+		Phrases:      ExtractPhrases(redacted, opts.MaxPhrases),
+	}
+
+	total := 0.0
+	for i, comment := range redacted {
+		scores[i] = SentimentScore(comment)
+		total += scores[i]
+		switch SentimentLabel(scores[i]) {
+		case SentimentPositive:
+			summary.Sentiment.Positive++
+		case SentimentNegative:
+			summary.Sentiment.Negative++
+		default:
+			summary.Sentiment.Neutral++
+		}
+	}
+	if len(redacted) > 0 {
+		summary.Sentiment.AverageScore = round3(total / float64(len(redacted)))
+	}
+
+	summary.Themes = ClusterThemes(redacted, scores, opts.MaxThemes)
+	return summary
+}
+
+// ClusterThemes groups comments around their most frequent keywords. Each theme is seeded
+// by the most common keyword not yet covered and extended with keywords that co-occur with
+// it in at least half of its comments; comments join the first theme they share a keyword with.
+func ClusterThemes(comments []string, scores []float64, maxThemes int) []models.CommentTheme {
+	themes := []models.CommentTheme{}
+	docs := make([]map[string]bool, len(comments))
+	for i, comment := range comments {
+		docs[i] = uniqueTokens(contentTokens(comment))
+	}
+
+	assigned := make([]bool, len(comments))
+	for _, seed := range ExtractKeywords(comments, 0) {
+		if len(themes) >= maxThemes || seed.Count < 2 {
+			break
+		}
+
+		// comments not yet in a theme that mention the seed
+		members := []int{}
+		for i, doc := range docs {
+			if !assigned[i] && doc[seed.Term] {
+				members = append(members, i)
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+
+		cooccurring := map[string]int{}
+		for _, i := range members {
+			for token := range docs[i] {
+				if token != seed.Term {
+					cooccurring[token]++
+				}
+			}
+		}
+		keywords := []string{seed.Term}
+		for _, term := range topTerms(cooccurring, 4, int(math.Ceil(float64(len(members))/2))) {
+			keywords = append(keywords, term.Term)
+		}
+		sort.Strings(keywords[1:])
+
+		theme := models.CommentTheme{Label: seed.Term, Keywords: keywords}
+		sum := 0.0
+		for _, i := range members {
+			assigned[i] = true
+			theme.CommentCount++
+			sum += scores[i]
+		}
+		theme.AverageSentiment = round3(sum / float64(theme.CommentCount))
+		themes = append(themes, theme)
+	}
+	return themes
+}
+
+func round3(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
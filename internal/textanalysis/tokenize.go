@@ -0,0 +1,73 @@
+package textanalysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize lower-cases text and splits it into words, keeping apostrophes inside words.
+// Redaction placeholders stay whole, e.g. "[email]", so they are never mistaken for the
+// word itself.
+func Tokenize(text string) []string {
+	text = strings.ReplaceAll(text, "’", "'")
+	tokens := []string{}
+	var current strings.Builder
+	flush := func() {
+		token := current.String()
+		current.Reset()
+		if !isPlaceholder(token) {
+			token = strings.Trim(token, "'[]")
+		}
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'':
+			current.WriteRune(r)
+		case r == '[':
+			flush()
+			current.WriteRune(r)
+		case r == ']':
+			if strings.HasPrefix(current.String(), "[") {
+				current.WriteRune(r)
+			}
+			flush()
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// placeholders are the tokens of the redaction placeholders
+var placeholders = map[string]bool{
+	strings.ToLower(RedactedEmail): true,
+	strings.ToLower(RedactedPhone): true,
+	strings.ToLower(RedactedID):    true,
+	strings.ToLower(RedactedName):  true,
+}
+
+// isPlaceholder reports whether a token came from a redaction placeholder
+func isPlaceholder(token string) bool {
+	return placeholders[token]
+}
+
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`a about above after again against all am an and any are aren't as at be because been
+		before being below between both but by can can't cannot could couldn't did didn't do does doesn't doing don't down
+		during each few for from further had hadn't has hasn't have haven't having he he'd he'll he's her here here's hers
+		herself him himself his how how's i i'd i'll i'm i've if in into is isn't it it's its itself let's me more most
+		mustn't my myself no nor not of off on once only or other ought our ours ourselves out over own same shan't she
+		she'd she'll she's should shouldn't so some such than that that's the their theirs them themselves then there
+		there's these they they'd they'll they're they've this those through to too under until up very was wasn't we
+		we'd we'll we're we've were weren't what what's when when's where where's which while who who's whom why why's
+		with won't would wouldn't you you'd you'll you're you've your yours yourself yourselves also just really would
+		get got much many lot lots even still well us will one two class course professor prof semester`) {
+		stopwords[word] = true
+	}
+}
@@ -2,56 +2,180 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"strings"
+	"sync"
 
 	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/api/iterator"
 
 	"time"
+
+	"api-server/internal/logging"
 )
 
-func UploadFileToGCS(file io.Reader, fileName, bucketName string) (string, error) {
-	ctx := context.Background()
+// instrument names are fixed, so creating them cannot fail
+var storageDuration, _ = otel.Meter("api-server/utils").Float64Histogram("storage.operation.duration",
+	metric.WithDescription("Duration of GCS operations"),
+	metric.WithUnit("s"))
+
+var (
+	storageTimeout     = time.Minute
+	storageTimeoutLock sync.RWMutex
+)
+
+// SetStorageTimeout bounds how long a single GCS call may run
+func SetStorageTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	storageTimeoutLock.Lock()
+	defer storageTimeoutLock.Unlock()
+	storageTimeout = timeout
+}
+
+// withStorageTimeout applies the storage timeout on top of any deadline of ctx
+func withStorageTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	storageTimeoutLock.RLock()
+	defer storageTimeoutLock.RUnlock()
+	return context.WithTimeout(ctx, storageTimeout)
+}
+
+// recordStorageOperation records how long a GCS operation started at start took and whether it failed
+func recordStorageOperation(ctx context.Context, operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	storageDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("outcome", outcome),
+	))
+}
+
+func UploadFileToGCS(ctx context.Context, file io.Reader, fileName, bucketName, contentType string) (string, error) {
+	return writeObjectToGCS(ctx, file, uploadObjectPath(fileName), bucketName, contentType, nil)
+}
+
+// QuarantineFileInGCS stores an infected upload under the quarantine prefix so it is never served
+func QuarantineFileInGCS(ctx context.Context, file io.Reader, fileName, bucketName, prefix, signature string) (string, error) {
+	return writeObjectToGCS(ctx, file, quarantineObjectPath(prefix, fileName), bucketName, "application/octet-stream", quarantineMetadata(signature))
+}
+
+// CopyFileToUploadsInGCS stores an object of the bucket, such as an assembled resumable
+// upload, as an upload without passing its content through the server
+func CopyFileToUploadsInGCS(ctx context.Context, gcsURL, fileName, bucketName, contentType string) (string, error) {
+	return copyObjectInGCS(ctx, gcsURL, uploadObjectPath(fileName), bucketName, contentType, nil)
+}
+
+// CopyFileToQuarantineInGCS stores an object of the bucket under the quarantine prefix
+func CopyFileToQuarantineInGCS(ctx context.Context, gcsURL, fileName, bucketName, prefix, signature string) (string, error) {
+	return copyObjectInGCS(ctx, gcsURL, quarantineObjectPath(prefix, fileName), bucketName, "application/octet-stream", quarantineMetadata(signature))
+}
+
+// uploadObjectPath makes the name of an upload unique under the uploads prefix
+func uploadObjectPath(fileName string) string {
+	return fmt.Sprintf("uploads/%d-%s", time.Now().UnixNano(), fileName)
+}
+
+func quarantineObjectPath(prefix, fileName string) string {
+	return fmt.Sprintf("%s/%d-%s", prefix, time.Now().UnixNano(), fileName)
+}
+
+func quarantineMetadata(signature string) map[string]string {
+	return map[string]string{
+		"quarantined": "true",
+		"signature":   signature,
+	}
+}
+
+// UploadChunkToGCS stores one chunk of a resumable upload under the staging prefix. Every
+// call writes its own object, so a retried request never overwrites the recorded chunk.
+func UploadChunkToGCS(ctx context.Context, chunk io.Reader, uploadID string, offset int64, bucketName string) (string, error) {
+	objectPath := fmt.Sprintf("staging/%s/%020d-%d", uploadID, offset, time.Now().UnixNano())
+	return writeObjectToGCS(ctx, chunk, objectPath, bucketName, "application/octet-stream", nil)
+}
+
+// UploadAssembledFileToGCS stores the whole file of a resumable upload next to its chunks
+func UploadAssembledFileToGCS(ctx context.Context, file io.Reader, uploadID, bucketName string) (string, error) {
+	objectPath := fmt.Sprintf("staging/%s/assembled", uploadID)
+	return writeObjectToGCS(ctx, file, objectPath, bucketName, "application/octet-stream", nil)
+}
+
+// UploadReportToGCS stores a generated report under the reports prefix
+func UploadReportToGCS(ctx context.Context, report io.Reader, objectName, bucketName, contentType string) (string, error) {
+	objectPath := fmt.Sprintf("reports/%s", objectName)
+	return writeObjectToGCS(ctx, report, objectPath, bucketName, contentType, nil)
+}
+
+func writeObjectToGCS(ctx context.Context, file io.Reader, objectPath, bucketName, contentType string, metadata map[string]string) (path string, err error) {
+	defer func(start time.Time) { recordStorageOperation(ctx, "write", start, err) }(time.Now())
+	ctx, cancel := withStorageTimeout(ctx)
+	defer cancel()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		log.Printf("Failed to create GCS client: %v", err)
+		logging.FromContext(ctx).Error("Failed to create GCS client", "error", err)
 		return "", err
 	}
 	defer client.Close()
 
-	// Generate a unique filename (optional)
-	uniqueFileName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), fileName)
-
-	// Define GCS object path
-	objectPath := fmt.Sprintf("uploads/%s", uniqueFileName)
-
 	// Create object handle
 	bucket := client.Bucket(bucketName)
 	object := bucket.Object(objectPath)
 	writer := object.NewWriter(ctx)
+	writer.ContentType = contentType
+	writer.Metadata = metadata
 
 	// Copy file content to GCS
 	if _, err := io.Copy(writer, file); err != nil {
-		log.Printf("Failed to upload file to GCS: %v", err)
+		logging.FromContext(ctx).Error("Failed to upload file to GCS", "error", err)
 		return "", err
 	}
 
 	// Close writer to complete upload
 	if err := writer.Close(); err != nil {
-		log.Printf("Failed to finalize upload: %v", err)
+		logging.FromContext(ctx).Error("Failed to finalize upload", "error", err)
 		return "", err
 	}
 
 	// Return the full GCS path
 	return fmt.Sprintf("gs://%s/%s", bucketName, objectPath), nil
 }
-func DeleteFileFromGCS(gcsURL, bucketName string) error {
-	ctx := context.Background()
+
+func copyObjectInGCS(ctx context.Context, gcsURL, objectPath, bucketName, contentType string, metadata map[string]string) (path string, err error) {
+	defer func(start time.Time) { recordStorageOperation(ctx, "copy", start, err) }(time.Now())
+	ctx, cancel := withStorageTimeout(ctx)
+	defer cancel()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to create GCS client", "error", err)
+		return "", err
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(bucketName)
+	copier := bucket.Object(objectPath).CopierFrom(bucket.Object(GetFilePathFromGCSURL(gcsURL)))
+	copier.ContentType = contentType
+	copier.Metadata = metadata
+	if _, err := copier.Run(ctx); err != nil {
+		logging.FromContext(ctx).Error("Failed to copy file in GCS", "error", err)
+		return "", err
+	}
+	return fmt.Sprintf("gs://%s/%s", bucketName, objectPath), nil
+}
+
+func DeleteFileFromGCS(ctx context.Context, gcsURL, bucketName string) (err error) {
+	defer func(start time.Time) { recordStorageOperation(ctx, "delete", start, err) }(time.Now())
+	ctx, cancel := withStorageTimeout(ctx)
+	defer cancel()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		log.Printf("Failed to create GCS client: %v", err)
+		logging.FromContext(ctx).Error("Failed to create GCS client", "error", err)
 		return err
 	}
 	defer client.Close()
@@ -59,23 +183,49 @@ func DeleteFileFromGCS(gcsURL, bucketName string) error {
 	// Create object handle
 	bucket := client.Bucket(bucketName)
 	object := bucket.Object(filePath)
-	log.Printf("Deleting file: gs://%s/%s", bucketName, filePath)
-	// Delete the file
+	logging.FromContext(ctx).Debug("Deleting file", "bucket", bucketName, "object_path", filePath)
+	// Delete the file, one that is already gone counts as deleted so cleanups can be retried
 	if err := object.Delete(ctx); err != nil {
-		log.Printf("Failed to delete file from GCS: %v", err)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			logging.FromContext(ctx).Info("File was already deleted", "bucket", bucketName, "object_path", filePath)
+			return nil
+		}
+		logging.FromContext(ctx).Error("Failed to delete file from GCS", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully deleted file: gs://%s/%s", bucketName, filePath)
+	logging.FromContext(ctx).Info("Deleted file", "bucket", bucketName, "object_path", filePath)
+	return nil
+}
+
+// CheckBucketAccess lists at most one object of the bucket to check that it can be reached
+// with the credentials of the server
+func CheckBucketAccess(ctx context.Context, bucketName string) (err error) {
+	defer func(start time.Time) { recordStorageOperation(ctx, "check", start, err) }(time.Now())
+	ctx, cancel := withStorageTimeout(ctx)
+	defer cancel()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	objects := client.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: "uploads/"})
+	objects.PageInfo().MaxSize = 1
+	if _, err := objects.Next(); err != nil && !errors.Is(err, iterator.Done) {
+		return err
+	}
 	return nil
 }
 
 // GetFileFromGCS retrieves a file from GCS and returns it as a byte array
-func GetFileFromGCS(gcsURL, bucketName string) ([]byte, string, error) {
-	ctx := context.Background()
+func GetFileFromGCS(ctx context.Context, gcsURL, bucketName string) (content []byte, contentType string, err error) {
+	defer func(start time.Time) { recordStorageOperation(ctx, "read", start, err) }(time.Now())
+	ctx, cancel := withStorageTimeout(ctx)
+	defer cancel()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		log.Printf("Failed to create GCS client: %v", err)
+		logging.FromContext(ctx).Error("Failed to create GCS client", "error", err)
 		return nil, "", err
 	}
 	defer client.Close()
@@ -89,16 +239,16 @@ func GetFileFromGCS(gcsURL, bucketName string) ([]byte, string, error) {
 	// Get object attributes to determine content type
 	attrs, err := object.Attrs(ctx)
 	if err != nil {
-		log.Printf("Failed to get file attributes: %v", err)
+		logging.FromContext(ctx).Error("Failed to get file attributes", "error", err)
 		return nil, "", err
 	}
 
-	contentType := attrs.ContentType
+	contentType = attrs.ContentType
 
 	// Read the file
 	reader, err := object.NewReader(ctx)
 	if err != nil {
-		log.Printf("Failed to create reader: %v", err)
+		logging.FromContext(ctx).Error("Failed to create reader", "error", err)
 		return nil, "", err
 	}
 	defer reader.Close()
@@ -106,13 +256,53 @@ func GetFileFromGCS(gcsURL, bucketName string) ([]byte, string, error) {
 	// Read the file content
 	fileContent, err := io.ReadAll(reader)
 	if err != nil {
-		log.Printf("Failed to read file: %v", err)
+		logging.FromContext(ctx).Error("Failed to read file", "error", err)
 		return nil, "", err
 	}
 
 	return fileContent, contentType, nil
 }
 
+// gcsObjectReader releases the client and the storage timeout of an object reader with it
+type gcsObjectReader struct {
+	*storage.Reader
+	ctx    context.Context
+	start  time.Time
+	client *storage.Client
+	cancel context.CancelFunc
+}
+
+func (r *gcsObjectReader) Close() error {
+	err := r.Reader.Close()
+	r.client.Close()
+	r.cancel()
+	recordStorageOperation(r.ctx, "read", r.start, err)
+	return err
+}
+
+// OpenFileFromGCS returns a reader streaming a file from GCS, which the caller closes.
+// The storage timeout covers the whole read.
+func OpenFileFromGCS(ctx context.Context, gcsURL, bucketName string) (io.ReadCloser, error) {
+	start := time.Now()
+	timeoutCtx, cancel := withStorageTimeout(ctx)
+	client, err := storage.NewClient(timeoutCtx)
+	if err != nil {
+		cancel()
+		recordStorageOperation(ctx, "read", start, err)
+		logging.FromContext(ctx).Error("Failed to create GCS client", "error", err)
+		return nil, err
+	}
+	reader, err := client.Bucket(bucketName).Object(GetFilePathFromGCSURL(gcsURL)).NewReader(timeoutCtx)
+	if err != nil {
+		client.Close()
+		cancel()
+		recordStorageOperation(ctx, "read", start, err)
+		logging.FromContext(ctx).Error("Failed to create reader", "error", err)
+		return nil, err
+	}
+	return &gcsObjectReader{Reader: reader, ctx: ctx, start: start, client: client, cancel: cancel}, nil
+}
+
 // write a function to trim filename out of filepath: gs://shaw-bucket/uploads/1741664748290248000-image.png i want to get /uploads/1741664748290248000-image.png
 func GetFilePathFromGCSURL(gcsURL string) string {
 	parts := strings.SplitN(gcsURL, "/", 4)
@@ -0,0 +1,221 @@
+package validators
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedFileType is returned when the sniffed content type is not in the allowlist
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	// ErrInvalidPDF is returned when a file claiming to be a PDF is structurally broken
+	ErrInvalidPDF = errors.New("invalid PDF document")
+	// ErrEncryptedPDF is returned for password protected PDFs which cannot be processed
+	ErrEncryptedPDF = errors.New("encrypted PDF documents are not supported")
+	// ErrTooManyPages is returned when a PDF exceeds the configured page limit
+	ErrTooManyPages = errors.New("PDF exceeds the maximum number of pages")
+)
+
+var (
+	pdfPageRegex    = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfCountRegex   = regexp.MustCompile(`/Type\s*/Pages\b[^>]*?/Count\s+(\d+)|/Count\s+(\d+)[^>]*?/Type\s*/Pages\b`)
+	pdfEncryptRegex = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+	unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// the PDF spec allows the header and trailer to be offset by a small amount of garbage
+const pdfMarkerWindow = 1024
+
+const (
+	// pdfScanOverlap is how much data before a write is searched again with it, so
+	// markers split between writes are still found
+	pdfScanOverlap = 4096
+	// pdfMatchMargin leaves matches ending this close to the data seen so far to a
+	// later write, which may still extend them
+	pdfMatchMargin = 64
+)
+
+// SniffContentType detects the content type from the file's magic bytes
+func SniffContentType(data []byte) string {
+	header := data
+	if len(header) > pdfMarkerWindow {
+		header = header[:pdfMarkerWindow]
+	}
+	switch {
+	case bytes.Contains(header, []byte("%PDF-")):
+		return "application/pdf"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	}
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// FileInspector collects what ValidateContent and ValidatePDF need from a file written to
+// it in pieces, holding only its head, its tail and a few kilobytes around every write.
+// Page markers longer than pdfScanOverlap are not recognised across writes.
+type FileInspector struct {
+	size      int64
+	head      []byte
+	tail      []byte
+	window    []byte
+	settled   int
+	startxref bool
+	encrypted bool
+	pages     int
+	pageCount int
+}
+
+func NewFileInspector() *FileInspector {
+	return &FileInspector{}
+}
+
+func (f *FileInspector) Write(p []byte) (int, error) {
+	f.size += int64(len(p))
+	if missing := pdfMarkerWindow - len(f.head); missing > 0 {
+		f.head = append(f.head, p[:min(missing, len(p))]...)
+	}
+	f.tail = append(f.tail, p[max(len(p)-pdfMarkerWindow, 0):]...)
+	if len(f.tail) > pdfMarkerWindow {
+		f.tail = append([]byte(nil), f.tail[len(f.tail)-pdfMarkerWindow:]...)
+	}
+
+	buf := p
+	if len(f.window) > 0 {
+		buf = append(f.window, p...)
+	}
+	f.scan(buf, len(buf)-pdfMatchMargin)
+	keep := max(f.settled-pdfScanOverlap, 0)
+	f.window = append([]byte(nil), buf[keep:]...)
+	f.settled -= keep
+	return len(p), nil
+}
+
+// scan records the markers of buf, counting the pages that end after what was settled
+// before and up to limit
+func (f *FileInspector) scan(buf []byte, limit int) {
+	if bytes.Contains(buf, []byte("startxref")) {
+		f.startxref = true
+	}
+	if pdfEncryptRegex.Match(buf) {
+		f.encrypted = true
+	}
+	for _, match := range pdfPageRegex.FindAllIndex(buf, -1) {
+		if match[1] > f.settled && match[1] <= limit {
+			f.pages++
+		}
+	}
+	for _, match := range pdfCountRegex.FindAllSubmatchIndex(buf, -1) {
+		if match[1] <= f.settled || match[1] > limit {
+			continue
+		}
+		for i := 2; i < len(match); i += 2 {
+			if match[i] < 0 {
+				continue
+			}
+			if count, err := strconv.Atoi(string(buf[match[i]:match[i+1]])); err == nil && count > f.pageCount {
+				f.pageCount = count
+			}
+		}
+	}
+	f.settled = max(f.settled, limit)
+}
+
+// finish settles the matches at the end of the file
+func (f *FileInspector) finish() {
+	if f.settled < len(f.window) {
+		f.scan(f.window, len(f.window))
+	}
+}
+
+// ContentType returns the content type sniffed from the head of the file
+func (f *FileInspector) ContentType() string {
+	return SniffContentType(f.head)
+}
+
+// ValidateContent checks the sniffed content type against the allowlist and returns it
+func (f *FileInspector) ValidateContent(allowedTypes []string) (string, error) {
+	if f.size == 0 {
+		return "", errors.New("file is empty")
+	}
+	contentType := f.ContentType()
+	for _, allowed := range allowedTypes {
+		if strings.EqualFold(strings.TrimSpace(allowed), contentType) {
+			return contentType, nil
+		}
+	}
+	return contentType, fmt.Errorf("%w: %s", ErrUnsupportedFileType, contentType)
+}
+
+// ValidatePDF performs structural checks on a PDF and returns its page count
+func (f *FileInspector) ValidatePDF(maxPages int) (int, error) {
+	f.finish()
+	if !bytes.Contains(f.head, []byte("%PDF-")) {
+		return 0, fmt.Errorf("%w: missing %%PDF header", ErrInvalidPDF)
+	}
+	if !bytes.Contains(f.tail, []byte("%%EOF")) {
+		return 0, fmt.Errorf("%w: missing %%%%EOF marker", ErrInvalidPDF)
+	}
+	if !f.startxref {
+		return 0, fmt.Errorf("%w: missing cross-reference table", ErrInvalidPDF)
+	}
+	if f.encrypted {
+		return 0, ErrEncryptedPDF
+	}
+
+	// page objects, or the page tree /Count for documents whose page objects live in
+	// compressed object streams
+	pages := max(f.pages, f.pageCount)
+	if pages == 0 {
+		return 0, fmt.Errorf("%w: no pages found", ErrInvalidPDF)
+	}
+	if maxPages > 0 && pages > maxPages {
+		return pages, fmt.Errorf("%w: %d pages, limit is %d", ErrTooManyPages, pages, maxPages)
+	}
+	return pages, nil
+}
+
+// ValidateFileContent checks the sniffed content type against the allowlist and returns it
+func ValidateFileContent(data []byte, allowedTypes []string) (string, error) {
+	inspector := NewFileInspector()
+	inspector.Write(data)
+	return inspector.ValidateContent(allowedTypes)
+}
+
+// ValidatePDF performs structural checks on a PDF and returns its page count
+func ValidatePDF(data []byte, maxPages int) (int, error) {
+	inspector := NewFileInspector()
+	inspector.Write(data)
+	return inspector.ValidatePDF(maxPages)
+}
+
+// SanitizeFileName strips directories and unsafe characters so the name is safe for
+// object paths and Content-Disposition headers
+func SanitizeFileName(fileName string) string {
+	name := strings.ReplaceAll(fileName, "\\", "/")
+	name = path.Base(name)
+	name = unsafeNameChars.ReplaceAllString(name, "_")
+	name = strings.TrimLeft(name, "._")
+	if len(name) > 255 {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = name[:255-len(ext)] + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
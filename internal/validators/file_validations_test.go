@@ -0,0 +1,66 @@
+package validators
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testPDF(pages int, filler int) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	b.WriteString("1 0 obj << /Type /Pages /Count 2 >> endobj\n")
+	for i := 0; i < pages; i++ {
+		b.WriteString("2 0 obj << /Type /Page >> endobj\n")
+		b.WriteString(strings.Repeat("x", filler))
+	}
+	b.WriteString("startxref\n0\n%%EOF\n")
+	return b.Bytes()
+}
+
+// write feeds data to a new inspector in pieces of size
+func write(data []byte, size int) *FileInspector {
+	inspector := NewFileInspector()
+	for len(data) > 0 {
+		n := min(size, len(data))
+		inspector.Write(data[:n])
+		data = data[n:]
+	}
+	return inspector
+}
+
+func TestFileInspectorMatchesWholeFileAcrossWrites(t *testing.T) {
+	data := testPDF(5, 3000)
+	want, err := ValidatePDF(data, 0)
+	if err != nil || want != 5 {
+		t.Fatalf("ValidatePDF = %d, %v; want 5 pages", want, err)
+	}
+	for _, size := range []int{1, 7, 64, 1000, 4096, 10000} {
+		inspector := write(data, size)
+		if pages, err := inspector.ValidatePDF(0); err != nil || pages != want {
+			t.Errorf("written in pieces of %d: %d pages, %v; want %d", size, pages, err, want)
+		}
+		if contentType := inspector.ContentType(); contentType != "application/pdf" {
+			t.Errorf("written in pieces of %d: content type %q", size, contentType)
+		}
+	}
+}
+
+func TestFileInspectorRejectsAcrossWrites(t *testing.T) {
+	encrypted := append(testPDF(1, 5000), []byte("trailer << /Encrypt 9 0 R >>\n%%EOF\n")...)
+	if _, err := write(encrypted, 3).ValidatePDF(0); !errors.Is(err, ErrEncryptedPDF) {
+		t.Errorf("encrypted PDF: %v, want ErrEncryptedPDF", err)
+	}
+	truncated := testPDF(1, 5000)
+	truncated = truncated[:len(truncated)-6]
+	if _, err := write(truncated, 100).ValidatePDF(0); !errors.Is(err, ErrInvalidPDF) {
+		t.Errorf("PDF without %%%%EOF: %v, want ErrInvalidPDF", err)
+	}
+	if _, err := write(testPDF(3, 2000), 500).ValidatePDF(2); !errors.Is(err, ErrTooManyPages) {
+		t.Errorf("3 pages with a limit of 2: %v, want ErrTooManyPages", err)
+	}
+	if _, err := NewFileInspector().ValidateContent([]string{"application/pdf"}); err == nil {
+		t.Error("empty file accepted")
+	}
+}
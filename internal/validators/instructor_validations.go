@@ -4,6 +4,8 @@ import (
 	"errors"
 	"strings"
 
+	"api-server/internal/models"
+
 	"github.com/google/uuid"
 )
 
@@ -21,15 +23,9 @@ func ValidateInstructorID(instructorID string) error {
 	return nil
 }
 
-// ValidateInstructorName validates the instructor's name.
-func ValidateInstructorName(name string) error {
-	if strings.TrimSpace(name) == "" {
-		return errors.New("name cannot be empty or just blank")
-	}
-	if ContainsNumber(name) {
-		return errors.New("name should not contain any numbers")
-	}
-	return nil
+// ValidateInstructorRequest validates the instructor request body of POST and PUT
+func ValidateInstructorRequest(req models.InstructorRequest) error {
+	return ValidateStruct(req)
 }
 
 // ValidateInstructorRequestBody validates if the instructor request body is valid
@@ -45,20 +41,11 @@ func ValidateInstructorRequestBody(contentLength int64, requireBody bool) error
 
 // ValidateInstructorPatchFields validates the fields for patch requests
 func ValidateInstructorPatchFields(fields map[string]interface{}) error {
-	// Check if name field is provided
-	nameVal, ok := fields["name"]
-	if !ok {
+	// name is the only field that can be changed
+	if _, ok := fields["name"]; !ok {
 		return errors.New("no valid fields to update")
 	}
-
-	// Check if name is a string
-	name, ok := nameVal.(string)
-	if !ok {
-		return errors.New("invalid name format")
-	}
-
-	// Validate the name value
-	return ValidateInstructorName(name)
+	return ValidatePartial(fields, models.InstructorRequest{})
 }
 
 // ExtractInstructorID extracts the instructor_id from the URL path.
@@ -0,0 +1,42 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Supported report formats
+const (
+	ReportFormatHTML = "html"
+	ReportFormatPDF  = "pdf"
+)
+
+// ValidateReportParameters only allows the given query parameters, each at most once
+func ValidateReportParameters(queryParams map[string][]string, allowed ...string) error {
+	for key, values := range queryParams {
+		permitted := false
+		for _, name := range allowed {
+			if key == name {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return fmt.Errorf("query parameter %q is not allowed", key)
+		}
+		if len(values) > 1 {
+			return fmt.Errorf("query parameter %q may only be given once", key)
+		}
+	}
+	return nil
+}
+
+// ValidateReportFormat validates the requested report format
+func ValidateReportFormat(format string) error {
+	switch strings.ToLower(format) {
+	case ReportFormatHTML, ReportFormatPDF:
+		return nil
+	}
+	return errors.New("format must be html or pdf")
+}
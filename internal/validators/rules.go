@@ -0,0 +1,358 @@
+package validators
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError describes why a single field of a request is invalid
+type FieldError struct {
+	// Field is the JSON name of the field, with dots for nested objects and
+	// brackets for array indexes, e.g. questions[0].ratings[1].count
+	Field string
+	// Pointer is the RFC 6901 JSON pointer of the field, e.g. /questions/0/ratings/1/count
+	Pointer string
+	// Code is a machine readable reason such as required, max_length or uuid
+	Code    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors collects every invalid field of a request so clients can fix them in one go
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records an invalid field
+func (v *ValidationErrors) Add(field, code, message string) {
+	*v = append(*v, FieldError{Field: field, Pointer: FieldPointer(field), Code: code, Message: message})
+}
+
+// Err returns the collected errors, or nil when every field is valid
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// FieldPointer converts a field path such as questions[0].text to the JSON pointer /questions/0/text
+func FieldPointer(field string) string {
+	if field == "" {
+		return ""
+	}
+	field = strings.ReplaceAll(field, "]", "")
+	field = strings.ReplaceAll(field, "[", ".")
+	parts := strings.Split(field, ".")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(part, "~", "~0"), "/", "~1")
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Rule checks a non-empty field value against the parameter given in the validate tag,
+// e.g. 15 for max=15. It returns the code and message of a violation, or an empty code.
+type Rule func(field string, value reflect.Value, param string) (code, message string)
+
+// rules are the rules usable in validate struct tags besides required, which
+// is handled by the engine since the other rules are skipped for empty values
+var rules = map[string]Rule{
+	"max":      maxRule,
+	"min":      minRule,
+	"uuid":     uuidRule,
+	"url":      urlRule,
+	"email":    emailRule,
+	"oneof":    oneOfRule,
+	"nodigits": noDigitsRule,
+}
+
+// RegisterRule makes a custom rule available to validate tags. It is not safe to call
+// while requests are validated and is meant to be called from init functions.
+func RegisterRule(name string, rule Rule) {
+	rules[name] = rule
+}
+
+// ValidateStruct checks every field of a request model against its validate tags, e.g.
+//
+//	Code string `json:"code" validate:"required,max=15"`
+//
+// Nested structs and slices of structs are validated as well.
+func ValidateStruct(model interface{}) error {
+	var errs ValidationErrors
+	validateStruct(reflect.Indirect(reflect.ValueOf(model)), "", &errs)
+	return errs.Err()
+}
+
+// ValidatePartial checks the fields present in a decoded JSON object against the validate
+// tags of model, so PATCH bodies follow the same rules as POST and PUT. Fields that are
+// not part of model are ignored.
+func ValidatePartial(fields map[string]interface{}, model interface{}) error {
+	return validatePartial(fields, model, false).Err()
+}
+
+func validatePartial(fields map[string]interface{}, model interface{}, strict bool) ValidationErrors {
+	var errs ValidationErrors
+	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, ok := fieldByJSONName(modelType, name)
+		if !ok {
+			if strict {
+				errs.Add(name, "unknown_field", fmt.Sprintf("field %s cannot be updated", name))
+			}
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if fields[name] == nil {
+			// in a merge patch null removes a field, which required fields do not allow
+			if hasRule(tag, "required") {
+				errs.Add(name, "required", fmt.Sprintf("%s cannot be removed", name))
+			}
+			continue
+		}
+		value := reflect.New(field.Type)
+		raw, err := json.Marshal(fields[name])
+		if err == nil {
+			err = json.Unmarshal(raw, value.Interface())
+		}
+		if err != nil {
+			errs.Add(name, "invalid_type", fmt.Sprintf("%s must be %s", name, typeName(field.Type)))
+			continue
+		}
+		validateField(name, tag, value.Elem(), &errs)
+	}
+	return errs
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		validateField(prefix+name, t.Field(i).Tag.Get("validate"), v.Field(i), errs)
+	}
+}
+
+func validateField(field, tag string, value reflect.Value, errs *ValidationErrors) {
+	if isEmpty(value) {
+		if hasRule(tag, "required") {
+			errs.Add(field, "required", fmt.Sprintf("%s is required", field))
+		}
+		return
+	}
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	for _, entry := range splitTag(tag) {
+		name, param, _ := strings.Cut(entry, "=")
+		if name == "required" {
+			continue
+		}
+		rule, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("validators: unknown rule %q on field %s", name, field))
+		}
+		// report one violation per field, the first rule usually explains the rest
+		if code, message := rule(field, value, param); code != "" {
+			errs.Add(field, code, message)
+			return
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		validateStruct(value, field+".", errs)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < value.Len(); i++ {
+				validateStruct(value.Index(i), fmt.Sprintf("%s[%d].", field, i), errs)
+			}
+		}
+	}
+}
+
+func splitTag(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+func hasRule(tag, name string) bool {
+	for _, entry := range splitTag(tag) {
+		if entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty reports whether a value was left out; blank strings count as empty
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return value.IsZero()
+}
+
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if jsonName(t.Field(i)) == name {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "an array"
+	}
+	return "an object"
+}
+
+// number returns the numeric value of integer and float fields
+func number(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+func maxRule(field string, value reflect.Value, param string) (string, string) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validators: invalid max=%s on field %s", param, field))
+	}
+	if value.Kind() == reflect.String {
+		if float64(utf8.RuneCountInString(value.String())) > limit {
+			return "max_length", fmt.Sprintf("%s cannot be longer than %s characters", field, param)
+		}
+		return "", ""
+	}
+	if n, ok := number(value); ok && n > limit {
+		return "max", fmt.Sprintf("%s cannot be higher than %s", field, param)
+	}
+	return "", ""
+}
+
+func minRule(field string, value reflect.Value, param string) (string, string) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validators: invalid min=%s on field %s", param, field))
+	}
+	if value.Kind() == reflect.String {
+		if float64(utf8.RuneCountInString(value.String())) < limit {
+			return "min_length", fmt.Sprintf("%s must be at least %s characters", field, param)
+		}
+		return "", ""
+	}
+	if n, ok := number(value); ok && n < limit {
+		return "min", fmt.Sprintf("%s cannot be lower than %s", field, param)
+	}
+	return "", ""
+}
+
+func uuidRule(field string, value reflect.Value, _ string) (string, string) {
+	if _, err := uuid.Parse(value.String()); err != nil {
+		return "uuid", fmt.Sprintf("invalid UUID format for %s", field)
+	}
+	return "", ""
+}
+
+// urlRule accepts absolute http and https URLs
+func urlRule(field string, value reflect.Value, _ string) (string, string) {
+	parsed, err := url.Parse(value.String())
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "url", fmt.Sprintf("%s must be an absolute http or https URL", field)
+	}
+	return "", ""
+}
+
+func emailRule(field string, value reflect.Value, _ string) (string, string) {
+	if !IsValidEmail(value.String()) {
+		return "email", fmt.Sprintf("invalid email format for %s", field)
+	}
+	return "", ""
+}
+
+// oneOfRule takes the allowed values separated by spaces, e.g. oneof=html pdf
+func oneOfRule(field string, value reflect.Value, param string) (string, string) {
+	actual := fmt.Sprint(value.Interface())
+	allowed := strings.Fields(param)
+	for _, candidate := range allowed {
+		if actual == candidate {
+			return "", ""
+		}
+	}
+	return "enum", fmt.Sprintf("%s must be one of %s", field, strings.Join(allowed, ", "))
+}
+
+func noDigitsRule(field string, value reflect.Value, _ string) (string, string) {
+	if ContainsNumber(value.String()) {
+		return "no_digits", fmt.Sprintf("%s cannot contain numbers", field)
+	}
+	return "", ""
+}
@@ -0,0 +1,175 @@
+package validators
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type ruleAddress struct {
+	City   string `json:"city" validate:"required,max=10"`
+	Postal *int   `json:"postal" validate:"min=1000,max=9999"`
+}
+
+type ruleRating struct {
+	Rating int `json:"rating" validate:"min=1,max=5"`
+}
+
+type ruleRequest struct {
+	Name     string        `json:"name" validate:"required,nodigits,min=2"`
+	ID       string        `json:"id" validate:"uuid"`
+	Homepage string        `json:"homepage" validate:"url"`
+	Email    string        `json:"email" validate:"email"`
+	Format   string        `json:"format" validate:"oneof=html pdf"`
+	Address  *ruleAddress  `json:"address"`
+	Ratings  []ruleRating  `json:"ratings"`
+	Previous **ruleAddress `json:"previous"`
+	internal string
+}
+
+func validRuleRequest() ruleRequest {
+	return ruleRequest{
+		Name:     "Ada",
+		ID:       "6f1f6b0c-3c3e-4b8e-9d76-0f2d6f6a1c11",
+		Homepage: "https://example.edu",
+		Email:    "ada@example.edu",
+		Format:   "pdf",
+	}
+}
+
+// fieldErrors returns the field errors of err, failing the test when err is not a ValidationErrors
+func fieldErrors(t *testing.T, err error) ValidationErrors {
+	t.Helper()
+	var errs ValidationErrors
+	if err != nil && !errors.As(err, &errs) {
+		t.Fatalf("error %v is not a ValidationErrors", err)
+	}
+	return errs
+}
+
+func TestValidateStructRuleCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ruleRequest)
+		field  string
+		code   string
+	}{
+		{"required", func(r *ruleRequest) { r.Name = "  " }, "name", "required"},
+		{"min_length", func(r *ruleRequest) { r.Name = "A" }, "name", "min_length"},
+		{"no_digits", func(r *ruleRequest) { r.Name = "Ada2" }, "name", "no_digits"},
+		{"uuid", func(r *ruleRequest) { r.ID = "not-a-uuid" }, "id", "uuid"},
+		{"url", func(r *ruleRequest) { r.Homepage = "ftp://example.edu" }, "homepage", "url"},
+		{"email", func(r *ruleRequest) { r.Email = "ada@" }, "email", "email"},
+		{"enum", func(r *ruleRequest) { r.Format = "docx" }, "format", "enum"},
+		{"max_length", func(r *ruleRequest) { r.Address = &ruleAddress{City: "Llanfairpwll"} }, "address.city", "max_length"},
+		{"max", func(r *ruleRequest) { r.Ratings = []ruleRating{{Rating: 6}} }, "ratings[0].rating", "max"},
+		{"min", func(r *ruleRequest) { r.Ratings = []ruleRating{{Rating: 5}, {Rating: -1}} }, "ratings[1].rating", "min"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRuleRequest()
+			tt.modify(&req)
+			errs := fieldErrors(t, ValidateStruct(req))
+			if len(errs) != 1 || errs[0].Field != tt.field || errs[0].Code != tt.code {
+				t.Errorf("errors = %+v, want one %s error on %s", errs, tt.code, tt.field)
+			}
+		})
+	}
+
+	if err := ValidateStruct(validRuleRequest()); err != nil {
+		t.Errorf("valid request: %v", err)
+	}
+}
+
+func TestValidateStructFollowsNestedPointers(t *testing.T) {
+	postal := 12
+	inner := &ruleAddress{Postal: &postal}
+	req := validRuleRequest()
+	req.Address = &ruleAddress{City: "Paris", Postal: &postal}
+	req.Previous = &inner
+
+	errs := fieldErrors(t, ValidateStruct(&req))
+	got := []string{}
+	for _, err := range errs {
+		got = append(got, err.Pointer+" "+err.Code)
+	}
+	want := []string{"/address/postal min", "/previous/city required", "/previous/postal min"}
+	if !slices.Equal(got, want) {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+
+	// nil pointers are empty, so only required would reject them
+	req.Address, req.Previous = nil, nil
+	if err := ValidateStruct(&req); err != nil {
+		t.Errorf("nil nested pointers: %v", err)
+	}
+}
+
+func TestValidateStructCollectsEveryInvalidField(t *testing.T) {
+	req := ruleRequest{
+		ID:      "42",
+		Email:   "nobody",
+		Format:  "txt",
+		Ratings: []ruleRating{{Rating: -1}, {Rating: 3}, {Rating: 9}},
+	}
+	errs := fieldErrors(t, ValidateStruct(req))
+	got := map[string]string{}
+	for _, err := range errs {
+		got[err.Field] = err.Code
+	}
+	want := map[string]string{
+		"name":              "required",
+		"id":                "uuid",
+		"email":             "email",
+		"format":            "enum",
+		"ratings[0].rating": "min",
+		"ratings[2].rating": "max",
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("%s: code %q, want %q", field, got[field], code)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("errors = %+v, want one per invalid field", errs)
+	}
+}
+
+func TestValidatePartialCodes(t *testing.T) {
+	fields := map[string]interface{}{
+		"name":    nil,
+		"email":   42.0,
+		"format":  "pdf",
+		"ratings": []interface{}{map[string]interface{}{"rating": 7.0}},
+		"extra":   true,
+	}
+	errs := fieldErrors(t, ValidatePartial(fields, ruleRequest{}))
+	got := []string{}
+	for _, err := range errs {
+		got = append(got, err.Field+" "+err.Code)
+	}
+	want := []string{"email invalid_type", "name required", "ratings[0].rating max"}
+	if !slices.Equal(got, want) {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+
+	strict := validatePartial(map[string]interface{}{"extra": true}, ruleRequest{}, true)
+	if len(strict) != 1 || strict[0].Code != "unknown_field" {
+		t.Errorf("strict errors = %+v, want one unknown_field error", strict)
+	}
+}
+
+func TestFieldPointer(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"name":                    "/name",
+		"questions[0].ratings[1]": "/questions/0/ratings/1",
+		"address.city":            "/address/city",
+		"a/b~c":                   "/a~1b~0c",
+	}
+	for field, want := range tests {
+		if got := FieldPointer(field); got != want {
+			t.Errorf("FieldPointer(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
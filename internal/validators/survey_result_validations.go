@@ -0,0 +1,53 @@
+package validators
+
+import (
+	"fmt"
+
+	"api-server/internal/models"
+)
+
+// TRACE surveys use a five point scale
+const (
+	MinSurveyRating = 1
+	MaxSurveyRating = 5
+)
+
+// ValidateSurveyResultRequest validates results delivered by the processing service
+func ValidateSurveyResultRequest(req models.SurveyResultRequest) error {
+	var errs ValidationErrors
+	if err := ValidateStruct(req); err != nil {
+		errs = err.(ValidationErrors)
+	}
+
+	if req.EnrolledCount > 0 && req.ResponseCount > req.EnrolledCount {
+		errs.Add("response_count", "exceeds_enrolled", "response_count cannot be higher than enrolled_count")
+	}
+	if len(req.Questions) == 0 && len(req.Comments) == 0 {
+		errs.Add("questions", "required", "results must contain questions or comments")
+	}
+
+	positions := map[int]bool{}
+	for i, question := range req.Questions {
+		if positions[question.Position] {
+			errs.Add(fmt.Sprintf("questions[%d].position", i), "duplicate", fmt.Sprintf("questions[%d].position %d is used more than once", i, question.Position))
+		}
+		positions[question.Position] = true
+
+		ratings := map[int]bool{}
+		total := 0
+		for j, rating := range question.Ratings {
+			field := fmt.Sprintf("questions[%d].ratings[%d].rating", i, j)
+			if rating.Rating < MinSurveyRating || rating.Rating > MaxSurveyRating {
+				errs.Add(field, "range", fmt.Sprintf("%s must be between %d and %d", field, MinSurveyRating, MaxSurveyRating))
+			} else if ratings[rating.Rating] {
+				errs.Add(field, "duplicate", fmt.Sprintf("%s %d is used more than once", field, rating.Rating))
+			}
+			ratings[rating.Rating] = true
+			total += rating.Count
+		}
+		if total > question.ResponseCount {
+			errs.Add(fmt.Sprintf("questions[%d].ratings", i), "exceeds_responses", fmt.Sprintf("questions[%d] has more ratings than responses", i))
+		}
+	}
+	return errs.Err()
+}
@@ -36,18 +36,18 @@ func ValidateSemesterTerm(semesterTerm string) error {
 	return nil
 }
 
-// ValidateTraceRequest validates the request body for creating a new trace
+// ValidateTraceRequest validates the metadata of a new trace
 func ValidateTraceRequest(trace models.TraceRequest) error {
-	if err := ValidateSection(trace.Section); err != nil {
-		return err
-	}
-	if err := ValidateSemesterTerm(trace.SemesterTerm); err != nil {
-		return err
-	}
-	if err := ValidateCourseInstructorID(trace.InstructorID); err != nil {
-		return err
+	return ValidateStruct(trace)
+}
+
+// ValidateTracePatchFields validates a JSON Merge Patch of trace metadata. Fields other
+// than those of models.TracePatchRequest are rejected and required fields cannot be removed.
+func ValidateTracePatchFields(fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return errors.New("no valid fields to update")
 	}
-	return nil
+	return validatePartial(fields, models.TracePatchRequest{}, true).Err()
 }
 
 // validate filename
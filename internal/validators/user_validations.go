@@ -4,7 +4,6 @@ import (
 	"api-server/internal/models"
 	"errors"
 	"regexp"
-	"strings"
 	"unicode"
 
 	"github.com/google/uuid"
@@ -26,72 +25,25 @@ func ValidateUserID(userID string) error {
 
 // ValidateUserRequest validates all fields in the user creation request
 func ValidateUserRequest(req models.UserRequest) error {
-	if strings.TrimSpace(req.FirstName) == "" {
-		return errors.New("first_name is required")
-	}
-	if strings.TrimSpace(req.LastName) == "" {
-		return errors.New("last_name is required")
-	}
-	if strings.TrimSpace(req.Username) == "" {
-		return errors.New("username is required")
-	}
-	if strings.TrimSpace(req.Password) == "" {
-		return errors.New("password is required")
-	}
-
-	if ContainsNumber(req.FirstName) {
-		return errors.New("first_name cannot contain numbers")
-	}
-	if ContainsNumber(req.LastName) {
-		return errors.New("last_name cannot contain numbers")
-	}
-
-	if !IsValidEmail(req.Username) {
-		return errors.New("invalid email format")
-	}
-
-	if len(req.Password) < 8 {
-		return errors.New("password must be at least 8 characters")
-	}
-
-	return nil
+	return ValidateStruct(req)
 }
 
-// ValidateUserUpdateFields validates the fields for user update
+// ValidateUserUpdateFields validates the fields for user update with the rules of ValidateUserRequest
 func ValidateUserUpdateFields(fields map[string]interface{}) error {
+	var errs ValidationErrors
 	// Prevent username updates
 	if _, ok := fields["username"]; ok {
-		return errors.New("username cannot be changed")
-	}
-
-	// Validate first_name if provided
-	if firstName, ok := fields["first_name"].(string); ok {
-		if strings.TrimSpace(firstName) == "" {
-			return errors.New("first_name cannot be empty")
-		}
-		if ContainsNumber(firstName) {
-			return errors.New("first_name cannot contain numbers")
-		}
+		errs.Add("username", "immutable", "username cannot be changed")
 	}
 
-	// Validate last_name if provided
-	if lastName, ok := fields["last_name"].(string); ok {
-		if strings.TrimSpace(lastName) == "" {
-			return errors.New("last_name cannot be empty")
-		}
-		if ContainsNumber(lastName) {
-			return errors.New("last_name cannot contain numbers")
+	updatable := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		if name != "username" {
+			updatable[name] = value
 		}
 	}
-
-	// Validate password if provided
-	if password, ok := fields["password"].(string); ok {
-		if len(password) < 8 {
-			return errors.New("password must be at least 8 characters")
-		}
-	}
-
-	return nil
+	errs = append(errs, validatePartial(updatable, models.UserRequest{}, false)...)
+	return errs.Err()
 }
 
 // ValidateRequestParameters checks for unwanted query parameters
@@ -0,0 +1,71 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"api-server/internal/kafka"
+	"api-server/internal/models"
+)
+
+// WebhookEventTypes are the events a webhook can subscribe to
+var WebhookEventTypes = []string{
+	kafka.EventTraceUploaded,
+	kafka.EventTraceFileReplaced,
+	kafka.EventTraceUpdated,
+	kafka.EventTraceResultsProcessed,
+	kafka.EventTraceDeleted,
+	kafka.EventCourseCreated,
+	kafka.EventCourseUpdated,
+	kafka.EventCourseDeleted,
+}
+
+// ValidateWebhookRequest validates a new webhook subscription
+func ValidateWebhookRequest(req models.WebhookRequest) error {
+	errs, _ := ValidateStruct(req).(ValidationErrors)
+	validateWebhookEventTypes(req.EventTypes, &errs)
+	return errs.Err()
+}
+
+// ValidateWebhookPatchFields validates a JSON Merge Patch of a webhook subscription. Fields
+// other than those of models.WebhookPatchRequest are rejected.
+func ValidateWebhookPatchFields(fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return errors.New("no valid fields to update")
+	}
+	errs := validatePartial(fields, models.WebhookPatchRequest{}, true)
+	if values, ok := fields["event_types"].([]interface{}); ok {
+		eventTypes := make([]string, 0, len(values))
+		for _, value := range values {
+			eventType, _ := value.(string)
+			eventTypes = append(eventTypes, eventType)
+		}
+		validateWebhookEventTypes(eventTypes, &errs)
+	}
+	return errs.Err()
+}
+
+func validateWebhookEventTypes(eventTypes []string, errs *ValidationErrors) {
+	seen := map[string]bool{}
+	for i, eventType := range eventTypes {
+		field := fmt.Sprintf("event_types[%d]", i)
+		if seen[eventType] {
+			errs.Add(field, "duplicate", fmt.Sprintf("%s repeats %s", field, eventType))
+			continue
+		}
+		seen[eventType] = true
+		if !isWebhookEventType(eventType) {
+			errs.Add(field, "enum", fmt.Sprintf("%s must be one of %s", field, strings.Join(WebhookEventTypes, ", ")))
+		}
+	}
+}
+
+func isWebhookEventType(eventType string) bool {
+	for _, candidate := range WebhookEventTypes {
+		if eventType == candidate {
+			return true
+		}
+	}
+	return false
+}